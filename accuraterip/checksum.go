@@ -0,0 +1,53 @@
+package accuraterip
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// samplesPerSector are the stereo samples of a CD sector.
+const samplesPerSector = 588
+
+// skippedSamples are left out of the checksums at the start of the first
+// track and the end of the last one, drives not reading them reliably.
+const skippedSamples = 5 * samplesPerSector
+
+// Checksum is the AccurateRip v1 and v2 checksums of a track.
+type Checksum struct {
+	V1 uint32
+	V2 uint32
+}
+
+// TrackChecksum computes the checksums of the audio of a track, 16 bits
+// stereo little endian samples, sectors long. The first and last tracks of
+// the disc skip their first and last 5 sectors.
+func TrackChecksum(r io.Reader, sectors int, first, last bool) (Checksum, error) {
+	samples := uint32(sectors * samplesPerSector)
+	checkFrom := uint32(1)
+	checkTo := samples
+	if first {
+		checkFrom += skippedSamples - 1
+	}
+	if last {
+		checkTo -= skippedSamples
+	}
+
+	var sum Checksum
+	reader := bufio.NewReaderSize(r, 64*1024)
+	buf := make([]byte, 4)
+	for position := uint32(1); position <= samples; position++ {
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return sum, fmt.Errorf("short track, read %d of %d samples: %w", position-1, samples, err)
+		}
+		if position < checkFrom || position > checkTo {
+			continue
+		}
+		sample := binary.LittleEndian.Uint32(buf)
+		product := uint64(sample) * uint64(position)
+		sum.V1 += uint32(product)
+		sum.V2 += uint32(product) + uint32(product>>32)
+	}
+	return sum, nil
+}
@@ -0,0 +1,64 @@
+package accuraterip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// constantTrack returns sectors of audio whose stereo samples are all sample.
+func constantTrack(sectors int, sample uint32) []byte {
+	audio := make([]byte, sectors*samplesPerSector*4)
+	for i := 0; i < len(audio); i += 4 {
+		binary.LittleEndian.PutUint32(audio[i:], sample)
+	}
+	return audio
+}
+
+// sumPositions returns the sum of the sample positions from first to last,
+// modulo 2^32.
+func sumPositions(first, last int) uint32 {
+	return uint32((last*(last+1) - (first-1)*first) / 2)
+}
+
+func TestTrackChecksum(t *testing.T) {
+	tests := []struct {
+		name        string
+		sectors     int
+		sample      uint32
+		first, last bool
+		want        Checksum
+	}{
+		// the first track skips its first 5 sectors but the last sample of
+		// the 5th, the checksum adding up positions 2940 to 7056
+		{"first", 12, 1, true, false, Checksum{V1: 0x0139f9fe, V2: 0x0139f9fe}},
+		// each product overflows: v1 adds up -position, and v2 the low and
+		// high halves of each product, 2^32 - 1
+		{"middle", 8, 0xffffffff, false, false, Checksum{V1: 0xff5724d0, V2: 0xffffeda0}},
+		// the last track skips its last 5 sectors, positions 1 to 5880
+		{"last", 15, 2, false, true, Checksum{V1: 0x020fa738, V2: 0x020fa738}},
+		{"only", 12, 1, true, true, Checksum{V1: sumPositions(2940, 4116), V2: sumPositions(2940, 4116)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audio := constantTrack(tt.sectors, tt.sample)
+			got, err := TrackChecksum(bytes.NewReader(audio), tt.sectors, tt.first, tt.last)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("checksum = %08x %08x, want %08x %08x", got.V1, got.V2, tt.want.V1, tt.want.V2)
+			}
+		})
+	}
+	if want := sumPositions(2940, 7056); tests[0].want.V1 != want {
+		t.Fatalf("first track checksum %08x, want %08x", tests[0].want.V1, want)
+	}
+}
+
+func TestTrackChecksumOfShortTrack(t *testing.T) {
+	audio := constantTrack(7, 1)
+	if _, err := TrackChecksum(bytes.NewReader(audio), 8, false, false); err == nil {
+		t.Fatal("checksum of a short track succeeded")
+	}
+}
@@ -0,0 +1,143 @@
+package accuraterip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultURL is the AccurateRip database.
+const DefaultURL = "http://www.accuraterip.com/accuraterip"
+
+// ErrNotFound is returned for discs missing from the database.
+var ErrNotFound = errors.New("disc not found in AccurateRip database")
+
+// Pressing is the checksums of the tracks of a pressing of the disc.
+type Pressing struct {
+	Tracks []Entry
+}
+
+// Entry is the checksum of a track of a pressing, and how many users ripped
+// it. The checksum is a v1 or v2 one, depending on the software that
+// submitted it.
+type Entry struct {
+	Confidence int
+	CRC        uint32
+	// Frame450CRC is the checksum of the 450th sector, used to detect the
+	// read offset
+	Frame450CRC uint32
+}
+
+// ParseResponse decodes a database response, a pressing after another.
+// Each starts with the track count and the disc IDs, followed by a
+// confidence and two checksums per track.
+func ParseResponse(data []byte) ([]Pressing, error) {
+	var pressings []Pressing
+	for len(data) > 0 {
+		if len(data) < 13 {
+			return nil, fmt.Errorf("truncated AccurateRip response header")
+		}
+		tracks := int(data[0])
+		data = data[13:]
+		if len(data) < tracks*9 {
+			return nil, fmt.Errorf("truncated AccurateRip response, expected %d tracks", tracks)
+		}
+		pressing := Pressing{Tracks: make([]Entry, tracks)}
+		for i := range pressing.Tracks {
+			pressing.Tracks[i] = Entry{
+				Confidence:  int(data[0]),
+				CRC:         binary.LittleEndian.Uint32(data[1:5]),
+				Frame450CRC: binary.LittleEndian.Uint32(data[5:9]),
+			}
+			data = data[9:]
+		}
+		pressings = append(pressings, pressing)
+	}
+	return pressings, nil
+}
+
+// Fetcher returns the database response of a disc.
+type Fetcher interface {
+	Fetch(toc *TOC) ([]byte, error)
+}
+
+// HTTPFetcher downloads responses from an AccurateRip server.
+type HTTPFetcher struct {
+	URL    string
+	client *http.Client
+}
+
+func NewHTTPFetcher(url string) *HTTPFetcher {
+	return &HTTPFetcher{
+		URL:    url,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (f *HTTPFetcher) Fetch(toc *TOC) ([]byte, error) {
+	path, err := toc.Path()
+	if err != nil {
+		return nil, err
+	}
+	url := f.URL + "/" + path
+	log.Printf("[accuraterip] GET %s", url)
+	resp, err := f.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query AccurateRip: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query AccurateRip: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AccurateRip response: %w", err)
+	}
+	return data, nil
+}
+
+// CachedFetcher keeps the responses of fetcher in a folder, so a disc is
+// downloaded once.
+type CachedFetcher struct {
+	folder  string
+	fetcher Fetcher
+}
+
+func NewCachedFetcher(folder string, fetcher Fetcher) *CachedFetcher {
+	return &CachedFetcher{
+		folder:  folder,
+		fetcher: fetcher,
+	}
+}
+
+func (f *CachedFetcher) Fetch(toc *TOC) ([]byte, error) {
+	path, err := toc.Path()
+	if err != nil {
+		return nil, err
+	}
+	cached := filepath.Join(f.folder, filepath.Base(path))
+	if data, err := os.ReadFile(cached); err == nil {
+		return data, nil
+	}
+	data, err := f.fetcher.Fetch(toc)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(f.folder, 0o755); err != nil {
+		log.Printf("[accuraterip] Failed to cache response: %v", err)
+		return data, nil
+	}
+	if err := os.WriteFile(cached, data, 0o644); err != nil {
+		log.Printf("[accuraterip] Failed to cache response: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,113 @@
+package accuraterip
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testResponse = "dBAR-003-00000043-000000e1-0a00b903.bin"
+
+func readTestResponse(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", testResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testServer serves the responses of testdata as the AccurateRip database.
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	data := readTestResponse(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accuraterip/3/4/0/"+testResponse {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseResponse(t *testing.T) {
+	pressings, err := ParseResponse(readTestResponse(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Pressing{
+		{Tracks: []Entry{{5, 0x0139f9fe, 0x11111111}, {5, 0xff5724d0, 0x22222222}, {5, 0xdeadbeef, 0x33333333}}},
+		{Tracks: []Entry{{2, 0xcafebabe, 0x44444444}, {2, 0xffffeda0, 0x55555555}, {3, 0x020fa738, 0x66666666}}},
+	}
+	if len(pressings) != len(want) {
+		t.Fatalf("%d pressings, want %d", len(pressings), len(want))
+	}
+	for i := range want {
+		for j, entry := range want[i].Tracks {
+			if pressings[i].Tracks[j] != entry {
+				t.Errorf("pressing %d track %d = %+v, want %+v", i, j+1, pressings[i].Tracks[j], entry)
+			}
+		}
+	}
+}
+
+func TestParseTruncatedResponse(t *testing.T) {
+	data := readTestResponse(t)
+	for _, size := range []int{12, 20, len(data) - 1} {
+		if _, err := ParseResponse(data[:size]); err == nil {
+			t.Errorf("response truncated to %d bytes parsed", size)
+		}
+	}
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	server := testServer(t)
+	fetcher := NewHTTPFetcher(server.URL + "/accuraterip")
+	data, err := fetcher.Fetch(testTOC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(readTestResponse(t)) {
+		t.Fatal("unexpected response")
+	}
+
+	unknown := testTOC()
+	unknown.LeadOut++
+	if _, err := fetcher.Fetch(unknown); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fetch of an unknown disc = %v, want ErrNotFound", err)
+	}
+}
+
+// countingFetcher counts the responses fetched.
+type countingFetcher struct {
+	fetcher Fetcher
+	fetched int
+}
+
+func (f *countingFetcher) Fetch(toc *TOC) ([]byte, error) {
+	f.fetched++
+	return f.fetcher.Fetch(toc)
+}
+
+func TestCachedFetcher(t *testing.T) {
+	server := testServer(t)
+	folder := t.TempDir()
+	counting := &countingFetcher{fetcher: NewHTTPFetcher(server.URL + "/accuraterip")}
+	fetcher := NewCachedFetcher(folder, counting)
+	for range 2 {
+		if _, err := fetcher.Fetch(testTOC()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counting.fetched != 1 {
+		t.Fatalf("fetched %d times, want once", counting.fetched)
+	}
+	if _, err := os.Stat(filepath.Join(folder, testResponse)); err != nil {
+		t.Fatalf("response not cached: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Package accuraterip verifies the audio read from discs against the
+// AccurateRip database, which holds the checksums of the tracks ripped by
+// its users.
+package accuraterip
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.uploadedlobster.com/discid"
+)
+
+// pregap is the 2 seconds lead-in the disc offsets count, in sectors.
+const pregap = 150
+
+// TOC is the table of contents of a disc, its offsets in sectors counting
+// the lead-in as read by libdiscid.
+type TOC struct {
+	// Offsets are the track offsets, in order
+	Offsets  []int
+	LeadOut  int
+	FreedbID string
+}
+
+// ReadTOC reads the table of contents of the disc in device.
+func ReadTOC(device string) (*TOC, error) {
+	disc, err := discid.Read(device)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disc in %s: %w", device, err)
+	}
+	defer disc.Close()
+	toc := &TOC{LeadOut: disc.Sectors(), FreedbID: disc.FreedbID()}
+	for number := disc.FirstTrackNumber(); number <= disc.LastTrackNumber(); number++ {
+		track, err := disc.Track(number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read track %d: %w", number, err)
+		}
+		toc.Offsets = append(toc.Offsets, track.Offset)
+	}
+	if len(toc.Offsets) == 0 {
+		return nil, fmt.Errorf("no track on disc in %s", device)
+	}
+	return toc, nil
+}
+
+// Tracks returns the number of tracks.
+func (toc *TOC) Tracks() int {
+	return len(toc.Offsets)
+}
+
+// Sectors returns the length of track, from 1, in sectors.
+func (toc *TOC) Sectors(track int) int {
+	end := toc.LeadOut
+	if track < len(toc.Offsets) {
+		end = toc.Offsets[track]
+	}
+	return end - toc.Offsets[track-1]
+}
+
+// IDs returns the AccurateRip disc IDs and the FreeDB ID of the disc.
+func (toc *TOC) IDs() (uint32, uint32, uint32, error) {
+	cddb, err := strconv.ParseUint(toc.FreedbID, 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid FreeDB ID %q: %w", toc.FreedbID, err)
+	}
+	var id1, id2 uint32
+	for i, offset := range toc.Offsets {
+		offset -= pregap
+		id1 += uint32(offset)
+		id2 += uint32(max(offset, 1) * (i + 1))
+	}
+	leadOut := toc.LeadOut - pregap
+	id1 += uint32(leadOut)
+	id2 += uint32(leadOut * (len(toc.Offsets) + 1))
+	return id1, id2, uint32(cddb), nil
+}
+
+// Path returns the path of the disc in the AccurateRip database.
+func (toc *TOC) Path() (string, error) {
+	id1, id2, cddb, err := toc.IDs()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x/%x/%x/dBAR-%03d-%08x-%08x-%08x.bin",
+		id1&0xf, id1>>4&0xf, id1>>8&0xf, len(toc.Offsets), id1, id2, cddb), nil
+}
@@ -0,0 +1,53 @@
+package accuraterip
+
+import "testing"
+
+// testTOC is a disc of 3 short tracks, 12, 8 and 15 sectors long, whose
+// database response is in testdata.
+func testTOC() *TOC {
+	return &TOC{
+		Offsets:  []int{150, 162, 170},
+		LeadOut:  185,
+		FreedbID: "0a00b903",
+	}
+}
+
+func TestTOCSectors(t *testing.T) {
+	toc := testTOC()
+	if toc.Tracks() != 3 {
+		t.Fatalf("Tracks = %d", toc.Tracks())
+	}
+	for track, want := range map[int]int{1: 12, 2: 8, 3: 15} {
+		if got := toc.Sectors(track); got != want {
+			t.Errorf("Sectors(%d) = %d, want %d", track, got, want)
+		}
+	}
+}
+
+func TestTOCIDs(t *testing.T) {
+	id1, id2, cddb, err := testTOC().IDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// id1 sums the offsets past the lead-in, 0 + 12 + 20 + 35, and id2 the
+	// offsets by track number, the first counted as 1: 1 + 24 + 60 + 140
+	if id1 != 67 || id2 != 225 || cddb != 0x0a00b903 {
+		t.Fatalf("IDs = %x %x %x", id1, id2, cddb)
+	}
+}
+
+func TestTOCPath(t *testing.T) {
+	path, err := testTOC().Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "3/4/0/dBAR-003-00000043-000000e1-0a00b903.bin"; path != want {
+		t.Fatalf("Path = %s, want %s", path, want)
+	}
+
+	toc := testTOC()
+	toc.FreedbID = "not hex"
+	if _, err := toc.Path(); err == nil {
+		t.Fatal("Path succeeded with an invalid FreeDB ID")
+	}
+}
@@ -0,0 +1,123 @@
+package accuraterip
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Status is the outcome of the verification of a track.
+type Status string
+
+const (
+	// StatusAccurate is a track matching a checksum of the database
+	StatusAccurate Status = "accurate"
+	// StatusMismatch is a track matching none, scratched or misread
+	StatusMismatch Status = "mismatch"
+	// StatusError is a track which could not be read
+	StatusError Status = "error"
+)
+
+// TrackResult is the verification of a track.
+type TrackResult struct {
+	Track  int    `json:"track"`
+	Status Status `json:"status"`
+	V1     string `json:"v1,omitempty"`
+	V2     string `json:"v2,omitempty"`
+	// Confidence is how many users ripped the same checksum
+	Confidence int    `json:"confidence"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the verification of a disc.
+type Report struct {
+	FreedbID  string        `json:"freedb_id"`
+	Pressings int           `json:"pressings"`
+	Tracks    []TrackResult `json:"tracks"`
+}
+
+// Accurate reports whether every track matched the database.
+func (report *Report) Accurate() bool {
+	return report.Failed() == 0
+}
+
+// Failed returns the number of tracks mismatching or not read.
+func (report *Report) Failed() int {
+	failed := 0
+	for _, track := range report.Tracks {
+		if track.Status != StatusAccurate {
+			failed++
+		}
+	}
+	return failed
+}
+
+// TrackOpener opens the audio of a track, from 1, as 16 bits stereo little
+// endian samples.
+type TrackOpener func(ctx context.Context, track int) (io.ReadCloser, error)
+
+// Verify reads each track of the disc with open and compares its checksums
+// with those of the database returned by fetcher. progress is called before
+// each track is read.
+func Verify(ctx context.Context, toc *TOC, fetcher Fetcher, open TrackOpener, progress func(track, tracks int)) (*Report, error) {
+	data, err := fetcher.Fetch(toc)
+	if err != nil {
+		return nil, err
+	}
+	pressings, err := ParseResponse(data)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{FreedbID: toc.FreedbID, Pressings: len(pressings)}
+	tracks := toc.Tracks()
+	for track := 1; track <= tracks; track++ {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		progress(track, tracks)
+		sum, err := readChecksum(ctx, toc, open, track)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return report, ctxErr
+			}
+			report.Tracks = append(report.Tracks, TrackResult{Track: track, Status: StatusError, Error: err.Error()})
+			continue
+		}
+		report.Tracks = append(report.Tracks, Compare(track, sum, pressings))
+	}
+	return report, nil
+}
+
+func readChecksum(ctx context.Context, toc *TOC, open TrackOpener, track int) (Checksum, error) {
+	audio, err := open(ctx, track)
+	if err != nil {
+		return Checksum{}, err
+	}
+	sum, err := TrackChecksum(audio, toc.Sectors(track), track == 1, track == toc.Tracks())
+	if closeErr := audio.Close(); err == nil {
+		err = closeErr
+	}
+	return sum, err
+}
+
+// Compare matches the checksums of track with those of the pressings, the
+// confidence of matching pressings adding up.
+func Compare(track int, sum Checksum, pressings []Pressing) TrackResult {
+	result := TrackResult{
+		Track:  track,
+		Status: StatusMismatch,
+		V1:     fmt.Sprintf("%08x", sum.V1),
+		V2:     fmt.Sprintf("%08x", sum.V2),
+	}
+	for _, pressing := range pressings {
+		if track > len(pressing.Tracks) {
+			continue
+		}
+		entry := pressing.Tracks[track-1]
+		if entry.CRC == sum.V1 || entry.CRC == sum.V2 {
+			result.Status = StatusAccurate
+			result.Confidence += entry.Confidence
+		}
+	}
+	return result
+}
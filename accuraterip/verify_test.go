@@ -0,0 +1,112 @@
+package accuraterip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+)
+
+// testTracks are the audio of the tracks of testTOC, matching the checksums
+// of the database response of testdata.
+func testTracks() map[int][]byte {
+	return map[int][]byte{
+		1: constantTrack(12, 1),
+		2: constantTrack(8, 0xffffffff),
+		3: constantTrack(15, 2),
+	}
+}
+
+func opener(tracks map[int][]byte) TrackOpener {
+	return func(ctx context.Context, track int) (io.ReadCloser, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		audio, ok := tracks[track]
+		if !ok {
+			return nil, fmt.Errorf("failed to read track %d", track)
+		}
+		return io.NopCloser(bytes.NewReader(audio)), nil
+	}
+}
+
+func verify(t *testing.T, ctx context.Context, tracks map[int][]byte, progress func(track, tracks int)) (*Report, error) {
+	t.Helper()
+	fetcher := NewHTTPFetcher(testServer(t).URL + "/accuraterip")
+	return Verify(ctx, testTOC(), fetcher, opener(tracks), progress)
+}
+
+func TestVerifyAccurateDisc(t *testing.T) {
+	var progress []int
+	report, err := verify(t, context.Background(), testTracks(), func(track, tracks int) {
+		if tracks != 3 {
+			t.Errorf("progress of %d tracks, want 3", tracks)
+		}
+		progress = append(progress, track)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(progress, []int{1, 2, 3}) {
+		t.Fatalf("progress = %v", progress)
+	}
+	want := []TrackResult{
+		{Track: 1, Status: StatusAccurate, V1: "0139f9fe", V2: "0139f9fe", Confidence: 5},
+		// matching the v1 checksum of a pressing and the v2 one of another
+		{Track: 2, Status: StatusAccurate, V1: "ff5724d0", V2: "ffffeda0", Confidence: 7},
+		{Track: 3, Status: StatusAccurate, V1: "020fa738", V2: "020fa738", Confidence: 3},
+	}
+	if !slices.Equal(report.Tracks, want) {
+		t.Fatalf("tracks = %+v, want %+v", report.Tracks, want)
+	}
+	if report.FreedbID != "0a00b903" || report.Pressings != 2 || !report.Accurate() {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestVerifyScratchedDisc(t *testing.T) {
+	tracks := testTracks()
+	// a misread sample in the middle of track 2, and track 3 unreadable
+	tracks[2][4*1000] ^= 0x01
+	delete(tracks, 3)
+	report, err := verify(t, context.Background(), tracks, func(int, int) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Tracks[0].Status != StatusAccurate ||
+		report.Tracks[1].Status != StatusMismatch || report.Tracks[1].Confidence != 0 ||
+		report.Tracks[2].Status != StatusError || report.Tracks[2].Error == "" {
+		t.Fatalf("tracks = %+v", report.Tracks)
+	}
+	if report.Accurate() || report.Failed() != 2 {
+		t.Fatalf("report accurate %v with %d failed tracks", report.Accurate(), report.Failed())
+	}
+}
+
+func TestVerifyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	report, err := verify(t, ctx, testTracks(), func(track, _ int) {
+		if track == 2 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want cancelled", err)
+	}
+	if len(report.Tracks) != 1 {
+		t.Fatalf("%d tracks verified before cancelling, want 1", len(report.Tracks))
+	}
+}
+
+func TestVerifyUnknownDisc(t *testing.T) {
+	toc := testTOC()
+	toc.LeadOut++
+	fetcher := NewHTTPFetcher(testServer(t).URL + "/accuraterip")
+	if _, err := Verify(context.Background(), toc, fetcher, opener(testTracks()), func(int, int) {}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
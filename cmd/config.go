@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/accuraterip"
+	"github.com/b0bbywan/go-mpd-discplayer/importer"
+	"github.com/b0bbywan/go-mpd-discplayer/manifest"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/ripper"
+)
+
+func loadConfig() error {
+	viper.SetDefault("MPDConnection.Type", "tcp")
+	viper.SetDefault("MPDConnection.Address", "127.0.0.1:6600")
+	viper.SetDefault("MPDConnection.ReconnectWait", 30)
+	viper.SetDefault("MPDLibraryFolder", defaultMpdFolder)
+	viper.SetDefault("MPDCueSubfolder", ".disc-cuer")
+	viper.SetDefault("MPDUSBSubfolder", ".udisks")
+	viper.SetDefault("DiscSpeed", 12)
+	viper.SetDefault("DiscAutoplay", true)
+	viper.SetDefault("DiscQueueMode", string(mpdplayer.QueueReplace))
+	viper.SetDefault("DiscEjectAtEnd", false)
+	viper.SetDefault("DiscLockTray", false)
+	viper.SetDefault("DiscReadOffset", 0)
+	viper.SetDefault("Drives", make(map[string]interface{}))
+	viper.SetDefault("USBQueueMode", string(mpdplayer.QueueReplace))
+	viper.SetDefault("USBPlaylistPolicy", string(manifest.PlaylistName))
+	viper.SetDefault("USBContent.Order", string(mpdplayer.OrderDatabase))
+	viper.SetDefault("USBContent.Include", []string{})
+	viper.SetDefault("USBContent.Exclude", []string{})
+	viper.SetDefault("USBContent.SkipHidden", true)
+	viper.SetDefault("ScheduleQueueMode", string(mpdplayer.QueueReplace))
+	viper.SetDefault("RestoreQueue", false)
+	viper.SetDefault("StateDirectory", defaultStateDirectory())
+	viper.SetDefault("DiscResume.Enabled", false)
+	viper.SetDefault("DiscResume.ExpiryDays", 30)
+	viper.SetDefault("USBResume.Enabled", false)
+	viper.SetDefault("USBResume.ExpiryDays", 30)
+	viper.SetDefault("Rip.OnInsert", false)
+	viper.SetDefault("Rip.Play", true)
+	viper.SetDefault("Rip.Folder", "Rips")
+	viper.SetDefault("Rip.Format", string(ripper.FormatFLAC))
+	viper.SetDefault("Rip.OpusBitrate", 160)
+	viper.SetDefault("AccurateRip.URL", accuraterip.DefaultURL)
+	viper.SetDefault("Import.UUIDs", []string{})
+	viper.SetDefault("Import.Play", false)
+	viper.SetDefault("Import.Folder", "Imports")
+	viper.SetDefault("Import.Mode", string(importer.ModeSync))
+	viper.SetDefault("Import.Extensions", importer.DefaultExtensions)
+	viper.SetDefault("StartupAutoplay", false)
+	viper.SetDefault("SoundsLocation", filepath.Join("/usr/local/share/", AppName))
+	viper.SetDefault("AudioBackend", "pulse")
+	viper.SetDefault("PulseServer", "")
+	viper.SetDefault("MountConfig", "mpd")
+	viper.SetDefault("MountNames", make(map[string]string))
+	viper.SetDefault("Schedule", make(map[string]interface{}))
+	viper.SetDefault("ControlSocket", defaultControlSocket())
+	viper.SetDefault("HTTP.Enabled", false)
+	viper.SetDefault("HTTP.Address", "127.0.0.1:8080")
+	viper.SetDefault("HTTP.Token", "")
+	viper.SetDefault("HTTP.AllowedOrigins", []string{})
+	viper.SetDefault("MQTT.Enabled", false)
+	viper.SetDefault("MQTT.Broker", "tcp://127.0.0.1:1883")
+	viper.SetDefault("MQTT.ClientID", AppName)
+	viper.SetDefault("MQTT.Username", "")
+	viper.SetDefault("MQTT.Password", "")
+	viper.SetDefault("MQTT.TopicPrefix", AppName)
+	viper.SetDefault("MQTT.DiscoveryPrefix", "homeassistant")
+
+	// Load from configuration file, environment variables, and CLI flags
+	viper.SetConfigName("config")                       // name of config file (without extension)
+	viper.SetConfigType("yaml")                         // config file format
+	viper.AddConfigPath(filepath.Join("/etc", AppName)) // Global configuration path
+	if home, err := os.UserHomeDir(); err == nil {
+		viper.AddConfigPath(filepath.Join(home, ".config", AppName)) // User config path
+	}
+
+	// Environment variable support
+	viper.SetEnvPrefix(strings.ReplaceAll(AppName, "-", "_")) // environment variables start with MPD_PLAYER
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))    // HTTP.Address is read from MPD_DISCPLAYER_HTTP_ADDRESS
+	viper.AutomaticEnv()
+
+	return readConfig()
+}
+
+func readConfig() error {
+	if err := viper.ReadInConfig(); err != nil {
+		// File not found is acceptable, only raise errors for other issues
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return fmt.Errorf("error reading config file: %w", err)
+		}
+	}
+	return nil
+}
+
+// ControlSocketPath returns the configured control socket path, empty when
+// the control socket is disabled.
+func ControlSocketPath() (string, error) {
+	if err := loadConfig(); err != nil {
+		return "", err
+	}
+	return viper.GetString("ControlSocket"), nil
+}
+
+func defaultControlSocket() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = os.TempDir()
+	}
+	return filepath.Join(runtimeDir, AppName+".sock")
+}
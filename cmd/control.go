@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/control"
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+)
+
+func (p *Player) startControlServer() {
+	if p.controlSocket == "" {
+		log.Println("Control socket disabled")
+		return
+	}
+	server, err := control.NewServer(p.controlSocket, p)
+	if err != nil {
+		log.Printf("Control socket disabled: %v", err)
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		server.Serve(p.ctx)
+	}()
+}
+
+func (p *Player) startHTTPServer() {
+	if p.httpConfig == nil {
+		return
+	}
+	server, err := control.NewHTTPServer(p.httpConfig, p, p.Events)
+	if err != nil {
+		log.Printf("HTTP API disabled: %v", err)
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if err := server.Serve(p.ctx); err != nil {
+			log.Printf("HTTP API disabled: %v", err)
+		}
+	}()
+}
+
+func (p *Player) startMQTTBridge() {
+	if p.mqttConfig == nil {
+		return
+	}
+	bridge := control.NewMQTTBridge(p.mqttConfig, p, p.Events)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if err := bridge.Serve(p.ctx); err != nil {
+			log.Printf("MQTT disabled: %v", err)
+		}
+	}()
+}
+
+func (p *Player) Status() (*control.Status, error) {
+	status, err := p.Client.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playback status: %w", err)
+	}
+	return &control.Status{
+		State:       status.State,
+		File:        status.File,
+		Artist:      status.Artist,
+		Album:       status.Album,
+		Title:       status.Title,
+		Song:        status.Song,
+		Elapsed:     status.Elapsed,
+		QueueLength: status.QueueLength,
+		Devices:     p.Devices(),
+	}, nil
+}
+
+func (p *Player) Devices() []control.DeviceInfo {
+	devices := []control.DeviceInfo{}
+	for _, dev := range p.devices.List() {
+		devices = append(devices, control.DeviceInfo{
+			Path: dev.Path(),
+			Kind: string(dev.Kind()),
+		})
+	}
+	return devices
+}
+
+func (p *Player) Mounts() []control.MountInfo {
+	mounts := []control.MountInfo{}
+	for device, path := range p.Mounter.Mounts() {
+		mounts = append(mounts, control.MountInfo{Device: device, Path: path})
+	}
+	sort.Slice(mounts, func(i, j int) bool {
+		return mounts[i].Device < mounts[j].Device
+	})
+	return mounts
+}
+
+func (p *Player) Schedules() []control.ScheduleInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.scheduler.List()
+}
+
+// Play starts the playback of a device, the first disc by default.
+func (p *Player) Play(device, mode string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dev, err := p.resolveDevice(device)
+	if err != nil {
+		return err
+	}
+	if dev == nil {
+		queueMode, err := queueModeOr(mode, p.drives.get(device).QueueMode)
+		if err != nil {
+			return err
+		}
+		return p.playDisc(device, queueMode)
+	}
+	return p.playDevice(dev, mode)
+}
+
+// playDevice starts the playback of a present device, ignoring the drive
+// autoplay setting and the autoplay of manifests. USB drives and data discs
+// are mounted if needed.
+func (p *Player) playDevice(dev detect.Device, mode string) error {
+	fallback := p.usbQueueMode
+	switch dev.Kind() {
+	case detect.DeviceDisc:
+		queueMode, err := queueModeOr(mode, p.drives.get(dev.Path()).QueueMode)
+		if err != nil {
+			return err
+		}
+		return p.playDisc(dev.Path(), queueMode)
+	case detect.DeviceDataDisc:
+		fallback = p.drives.get(dev.Path()).QueueMode
+	}
+	queueMode, err := queueModeOr(mode, fallback)
+	if err != nil {
+		return err
+	}
+	relPath, err := p.Mounter.RelPath(dev.Path())
+	if err != nil {
+		if relPath, err = p.Mounter.Mount(dev.Udev()); err != nil {
+			return fmt.Errorf("failed to mount %s: %w", dev.Path(), err)
+		}
+	}
+	m, err := p.readManifest(dev)
+	if err != nil {
+		return err
+	}
+	return p.playMounted(dev, relPath, queueMode, m)
+}
+
+// Stop stops the playback, and removes the tracks of device from the queue
+// when given.
+func (p *Player) Stop(device string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if device == "" {
+		return p.Client.Stop()
+	}
+	dev, err := p.findDevice(device)
+	if err != nil {
+		return p.Client.StopDiscPlayback(device)
+	}
+	if dev.Kind() == detect.DeviceDisc {
+		p.saveDiscPosition(dev.Path())
+		return p.Client.StopDiscPlayback(dev.Path())
+	}
+	relPath, err := p.Mounter.RelPath(dev.Path())
+	if err != nil {
+		return fmt.Errorf("failed to find %s in MPD library: %w", dev.Path(), err)
+	}
+	p.saveUSBPosition(dev, relPath)
+	return p.Client.StopPlayback(relPath)
+}
+
+// Eject runs the removal of a device, the first disc by default: its
+// tracks are removed from the queue, USB drives and data discs are
+// unmounted, then the tray of discs is opened, empty drives included.
+func (p *Player) Eject(device string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dev, err := p.resolveDevice(device)
+	if err != nil {
+		return err
+	}
+	if dev == nil {
+		if err := p.Client.StopDiscPlayback(device); err != nil {
+			return err
+		}
+		return p.openTray(device)
+	}
+	return p.eject(dev)
+}
+
+// Rescan looks for devices present but unknown to the player, and handles
+// them as if they had just been inserted.
+func (p *Player) Rescan() error {
+	scanner, ok := p.source.(detect.Scanner)
+	if !ok {
+		return fmt.Errorf("event source does not support rescan")
+	}
+	events, err := scanner.Scan()
+	if err != nil {
+		return fmt.Errorf("failed to scan devices: %w", err)
+	}
+	for _, ev := range events {
+		if _, known := p.devices.Get(ev.Device.Path()); known {
+			continue
+		}
+		log.Printf("[control] Found new %s device %s", ev.Device.Kind(), ev.Device.Path())
+		p.dispatch(ev)
+	}
+	return nil
+}
+
+// Reload re-reads the configuration file and applies the settings that do
+// not require a restart: drive settings, USB queue mode, playlist policy and
+// content settings, and schedules.
+func (p *Player) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := readConfig(); err != nil {
+		return err
+	}
+	p.drives = newDriveConfigs()
+	p.usbQueueMode = parseQueueMode(viper.GetString("USBQueueMode"), mpdplayer.QueueReplace)
+	p.usbPlaylists = parsePlaylistPolicy(viper.GetString("USBPlaylistPolicy"))
+	p.usbContent = newUSBContent()
+
+	p.scheduler.Close()
+	p.scheduler = newScheduler(newSchedulerUris(p.playScheduled, viper.GetStringMap("Schedule"), scheduleQueueMode()))
+	p.StartScheduler()
+	log.Println("Configuration reloaded")
+	return nil
+}
+
+// Trigger plays uri as a schedule would.
+func (p *Player) Trigger(uri, mode string) error {
+	queueMode, err := queueModeOr(mode, scheduleQueueMode())
+	if err != nil {
+		return err
+	}
+	return p.playScheduled("", uri, queueMode)
+}
+
+// resolveDevice returns the present device at path, the first disc if path
+// is empty. Unknown devices are assumed to be disc drives, e.g. empty ones,
+// and returned nil.
+func (p *Player) resolveDevice(path string) (detect.Device, error) {
+	dev, err := p.findDevice(path)
+	if path != "" && errors.Is(err, control.ErrUnknownDevice) {
+		log.Printf("[control] %v, trying it as a disc drive", err)
+		return nil, nil
+	}
+	return dev, err
+}
+
+// audioDisc returns the drive of an audio disc, the first one if path is
+// empty, as resolved by resolveDevice.
+func (p *Player) audioDisc(path string) (string, error) {
+	dev, err := p.resolveDevice(path)
+	if err != nil {
+		return "", err
+	}
+	if dev == nil {
+		return path, nil
+	}
+	if dev.Kind() != detect.DeviceDisc {
+		return "", fmt.Errorf("%s is not an audio disc", dev.Path())
+	}
+	return dev.Path(), nil
+}
+
+// findDevice returns the present device at path, the first disc if path is
+// empty, audio discs coming before data discs.
+func (p *Player) findDevice(path string) (detect.Device, error) {
+	if path == "" {
+		for _, kind := range []detect.DeviceKind{detect.DeviceDisc, detect.DeviceDataDisc} {
+			if dev, ok := p.devices.First(kind); ok {
+				return dev, nil
+			}
+		}
+		return nil, fmt.Errorf("no disc present")
+	}
+	if dev, ok := p.devices.Get(path); ok {
+		return dev, nil
+	}
+	return nil, fmt.Errorf("%w %s", control.ErrUnknownDevice, path)
+}
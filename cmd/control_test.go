@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+)
+
+func TestAudioDisc(t *testing.T) {
+	r := startReplay(t, "usb_stick.yaml", nil)
+	r.server.AddNeighbor("udisks://by-uuid-1234-ABCD", "MUSIC")
+	r.server.AddToDatabase("MUSIC/01 Intro.flac")
+	r.step(t, detect.DeviceAdded)
+
+	if _, err := r.audioDisc(""); err == nil {
+		t.Error("found a disc without any present")
+	}
+	if _, err := r.audioDisc("/dev/sda1"); err == nil {
+		t.Error("took a USB stick for an audio disc")
+	}
+	// an empty drive is unknown to the player
+	if drive, err := r.audioDisc("/dev/sr1"); err != nil || drive != "/dev/sr1" {
+		t.Errorf("audioDisc(/dev/sr1) = %q, %v, want the drive", drive, err)
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/b0bbywan/go-mpd-discplayer/control"
+)
+
+// RunCtl sends a control command to the running daemon and prints its result.
+// A --mode <mode> option overrides the queue mode of play and trigger.
+func RunCtl(args []string) error {
+	req, err := parseCtlArgs(args)
+	if err != nil {
+		return err
+	}
+	path, err := ControlSocketPath()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("%w: control socket disabled", control.ErrUnavailable)
+	}
+
+	data, err := control.Call(path, req)
+	if err != nil {
+		return err
+	}
+	return printCtlResult(req.Command, data)
+}
+
+// ExecuteRemoteAction asks the running daemon to play, stop or eject a device.
+func ExecuteRemoteAction(device, action, mode string) error {
+	path, err := ControlSocketPath()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("%w: control socket disabled", control.ErrUnavailable)
+	}
+	_, err = control.Call(path, control.Request{Command: action, Args: []string{device}, Mode: mode})
+	return err
+}
+
+func parseCtlArgs(args []string) (control.Request, error) {
+	var req control.Request
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--mode" || arg == "-mode":
+			if i+1 >= len(args) {
+				return req, fmt.Errorf("--mode requires a value")
+			}
+			i++
+			req.Mode = args[i]
+		case strings.HasPrefix(arg, "--mode="):
+			req.Mode = strings.TrimPrefix(arg, "--mode=")
+		case req.Command == "":
+			req.Command = arg
+		default:
+			req.Args = append(req.Args, arg)
+		}
+	}
+	if req.Command == "" {
+		return req, fmt.Errorf("missing ctl command")
+	}
+	return req, nil
+}
+
+func printCtlResult(command string, data json.RawMessage) error {
+	switch command {
+	case control.CommandStatus:
+		var status control.Status
+		if err := json.Unmarshal(data, &status); err != nil {
+			return fmt.Errorf("invalid status: %w", err)
+		}
+		printStatus(&status)
+	case control.CommandListDevices:
+		var devices []control.DeviceInfo
+		if err := json.Unmarshal(data, &devices); err != nil {
+			return fmt.Errorf("invalid device list: %w", err)
+		}
+		printDevices(devices)
+	case control.CommandVerify:
+		var report control.VerifyReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return fmt.Errorf("invalid verification report: %w", err)
+		}
+		printVerifyReport(&report)
+	default:
+		fmt.Println("OK")
+	}
+	return nil
+}
+
+func printStatus(status *control.Status) {
+	fmt.Printf("state: %s\n", status.State)
+	if status.File != "" {
+		fmt.Printf("song: %d/%d %s\n", status.Song+1, status.QueueLength, status.File)
+	}
+	if status.Title != "" {
+		fmt.Printf("title: %s - %s (%s)\n", status.Artist, status.Title, status.Album)
+	}
+	if status.State != "stop" {
+		fmt.Printf("elapsed: %.0fs\n", status.Elapsed)
+	}
+	fmt.Println("devices:")
+	printDevices(status.Devices)
+}
+
+func printDevices(devices []control.DeviceInfo) {
+	if len(devices) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, dev := range devices {
+		fmt.Printf("  %s\t%s\n", dev.Path, dev.Kind)
+	}
+}
+
+func printVerifyReport(report *control.VerifyReport) {
+	fmt.Printf("disc: %s %s (%d pressings)\n", report.Device, report.FreedbID, report.Pressings)
+	failed := 0
+	for _, track := range report.Tracks {
+		switch track.Status {
+		case "accurate":
+			fmt.Printf("  track %2d: %s (confidence %d)\n", track.Track, track.Status, track.Confidence)
+			continue
+		case "mismatch":
+			fmt.Printf("  track %2d: %s (v1 %s, v2 %s)\n", track.Track, track.Status, track.V1, track.V2)
+		default:
+			fmt.Printf("  track %2d: %s: %s\n", track.Track, track.Status, track.Error)
+		}
+		failed++
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d tracks not accurate\n", failed, len(report.Tracks))
+		return
+	}
+	fmt.Printf("all %d tracks accurate\n", len(report.Tracks))
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+)
+
+// deviceRegistry tracks the devices currently present, as seen by the dispatcher.
+type deviceRegistry struct {
+	devices map[string]detect.Device
+	// ejected are the devices removed by the player, whose removal udev has
+	// yet to report
+	ejected map[string]bool
+	mu      sync.RWMutex
+}
+
+func newDeviceRegistry() *deviceRegistry {
+	return &deviceRegistry{
+		devices: make(map[string]detect.Device),
+		ejected: make(map[string]bool),
+	}
+}
+
+func (r *deviceRegistry) Update(ev detect.DeviceEvent) {
+	switch ev.Type {
+	case detect.DeviceAdded:
+		r.Add(ev.Device)
+	case detect.DeviceRemoved:
+		r.Remove(ev.Device.Path())
+	}
+}
+
+// Eject removes the device at path, its next removal being the one the
+// player made.
+func (r *deviceRegistry) Eject(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.devices, path)
+	r.ejected[path] = true
+}
+
+// Ejected reports whether ev is the removal of a device the player removed
+// already, forgetting it.
+func (r *deviceRegistry) Ejected(ev detect.DeviceEvent) bool {
+	if ev.Type != detect.DeviceRemoved {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.ejected[ev.Device.Path()] {
+		return false
+	}
+	delete(r.ejected, ev.Device.Path())
+	return true
+}
+
+// Resolve returns ev with the device registered at its path for removals, as
+// an optical drive ejecting a data disc may not report its filesystem anymore.
+func (r *deviceRegistry) Resolve(ev detect.DeviceEvent) detect.DeviceEvent {
+	if ev.Type != detect.DeviceRemoved {
+		return ev
+	}
+	if dev, ok := r.Get(ev.Device.Path()); ok && dev.Kind() != ev.Device.Kind() {
+		ev.Device = dev
+	}
+	return ev
+}
+
+func (r *deviceRegistry) Add(dev detect.Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices[dev.Path()] = dev
+	delete(r.ejected, dev.Path())
+}
+
+func (r *deviceRegistry) Remove(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.devices, path)
+}
+
+func (r *deviceRegistry) Get(path string) (detect.Device, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	dev, ok := r.devices[path]
+	return dev, ok
+}
+
+// List returns the present devices sorted by path.
+func (r *deviceRegistry) List() []detect.Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	devices := make([]detect.Device, 0, len(r.devices))
+	for _, dev := range r.devices {
+		devices = append(devices, dev)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Path() < devices[j].Path()
+	})
+	return devices
+}
+
+// First returns the first present device of the given kind.
+func (r *deviceRegistry) First(kind detect.DeviceKind) (detect.Device, bool) {
+	for _, dev := range r.List() {
+		if dev.Kind() == kind {
+			return dev, true
+		}
+	}
+	return nil, false
+}
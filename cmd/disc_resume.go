@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/state"
+)
+
+// discResume remembers where the playback of each disc stood when it was
+// removed, so it resumes there on its next insertion.
+type discResume struct {
+	store *state.Store
+	// expiry is how long a position is kept, forever when zero
+	expiry time.Duration
+	// ids are the disc IDs of the drives, read when their disc is played
+	ids map[string]string
+}
+
+// discPositions are the saved positions by disc ID.
+type discPositions map[string]mpdplayer.DiscPosition
+
+// newDiscResume returns the disc positions keeper, nil when disabled.
+func newDiscResume() *discResume {
+	if !viper.GetBool("DiscResume.Enabled") {
+		return nil
+	}
+	return &discResume{
+		store:  newStateStore("discs.json"),
+		expiry: time.Duration(viper.GetInt("DiscResume.ExpiryDays")) * 24 * time.Hour,
+		ids:    make(map[string]string),
+	}
+}
+
+// identify reads the ID of the disc in device, once per insertion.
+func (d *discResume) identify(device string) string {
+	if id, ok := d.ids[device]; ok {
+		return id
+	}
+	id, err := mpdplayer.DiscID(device)
+	if err != nil || id == "" {
+		log.Printf("Failed to identify disc in %s, it won't be resumed: %v", device, err)
+		return ""
+	}
+	d.ids[device] = id
+	return id
+}
+
+// forgetDisc drops the ID of the disc removed from device.
+func (p *Player) forgetDisc(device string) {
+	if p.discResume != nil {
+		delete(p.discResume.ids, device)
+	}
+}
+
+func (d *discResume) load() discPositions {
+	positions := make(discPositions)
+	if err := d.store.Load(&positions); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	for id, position := range positions {
+		if expired(position.Time, d.expiry) {
+			delete(positions, id)
+		}
+	}
+	return positions
+}
+
+// expired reports whether a position saved at t is older than expiry,
+// positions never expiring when it is zero.
+func expired(t time.Time, expiry time.Duration) bool {
+	return expiry > 0 && time.Since(t) > expiry
+}
+
+// resumeDisc seeks the disc in device back to where it was last removed.
+func (p *Player) resumeDisc(device string) {
+	if p.discResume == nil {
+		return
+	}
+	id := p.discResume.identify(device)
+	if id == "" {
+		return
+	}
+	position, ok := p.discResume.load()[id]
+	if !ok {
+		return
+	}
+	if _, err := p.Client.ResumeDisc(device, &position); err != nil {
+		log.Printf("Failed to resume disc %s in %s: %v", id, device, err)
+	}
+}
+
+// saveDiscPosition records where the playback of the disc in device stands,
+// before its tracks leave the queue. A disc which is not the current song
+// anymore, e.g. played to its end, restarts from its first track.
+func (p *Player) saveDiscPosition(device string) {
+	if p.discResume == nil {
+		return
+	}
+	id, ok := p.discResume.ids[device]
+	if !ok {
+		return
+	}
+	position, err := p.Client.DiscPosition(device)
+	if err != nil {
+		log.Printf("Failed to save disc %s position: %v", id, err)
+		return
+	}
+	positions := p.discResume.load()
+	if position == nil {
+		delete(positions, id)
+	} else {
+		positions[id] = *position
+		log.Printf("Saved disc %s position: track %d, %.0fs", id, position.Track, position.Elapsed)
+	}
+	if err := p.discResume.store.Save(positions); err != nil {
+		log.Printf("Failed to save disc %s position: %v", id, err)
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+)
+
+// defaultDrive is used when acting on MPD directly without a device.
+const defaultDrive = "/dev/sr0"
+
+// driveSettings is the policy applied to an optical drive.
+type driveSettings struct {
+	Speed     int
+	Autoplay  bool
+	QueueMode mpdplayer.QueueMode
+	// EjectAtEnd opens the tray once the disc is played to its end
+	EjectAtEnd bool
+	// LockTray locks the tray while the disc is playing
+	LockTray bool
+	// ReadOffset is the read offset of the drive, in samples
+	ReadOffset int
+}
+
+// driveConfig is the per drive configuration, unset fields default to the
+// global settings.
+type driveConfig struct {
+	Speed      *int
+	Autoplay   *bool
+	QueueMode  *string
+	EjectAtEnd *bool
+	LockTray   *bool
+	ReadOffset *int
+}
+
+type driveConfigs struct {
+	defaults driveSettings
+	drives   map[string]driveSettings
+}
+
+// newDriveConfigs reads the global disc settings and the Drives overrides.
+func newDriveConfigs() *driveConfigs {
+	d := &driveConfigs{
+		defaults: driveSettings{
+			Speed:      viper.GetInt("DiscSpeed"),
+			Autoplay:   viper.GetBool("DiscAutoplay"),
+			QueueMode:  parseQueueMode(viper.GetString("DiscQueueMode"), mpdplayer.QueueReplace),
+			EjectAtEnd: viper.GetBool("DiscEjectAtEnd"),
+			LockTray:   viper.GetBool("DiscLockTray"),
+			ReadOffset: viper.GetInt("DiscReadOffset"),
+		},
+		drives: make(map[string]driveSettings),
+	}
+
+	var configs map[string]driveConfig
+	if err := viper.UnmarshalKey("Drives", &configs); err != nil {
+		log.Printf("Invalid Drives configuration, using defaults: %v", err)
+		return d
+	}
+	for device, config := range configs {
+		settings := d.defaults
+		if config.Speed != nil {
+			settings.Speed = *config.Speed
+		}
+		if config.Autoplay != nil {
+			settings.Autoplay = *config.Autoplay
+		}
+		if config.QueueMode != nil {
+			settings.QueueMode = parseQueueMode(*config.QueueMode, d.defaults.QueueMode)
+		}
+		if config.EjectAtEnd != nil {
+			settings.EjectAtEnd = *config.EjectAtEnd
+		}
+		if config.LockTray != nil {
+			settings.LockTray = *config.LockTray
+		}
+		if config.ReadOffset != nil {
+			settings.ReadOffset = *config.ReadOffset
+		}
+		d.drives[device] = settings
+		log.Printf("Drive %s: speed=%d autoplay=%t queue=%s eject=%t lock=%t offset=%d", device, settings.Speed, settings.Autoplay, settings.QueueMode, settings.EjectAtEnd, settings.LockTray, settings.ReadOffset)
+	}
+	return d
+}
+
+// get returns the settings of device, the global ones if not configured.
+func (d *driveConfigs) get(device string) driveSettings {
+	if settings, ok := d.drives[device]; ok {
+		return settings
+	}
+	return d.defaults
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol"
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+)
+
+// discCheckInterval is how often the playback of discs is checked, to eject
+// them at their end and lock their tray.
+const discCheckInterval = 2 * time.Second
+
+// eject runs and publishes the removal of dev, as if it had been pulled,
+// then opens the tray of discs once their tracks left the queue, so MPD is
+// not reading them anymore. The removal udev reports afterwards is skipped.
+func (p *Player) eject(dev detect.Device) error {
+	ev := detect.DeviceEvent{Type: detect.DeviceRemoved, Device: dev}
+	p.devices.Eject(dev.Path())
+	p.publishDeviceEvent(ev)
+	if err := p.handleEvent(ev); err != nil {
+		return err
+	}
+	if dev.Kind() == detect.DeviceUSB {
+		return nil
+	}
+	if err := p.openTray(dev.Path()); err != nil {
+		return fmt.Errorf("[%s] Error ejecting %s: %w", dev.Kind(), dev.Path(), err)
+	}
+	log.Printf("[%s] Ejected %s", dev.Kind(), dev.Path())
+	return nil
+}
+
+// openTray unlocks the tray of device and opens it.
+func (p *Player) openTray(device string) error {
+	delete(p.lockedTrays, device)
+	return hwcontrol.EjectDisc(device)
+}
+
+// watchDiscs ejects the discs played to their end and locks the tray of the
+// discs playing, on the drives set to, and rips the discs waiting for their
+// playback to end. Trays are unlocked when the player
+// stops, the lock outliving the process otherwise.
+func (p *Player) watchDiscs() {
+	ticker := time.NewTicker(discCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			p.unlockTrays()
+			return
+		case <-ticker.C:
+			p.checkDiscEnds()
+			p.checkTrayLocks()
+			p.checkPendingRips()
+		}
+	}
+}
+
+// discPlayback is how far the playback of a present disc went, as read
+// from MPD.
+type discPlayback struct {
+	dev      detect.Device
+	progress mpdplayer.DiscProgress
+	err      error
+}
+
+// readDiscPlayback reads the playback of the discs present in the drives
+// matching want, called with the player lock held. MPD is queried without
+// holding it, so device events and control commands are not held up by a
+// slow server.
+func (p *Player) readDiscPlayback(want func(device string) bool) []discPlayback {
+	p.mu.Lock()
+	var discs []detect.Device
+	for _, dev := range p.devices.List() {
+		if dev.Kind() == detect.DeviceDisc && want(dev.Path()) {
+			discs = append(discs, dev)
+		}
+	}
+	p.mu.Unlock()
+
+	playbacks := make([]discPlayback, 0, len(discs))
+	for _, dev := range discs {
+		progress, err := p.Client.DiscProgress(dev.Path())
+		playbacks = append(playbacks, discPlayback{dev: dev, progress: progress, err: err})
+	}
+	return playbacks
+}
+
+// checkDiscEnds ejects the discs whose last track was the current song, and
+// is not anymore while still queued: MPD played it to its end, or moved to
+// the songs queued after the disc. Discs whose tracks left the queue, e.g.
+// replaced by a USB drive, are left alone.
+func (p *Player) checkDiscEnds() {
+	playbacks := p.readDiscPlayback(func(device string) bool { return p.drives.get(device).EjectAtEnd })
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lastTracks := make(map[string]bool)
+	for _, playback := range playbacks {
+		dev := playback.dev
+		if _, present := p.devices.Get(dev.Path()); !present {
+			// removed while MPD was queried
+			continue
+		}
+		if playback.err != nil {
+			log.Printf("[%s] Failed to check the playback of %s: %v", dev.Kind(), dev.Path(), playback.err)
+			lastTracks[dev.Path()] = p.lastTracks[dev.Path()]
+			continue
+		}
+		if playback.progress == mpdplayer.DiscNotCurrent && p.lastTracks[dev.Path()] {
+			if p.rips.ripper.Running(dev.Path()) || p.rips.pending[dev.Path()] {
+				// ejected once ripped
+				lastTracks[dev.Path()] = true
+				continue
+			}
+			log.Printf("[%s] Played %s to its end", dev.Kind(), dev.Path())
+			if err := p.eject(dev); err != nil {
+				log.Printf("[dispatcher] %v", err)
+			}
+			continue
+		}
+		lastTracks[dev.Path()] = playback.progress == mpdplayer.DiscLastTrack
+	}
+	p.lastTracks = lastTracks
+}
+
+// checkTrayLocks locks the tray of the drives set to while a track of their
+// disc is the current song and not stopped, so that the eject button is not
+// honoured while MPD reads the disc. The tray is unlocked otherwise.
+func (p *Player) checkTrayLocks() {
+	playbacks := p.readDiscPlayback(func(device string) bool { return p.drives.get(device).LockTray })
+	var status *mpdplayer.PlayerStatus
+	var statusErr error
+	if len(playbacks) > 0 {
+		status, statusErr = p.Client.Status()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, dev := range p.devices.List() {
+		if dev.Kind() == detect.DeviceDisc && !p.drives.get(dev.Path()).LockTray {
+			p.lockTray(dev.Path(), false)
+		}
+	}
+	if statusErr != nil {
+		log.Printf("[%s] Failed to check the playback state: %v", detect.DeviceDisc, statusErr)
+		return
+	}
+	for _, playback := range playbacks {
+		dev := playback.dev
+		if _, present := p.devices.Get(dev.Path()); !present || !p.drives.get(dev.Path()).LockTray {
+			continue
+		}
+		if playback.err != nil {
+			log.Printf("[%s] Failed to check the playback of %s: %v", dev.Kind(), dev.Path(), playback.err)
+			continue
+		}
+		current := playback.progress == mpdplayer.DiscCurrent || playback.progress == mpdplayer.DiscLastTrack
+		p.lockTray(dev.Path(), current && status.State != "stop")
+	}
+}
+
+// lockTray locks or unlocks the tray of device, unless it already is.
+func (p *Player) lockTray(device string, lock bool) {
+	if p.lockedTrays[device] == lock {
+		return
+	}
+	if err := hwcontrol.LockDoor(device, lock); err != nil {
+		log.Printf("[%s] Error locking tray of %s: %v", detect.DeviceDisc, device, err)
+		return
+	}
+	if lock {
+		p.lockedTrays[device] = true
+		log.Printf("[%s] Locked tray of %s", detect.DeviceDisc, device)
+		return
+	}
+	delete(p.lockedTrays, device)
+	log.Printf("[%s] Unlocked tray of %s", detect.DeviceDisc, device)
+}
+
+func (p *Player) unlockTrays() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for device := range p.lockedTrays {
+		p.lockTray(device, false)
+	}
+}
+
+// releaseTray unlocks the tray of a removed disc, once its tracks left the
+// queue. The eject button pressed on a locked drive only requests the
+// removal, the tray is then opened.
+func (p *Player) releaseTray(dev detect.Device) {
+	if !p.lockedTrays[dev.Path()] {
+		return
+	}
+	if dev.Udev().PropertyValue("DISK_EJECT_REQUEST") != "1" {
+		p.lockTray(dev.Path(), false)
+		return
+	}
+	if err := p.openTray(dev.Path()); err != nil {
+		log.Printf("[%s] Error ejecting %s: %v", detect.DeviceDisc, dev.Path(), err)
+		return
+	}
+	log.Printf("[%s] Ejected %s on request", detect.DeviceDisc, dev.Path())
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol"
 	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+	"github.com/b0bbywan/go-mpd-discplayer/manifest"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
 )
 
 // Handler defines a stateless handler capable of handling one type of  device.
@@ -53,24 +55,104 @@ func NewBasicHandler(kind detect.DeviceKind,
 	}
 }
 
+// playDisc starts the playback of the disc in device.
+func (player *Player) playDisc(device string, mode mpdplayer.QueueMode) error {
+	player.saveQueue(mode)
+	if err := player.Client.StartDiscPlayback(device, mode); err != nil {
+		return fmt.Errorf("[%s] Error starting %s playback: %w", detect.DeviceDisc, device, err)
+	}
+	player.resumeDisc(device)
+	return nil
+}
+
+// playMounted starts the playback of a USB drive or data disc mounted at
+// relPath in the MPD library, as told by its manifest and where it was last
+// removed.
+func (player *Player) playMounted(dev detect.Device, relPath string, mode mpdplayer.QueueMode, m *manifest.Manifest) error {
+	player.saveQueue(mode)
+	if err := player.Client.StartUSBPlayback(relPath, mode, player.usbOptions(dev, m)); err != nil {
+		return fmt.Errorf("[%s] Error starting %s:%s playback: %w", dev.Kind(), dev.Path(), relPath, err)
+	}
+	return nil
+}
+
+// addMounted mounts a USB drive or data disc in the MPD library, and plays it
+// unless autoplay is disabled by the configuration or its manifest.
+func (player *Player) addMounted(dev detect.Device, mode mpdplayer.QueueMode, autoplay bool) error {
+	relPath, err := player.Mounter.Mount(dev.Udev())
+	if err != nil {
+		return fmt.Errorf("[%s] Error getting mount point for %s: %w", dev.Kind(), dev.Path(), err)
+	}
+	if !autoplay {
+		log.Printf("[%s] Autoplay disabled on %s", dev.Kind(), dev.Path())
+		return nil
+	}
+	m, err := player.readManifest(dev)
+	if err != nil {
+		return err
+	}
+	if m != nil && !m.AutoplayEnabled() {
+		log.Printf("[%s] Autoplay disabled by %s manifest", dev.Kind(), dev.Path())
+		return nil
+	}
+	return player.playMounted(dev, relPath, mode, m)
+}
+
+// removeMounted removes the files of a USB drive or data disc from the queue,
+// and unmounts it from the MPD library.
+func (player *Player) removeMounted(dev detect.Device) error {
+	relPath, err := player.Mounter.Unmount(dev.Udev())
+	if err != nil {
+		return fmt.Errorf("[%s] Error getting mount point for %s: %w", dev.Kind(), dev.Path(), err)
+	}
+	player.saveUSBPosition(dev, relPath)
+	if err = player.Client.StopPlayback(relPath); err != nil {
+		return fmt.Errorf("[%s] Error stopping %s playback: %w", dev.Kind(), dev.Path(), err)
+	}
+	player.restoreQueue()
+	return nil
+}
+
 func (player *Player) newDiscHandler() {
 	discHandler := NewBasicHandler(
 		detect.DeviceDisc,
 		// processAdd
 		func(ctx context.Context, dev detect.Device) error {
-			if err := hwcontrol.SetDiscSpeed(dev.Path(), player.discSpeed); err != nil {
+			drive := player.drives.get(dev.Path())
+			if err := hwcontrol.SetDiscSpeed(dev.Path(), drive.Speed); err != nil {
 				log.Printf("[%s] Error setting disc speed on %s: %v", detect.DeviceDisc, dev.Path(), err)
 			}
-			if err := player.Client.StartDiscPlayback(dev.Path()); err != nil {
-				return fmt.Errorf("[%s] Error starting %s playback: %w", detect.DeviceDisc, dev.Path(), err)
+			player.mountDataSession(dev)
+			if player.rips.onInsert && !player.rips.play {
+				return player.startRip(dev.Path())
+			}
+			if !drive.Autoplay {
+				log.Printf("[%s] Autoplay disabled on %s", detect.DeviceDisc, dev.Path())
+				if player.rips.onInsert {
+					return player.startRip(dev.Path())
+				}
+				return nil
+			}
+			if err := player.playDisc(dev.Path(), drive.QueueMode); err != nil {
+				return err
+			}
+			if player.rips.onInsert {
+				player.ripAfterPlayback(dev.Path())
 			}
 			return nil
 		},
 		// processRemove
 		func(ctx context.Context, dev detect.Device) error {
-			if err := player.Client.StopDiscPlayback(); err != nil {
+			player.cancelRip(dev.Path())
+			player.verifier.cancel(dev.Path())
+			player.saveDiscPosition(dev.Path())
+			player.forgetDisc(dev.Path())
+			if err := player.Client.StopDiscPlayback(dev.Path()); err != nil {
 				return fmt.Errorf("[%s] Error stopping %s playback: %w", detect.DeviceDisc, dev.Path(), err)
 			}
+			player.unmountDataSession(dev)
+			player.releaseTray(dev)
+			player.restoreQueue()
 			return nil
 		},
 	)
@@ -83,27 +165,66 @@ func (player *Player) newUSBHandler() {
 		detect.DeviceUSB,
 		// processAdd
 		func(ctx context.Context, dev detect.Device) error {
-			relPath, err := player.Mounter.Mount(dev.Udev())
-			if err != nil {
-				return fmt.Errorf("[%s] Error getting mount point for %s: %w", detect.DeviceUSB, dev.Path(), err)
+			if !player.imports.onInsert(dev) {
+				return player.addMounted(dev, player.usbQueueMode, true)
 			}
-			if err = player.Client.StartUSBPlayback(relPath); err != nil {
-				return fmt.Errorf("[%s] Error starting %s:%s USB playback: %w", detect.DeviceUSB, dev.Path(), relPath, err)
+			if err := player.addMounted(dev, player.usbQueueMode, player.imports.play); err != nil {
+				return err
 			}
-			return nil
+			return player.startImport(dev)
 		},
 		// processRemove
 		func(ctx context.Context, dev detect.Device) error {
-			relPath, err := player.Mounter.Unmount(dev.Udev())
-			if err != nil {
-				return fmt.Errorf("[%s] Error getting mount point for %s: %w", detect.DeviceUSB, dev.Path(), err)
-			}
-			if err = player.Client.StopPlayback(relPath); err != nil {
-				return fmt.Errorf("[%s] Error stopping %s USB playback: %w", detect.DeviceUSB, dev.Path(), err)
-			}
-			return nil
+			player.cancelImport(dev.Path())
+			return player.removeMounted(dev)
 		},
 	)
 
 	player.handlers = append(player.handlers, usbHandler)
 }
+
+// newDataDiscHandler handles discs holding files, played like USB drives with
+// the settings of their drive.
+func (player *Player) newDataDiscHandler() {
+	dataDiscHandler := NewBasicHandler(
+		detect.DeviceDataDisc,
+		// processAdd
+		func(ctx context.Context, dev detect.Device) error {
+			drive := player.drives.get(dev.Path())
+			if err := hwcontrol.SetDiscSpeed(dev.Path(), drive.Speed); err != nil {
+				log.Printf("[%s] Error setting disc speed on %s: %v", detect.DeviceDataDisc, dev.Path(), err)
+			}
+			return player.addMounted(dev, drive.QueueMode, drive.Autoplay)
+		},
+		// processRemove
+		func(ctx context.Context, dev detect.Device) error {
+			return player.removeMounted(dev)
+		},
+	)
+
+	player.handlers = append(player.handlers, dataDiscHandler)
+}
+
+// mountDataSession exposes the data session of a mixed mode disc in the MPD
+// library, without playing it.
+func (player *Player) mountDataSession(dev detect.Device) {
+	if dev.Udev().PropertyValue("ID_FS_TYPE") == "" {
+		return
+	}
+	relPath, err := player.Mounter.Mount(dev.Udev())
+	if err != nil {
+		log.Printf("[%s] Error mounting data session of %s: %v", detect.DeviceDisc, dev.Path(), err)
+		return
+	}
+	log.Printf("[%s] Data session of %s mounted at %s", detect.DeviceDisc, dev.Path(), relPath)
+}
+
+// unmountDataSession unmounts the data session of a mixed mode disc, if any.
+func (player *Player) unmountDataSession(dev detect.Device) {
+	if _, err := player.Mounter.RelPath(dev.Path()); err != nil {
+		return
+	}
+	if _, err := player.Mounter.Unmount(dev.Udev()); err != nil {
+		log.Printf("[%s] Error unmounting data session of %s: %v", detect.DeviceDisc, dev.Path(), err)
+	}
+}
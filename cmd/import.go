@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/events"
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/mounts"
+	"github.com/b0bbywan/go-mpd-discplayer/importer"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/notifications"
+	"github.com/b0bbywan/go-mpd-discplayer/state"
+)
+
+// usbImports copies the audio files of USB drives into a folder of the MPD
+// library.
+type usbImports struct {
+	importer *importer.Importer
+	// uuids are the drives imported when inserted, play playing them
+	// meanwhile
+	uuids map[string]bool
+	play  bool
+	// owners are the drives the import folders belong to, by folder name
+	owners  map[string]string
+	folders *state.Store
+	mu      sync.Mutex // Protects owners
+}
+
+func newUSBImports(client *mpdplayer.ReconnectingMPDClient) *usbImports {
+	config := importer.NewConfig(
+		viper.GetString("MPDLibraryFolder"),
+		viper.GetString("Import.Folder"),
+		parseImportMode(viper.GetString("Import.Mode")),
+		viper.GetStringSlice("Import.Extensions"),
+	)
+	uuids := make(map[string]bool)
+	for _, uuid := range viper.GetStringSlice("Import.UUIDs") {
+		uuids[strings.ToLower(uuid)] = true
+	}
+	folders := newStateStore("import-folders.json")
+	owners := make(map[string]string)
+	if err := folders.Load(&owners); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	return &usbImports{
+		importer: importer.NewImporter(config, importTags(client), newStateStore("imports.json")),
+		uuids:    uuids,
+		play:     viper.GetBool("Import.Play"),
+		owners:   owners,
+		folders:  folders,
+	}
+}
+
+func parseImportMode(value string) importer.Mode {
+	mode, err := importer.ParseMode(value)
+	if err != nil {
+		log.Printf("%v, using %s", err, importer.ModeSync)
+		return importer.ModeSync
+	}
+	return mode
+}
+
+// importTags reads the tags of the songs from the MPD database.
+func importTags(client *mpdplayer.ReconnectingMPDClient) importer.TagLister {
+	return func(uri string) (map[string]importer.Tags, error) {
+		songs, err := client.SongTags(uri)
+		if err != nil {
+			return nil, err
+		}
+		tags := make(map[string]importer.Tags, len(songs))
+		for file, song := range songs {
+			tags[file] = importer.Tags{
+				Artist: song["Artist"],
+				Album:  song["Album"],
+				Disc:   song["Disc"],
+				Track:  song["Track"],
+				Title:  song["Title"],
+			}
+		}
+		return tags, nil
+	}
+}
+
+// onInsert reports whether a USB drive is imported when inserted.
+func (i *usbImports) onInsert(dev detect.Device) bool {
+	uuid := usbUUID(dev)
+	return uuid != "" && i.uuids[strings.ToLower(uuid)]
+}
+
+// folder returns the folder a USB drive is imported into, named as its
+// mounts are, and records it as the drive's. A drive gets a suffixed name
+// when its own is the folder of another drive.
+func (i *usbImports) folder(namer *mounts.MountManager, device mounts.BlockDevice) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	owner := strings.ToLower(device.PropertyValue("ID_FS_UUID"))
+	if owner == "" {
+		owner = device.Devnode()
+	}
+	name := namer.Name(device, func(name string) bool {
+		recorded, ok := i.owners[name]
+		return ok && recorded != owner
+	})
+	if i.owners[name] != owner {
+		i.owners[name] = owner
+		if err := i.folders.Save(i.owners); err != nil {
+			log.Printf("[import] %v", err)
+		}
+	}
+	return name
+}
+
+// startImport imports a mounted USB drive in the background.
+func (p *Player) startImport(dev detect.Device) error {
+	root, err := p.Mounter.MountPoint(dev.Path())
+	if err != nil {
+		return fmt.Errorf("[%s] Can't import %s: %w", dev.Kind(), dev.Path(), err)
+	}
+	uri, err := p.Mounter.RelPath(dev.Path())
+	if err != nil {
+		return fmt.Errorf("[%s] Can't import %s: %w", dev.Kind(), dev.Path(), err)
+	}
+	job := importer.Job{Device: dev.Path(), Root: root, URI: uri, Name: p.imports.folder(p.Mounter, dev.Udev())}
+	if err := p.imports.importer.Start(p.ctx, job, p.importProgress, p.importDone); err != nil {
+		return err
+	}
+	p.Events.Publish(events.Event{Type: events.ImportStarted, Device: dev.Path(), Kind: string(dev.Kind())})
+	return nil
+}
+
+func (p *Player) importProgress(progress importer.Progress) {
+	log.Printf("[import] Importing file %d/%d of %s: %s", progress.Index, progress.Files, progress.Device, progress.File)
+	p.Events.Publish(events.Event{
+		Type:   events.ImportProgress,
+		Device: progress.Device,
+		Kind:   string(detect.DeviceUSB),
+		File:   progress.File,
+		Index:  progress.Index,
+		Count:  progress.Files,
+	})
+}
+
+// importDone updates the import folder in the MPD database, so the music
+// stays in the library once the drive is pulled. The remove sound tells the
+// drive can be pulled.
+func (p *Player) importDone(job importer.Job, summary importer.Summary, err error) {
+	ev := events.Event{Device: job.Device, Kind: string(detect.DeviceUSB), URI: summary.URI, Count: summary.Copied}
+	if summary.Copied > 0 {
+		if err := p.Client.UpdateDB(summary.URI); err != nil {
+			log.Printf("[import] %v", err)
+		}
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		log.Printf("[import] Import of %s cancelled", job.Device)
+		ev.Type = events.ImportCancelled
+	case err != nil:
+		log.Printf("[import] Import of %s failed: %v", job.Device, err)
+		ev.Type = events.ImportFailed
+		ev.Error = err.Error()
+		p.NotifyEvent(notifications.EventError)
+	default:
+		ev.Type = events.ImportFinished
+		p.NotifyEvent(notifications.EventRemove)
+	}
+	p.Events.Publish(ev)
+}
+
+// cancelImport stops the import of the USB drive device, if any.
+func (p *Player) cancelImport(device string) bool {
+	if !p.imports.importer.Cancel(device) {
+		return false
+	}
+	log.Printf("[import] Cancelling import of %s", device)
+	return true
+}
+
+// findUSB returns the present USB drive at path, the first one if path is
+// empty.
+func (p *Player) findUSB(path string) (detect.Device, error) {
+	if path == "" {
+		if dev, ok := p.devices.First(detect.DeviceUSB); ok {
+			return dev, nil
+		}
+		return nil, fmt.Errorf("no USB drive present")
+	}
+	dev, err := p.findDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	if dev.Kind() != detect.DeviceUSB {
+		return nil, fmt.Errorf("%s is not a USB drive", dev.Path())
+	}
+	return dev, nil
+}
+
+// Import copies the audio files of a present USB drive, the first one by
+// default, into the MPD library. The drive is mounted if needed.
+func (p *Player) Import(device string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dev, err := p.findUSB(device)
+	if err != nil {
+		return err
+	}
+	if _, err := p.Mounter.RelPath(dev.Path()); err != nil {
+		if _, err := p.Mounter.Mount(dev.Udev()); err != nil {
+			return fmt.Errorf("failed to mount %s: %w", dev.Path(), err)
+		}
+	}
+	return p.startImport(dev)
+}
+
+// CancelImport stops the import of a USB drive, the first one by default.
+// The files already copied are kept.
+func (p *Player) CancelImport(device string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if device == "" {
+		dev, err := p.findUSB(device)
+		if err != nil {
+			return err
+		}
+		device = dev.Path()
+	}
+	if !p.cancelImport(device) {
+		return fmt.Errorf("no import running on %s", device)
+	}
+	return nil
+}
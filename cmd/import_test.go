@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/mounts"
+	"github.com/b0bbywan/go-mpd-discplayer/state"
+)
+
+// usbDrive is a USB drive from its udev properties.
+type usbDrive map[string]string
+
+func (d usbDrive) Devnode() string {
+	return d["DEVNAME"]
+}
+
+func (d usbDrive) PropertyValue(key string) string {
+	return d[key]
+}
+
+func TestImportFolders(t *testing.T) {
+	dir := t.TempDir()
+	config := mounts.NewMountConfig(t.TempDir(), ".udisks", "mpd", map[string]string{"9999-ZZZZ": "Party mix"})
+	namer, err := mounts.NewMountManager(config, nil, state.NewStore(filepath.Join(dir, "mounts.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := &usbImports{
+		owners:  make(map[string]string),
+		folders: state.NewStore(filepath.Join(dir, "import-folders.json")),
+	}
+
+	tests := []struct {
+		name  string
+		drive usbDrive
+		want  string
+	}{
+		{"label made safe", usbDrive{"DEVNAME": "/dev/sda1", "ID_FS_UUID": "1234-ABCD", "ID_FS_LABEL": "My Music/2024"}, "My_Music_2024"},
+		{"same drive again", usbDrive{"DEVNAME": "/dev/sdb1", "ID_FS_UUID": "1234-abcd", "ID_FS_LABEL": "My Music/2024"}, "My_Music_2024"},
+		{"same label", usbDrive{"DEVNAME": "/dev/sda1", "ID_FS_UUID": "5678-EF01", "ID_FS_LABEL": "My Music/2024"}, "My_Music_2024-5678ef01"},
+		{"pinned name", usbDrive{"DEVNAME": "/dev/sda1", "ID_FS_UUID": "9999-ZZZZ", "ID_FS_LABEL": "MUSIC"}, "Party_mix"},
+		{"no label", usbDrive{"DEVNAME": "/dev/sdc1", "ID_FS_UUID": "AAAA-BBBB"}, "AAAA-BBBB"},
+	}
+	for _, tt := range tests {
+		if got := imports.folder(namer, tt.drive); got != tt.want {
+			t.Errorf("%s: folder = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// the folders stay the drives' across restarts
+	var owners map[string]string
+	if err := imports.folders.Load(&owners); err != nil {
+		t.Fatal(err)
+	}
+	restarted := &usbImports{owners: owners, folders: imports.folders}
+	if got := restarted.folder(namer, tests[2].drive); got != "My_Music_2024-5678ef01" {
+		t.Fatalf("folder after restart = %s", got)
+	}
+}
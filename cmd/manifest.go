@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+	"github.com/b0bbywan/go-mpd-discplayer/manifest"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+)
+
+// parsePlaylistPolicy parses the configured playlist policy, falling back on
+// errors.
+func parsePlaylistPolicy(policy string) manifest.PlaylistPolicy {
+	playlistPolicy, err := manifest.ParsePlaylistPolicy(policy)
+	if err != nil {
+		log.Printf("%v, using %s", err, manifest.PlaylistName)
+		return manifest.PlaylistName
+	}
+	return playlistPolicy
+}
+
+// newUSBContent reads the settings selecting and sorting the files of USB
+// drives.
+func newUSBContent() *mpdplayer.USBContent {
+	order, err := mpdplayer.ParseContentOrder(viper.GetString("USBContent.Order"))
+	if err != nil {
+		log.Printf("%v, using %s", err, mpdplayer.OrderDatabase)
+		order = mpdplayer.OrderDatabase
+	}
+	return mpdplayer.NewUSBContent(
+		order,
+		viper.GetStringSlice("USBContent.Include"),
+		viper.GetStringSlice("USBContent.Exclude"),
+		viper.GetBool("USBContent.SkipHidden"),
+	)
+}
+
+// readManifest returns the autoplay manifest of a mounted USB drive or data
+// disc, nil if it has none or its files can't be read.
+func (p *Player) readManifest(dev detect.Device) (*manifest.Manifest, error) {
+	mountPoint, err := p.Mounter.MountPoint(dev.Path())
+	if err != nil {
+		log.Printf("[%s] Not looking for a manifest on %s: %v", dev.Kind(), dev.Path(), err)
+		return nil, nil
+	}
+	m, err := manifest.Load(mountPoint, p.usbPlaylists)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %s: %w", dev.Kind(), dev.Path(), err)
+	}
+	return m, nil
+}
+
+// usbOptions returns how a USB drive is played, from the content settings,
+// its manifest and where it was last removed.
+func (p *Player) usbOptions(dev detect.Device, m *manifest.Manifest) *mpdplayer.USBOptions {
+	options := &mpdplayer.USBOptions{
+		Content:  p.usbContent,
+		Position: p.usbPosition(dev),
+	}
+	if m == nil {
+		return options
+	}
+	options.Shuffle = m.Shuffle
+	options.Random = m.Random
+	options.Repeat = m.Repeat
+	options.Volume = m.Volume
+	options.Start = m.Start
+	options.Folder = m.Folder
+	options.URIs = m.Entries
+	return options
+}
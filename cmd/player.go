@@ -4,19 +4,21 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/spf13/viper"
 
 	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-mpd-discplayer/control"
+	"github.com/b0bbywan/go-mpd-discplayer/events"
 	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
 	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/mounts"
+	"github.com/b0bbywan/go-mpd-discplayer/manifest"
 	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
 	"github.com/b0bbywan/go-mpd-discplayer/notifications"
+	"github.com/b0bbywan/go-mpd-discplayer/state"
 )
 
 const (
@@ -27,49 +29,42 @@ const (
 var AppVersion = "dev"
 
 type Player struct {
-	ctx       context.Context
-	cancel    context.CancelFunc
-	wg        *sync.WaitGroup
-	discSpeed int
-	Client    *mpdplayer.ReconnectingMPDClient
-	Notifier  *notifications.Notifier
-	Mounter   *mounts.MountManager
-	scheduler *scheduler
-	handlers  []Handler
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              *sync.WaitGroup
+	drives          *driveConfigs
+	usbQueueMode    mpdplayer.QueueMode
+	usbPlaylists    manifest.PlaylistPolicy
+	usbContent      *mpdplayer.USBContent
+	queueStore      *state.Store
+	discResume      *discResume
+	usbResume       *usbResume
+	rips            *discRips
+	verifier        *discVerifier
+	imports         *usbImports
+	startupAutoplay bool
+	Client          *mpdplayer.ReconnectingMPDClient
+	Notifier        *notifications.Notifier
+	Mounter         *mounts.MountManager
+	Events          *events.Bus
+	scheduler       *scheduler
+	handlers        []Handler
+	source          detect.EventSource
+	devices         *deviceRegistry
+	controlSocket   string
+	httpConfig      *control.HTTPConfig
+	mqttConfig      *control.MQTTConfig
+	// mu serializes device event handling and control actions
+	mu sync.Mutex
+	// lastTracks are the drives whose disc was playing its last track
+	lastTracks map[string]bool
+	// lockedTrays are the drives whose tray is locked by the player
+	lockedTrays map[string]bool
 }
 
 func NewPlayer(ctx context.Context, cancel context.CancelFunc) (*Player, error) {
-	viper.SetDefault("MPDConnection.Type", "tcp")
-	viper.SetDefault("MPDConnection.Address", "127.0.0.1:6600")
-	viper.SetDefault("MPDConnection.ReconnectWait", 30)
-	viper.SetDefault("MPDLibraryFolder", defaultMpdFolder)
-	viper.SetDefault("MPDCueSubfolder", ".disc-cuer")
-	viper.SetDefault("MPDUSBSubfolder", ".udisks")
-	viper.SetDefault("DiscSpeed", 12)
-	viper.SetDefault("SoundsLocation", filepath.Join("/usr/local/share/", AppName))
-	viper.SetDefault("AudioBackend", "pulse")
-	viper.SetDefault("PulseServer", "")
-	viper.SetDefault("MountConfig", "mpd")
-	viper.SetDefault("Schedule", make(map[string]string))
-
-	// Load from configuration file, environment variables, and CLI flags
-	viper.SetConfigName("config")                       // name of config file (without extension)
-	viper.SetConfigType("yaml")                         // config file format
-	viper.AddConfigPath(filepath.Join("/etc", AppName)) // Global configuration path
-	if home, err := os.UserHomeDir(); err == nil {
-		viper.AddConfigPath(filepath.Join(home, ".config", AppName)) // User config path
-	}
-
-	// Environment variable support
-	viper.SetEnvPrefix(strings.ReplaceAll(AppName, "-", "_")) // environment variables start with MPD_PLAYER
-	viper.AutomaticEnv()
-
-	err := viper.ReadInConfig()
-	if err != nil {
-		// File not found is acceptable, only raise errors for other issues
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, fmt.Errorf("error reading config file: %w", err)
-		}
+	if err := loadConfig(); err != nil {
+		return nil, err
 	}
 	var wg sync.WaitGroup
 
@@ -100,8 +95,9 @@ func NewPlayer(ctx context.Context, cancel context.CancelFunc) (*Player, error)
 		viper.GetString("MPDLibraryFolder"),
 		viper.GetString("MPDUSBSubfolder"),
 		viper.GetString("MountConfig"),
+		viper.GetStringMapString("MountNames"),
 	)
-	mounter, err := mounts.NewMountManager(mountConfig, mpdClient)
+	mounter, err := mounts.NewMountManager(mountConfig, mpdClient, newStateStore("mounts.json"))
 	if err != nil {
 		return nil, fmt.Errorf("USB Playback disabled: Failed to create mount manager: %w", err)
 	}
@@ -113,36 +109,67 @@ func NewPlayer(ctx context.Context, cancel context.CancelFunc) (*Player, error)
 	)
 	notifier := notifications.NewNotifier(notificationConfig)
 
-	schedules := newSchedulerUris(mpdClient, notifier, viper.GetStringMapString("Schedule"))
-	scheduler := newScheduler(schedules)
-
-	return &Player{
-		ctx:       ctx,
-		cancel:    cancel,
-		wg:        &wg,
-		discSpeed: viper.GetInt("DiscSpeed"),
-		Client:    mpdClient,
-		Notifier:  notifier,
-		Mounter:   mounter,
-		scheduler: scheduler,
-	}, nil
+	p := &Player{
+		ctx:             ctx,
+		cancel:          cancel,
+		wg:              &wg,
+		drives:          newDriveConfigs(),
+		usbQueueMode:    parseQueueMode(viper.GetString("USBQueueMode"), mpdplayer.QueueReplace),
+		usbPlaylists:    parsePlaylistPolicy(viper.GetString("USBPlaylistPolicy")),
+		usbContent:      newUSBContent(),
+		queueStore:      newQueueStore(),
+		discResume:      newDiscResume(),
+		usbResume:       newUSBResume(),
+		rips:            newDiscRips(mpdClient),
+		verifier:        newDiscVerifier(),
+		imports:         newUSBImports(mpdClient),
+		startupAutoplay: viper.GetBool("StartupAutoplay"),
+		Client:          mpdClient,
+		Notifier:        notifier,
+		Mounter:         mounter,
+		Events:          events.NewBus(),
+		devices:         newDeviceRegistry(),
+		lockedTrays:     make(map[string]bool),
+		controlSocket:   viper.GetString("ControlSocket"),
+		httpConfig:      newHTTPConfig(),
+		mqttConfig:      newMQTTConfig(),
+	}
+	p.scheduler = newScheduler(newSchedulerUris(p.playScheduled, viper.GetStringMap("Schedule"), scheduleQueueMode()))
+	return p, nil
 }
 
 func (p *Player) Start() {
+	p.StartWithSource(detect.NewUdevDetector())
+}
+
+// StartWithSource starts the player with the given source of device events.
+func (p *Player) StartWithSource(source detect.EventSource) {
 	p.StartScheduler()
 
 	p.newDiscHandler()
 	p.newUSBHandler()
+	p.newDataDiscHandler()
+
+	p.source = source
+	p.reconcileMounts()
+	p.startControlServer()
+	p.startHTTPServer()
+	p.startMQTTBridge()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.watchDiscs()
+	}()
 
-	detector := detect.NewUdevDetector()
 	events := make(chan detect.DeviceEvent)
 
 	p.wg.Add(1)
 	go func() {
 		defer p.wg.Done()
 		defer close(events)
-		if err := detector.Run(p.ctx, events); err != nil {
-			log.Printf("Failed to run udev detector: %s", err)
+		if err := source.Run(p.ctx, events); err != nil {
+			log.Printf("Failed to run event source: %s", err)
 			p.cancel()
 		}
 	}()
@@ -154,6 +181,26 @@ func (p *Player) Start() {
 	}()
 }
 
+// reconcileMounts takes back the mounts made before the player restarted,
+// against the devices present. They are left as recorded when the source
+// can't list the devices present.
+func (p *Player) reconcileMounts() {
+	scanner, ok := p.source.(detect.Scanner)
+	if !ok {
+		return
+	}
+	events, err := scanner.Scan()
+	if err != nil {
+		log.Printf("Failed to reconcile mounts: failed to scan devices: %v", err)
+		return
+	}
+	present := make([]mounts.BlockDevice, 0, len(events))
+	for _, ev := range events {
+		present = append(present, ev.Device.Udev())
+	}
+	p.Mounter.Reconcile(present)
+}
+
 func (p *Player) run(events <-chan detect.DeviceEvent) {
 	for {
 		select {
@@ -171,11 +218,32 @@ func (p *Player) run(events <-chan detect.DeviceEvent) {
 }
 
 func (p *Player) dispatch(ev detect.DeviceEvent) {
-	var err error
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ev = p.devices.Resolve(ev)
+	if p.devices.Ejected(ev) {
+		log.Printf("[dispatcher] %s %s removed already, when ejected", ev.Device.Kind(), ev.Device.Path())
+		return
+	}
+	p.devices.Update(ev)
+	p.publishDeviceEvent(ev)
+	if ev.Startup && !p.startupAutoplay {
+		log.Printf("[dispatcher] %s %s present at startup, not played", ev.Device.Kind(), ev.Device.Path())
+		return
+	}
+	if err := p.handleEvent(ev); err != nil {
+		log.Printf("[dispatcher] %v", err)
+	}
+}
+
+// handleEvent runs the handlers of the event device, returning the last error.
+func (p *Player) handleEvent(ev detect.DeviceEvent) error {
+	var lastErr error
 	for _, h := range p.handlers {
 		if !h.Handles(ev.Device.Kind()) {
 			continue
 		}
+		var err error
 		switch ev.Type {
 		case detect.DeviceAdded:
 			p.NotifyEvent(notifications.EventAdd)
@@ -184,15 +252,51 @@ func (p *Player) dispatch(ev detect.DeviceEvent) {
 			p.NotifyEvent(notifications.EventRemove)
 			err = h.OnRemove(p.ctx, ev.Device)
 		}
+		p.publishOutcome(ev, err)
 		if err != nil {
 			p.NotifyEvent(notifications.EventError)
-			log.Printf("[dispatcher] Error during callback execution %s %s: %s", ev.Type, ev.Device.Kind(), err)
+			lastErr = fmt.Errorf("error during callback execution %s %s: %w", ev.Type, ev.Device.Kind(), err)
 		}
 	}
+	return lastErr
+}
+
+func (p *Player) publishDeviceEvent(ev detect.DeviceEvent) {
+	busEvent := events.Event{
+		Device: ev.Device.Path(),
+		Kind:   string(ev.Device.Kind()),
+	}
+	switch ev.Type {
+	case detect.DeviceAdded:
+		busEvent.Type = events.DeviceAdded
+	case detect.DeviceRemoved:
+		busEvent.Type = events.DeviceRemoved
+	default:
+		return
+	}
+	p.Events.Publish(busEvent)
+}
+
+// publishOutcome publishes the result of a handler run for ev.
+func (p *Player) publishOutcome(ev detect.DeviceEvent, err error) {
+	busEvent := events.Event{
+		Type:   events.HandlerSucceeded,
+		Device: ev.Device.Path(),
+		Kind:   string(ev.Device.Kind()),
+		Action: string(ev.Type),
+	}
+	if err != nil {
+		busEvent.Type = events.HandlerFailed
+		busEvent.Error = err.Error()
+	}
+	p.Events.Publish(busEvent)
 }
 
 func (p *Player) Close() {
 	p.cancel()
+	p.rips.ripper.Wait()
+	p.verifier.wait()
+	p.imports.importer.Wait()
 	if p.Client != nil {
 		p.Client.Disconnect()
 	}
@@ -211,6 +315,33 @@ func (p *Player) NotifyEvent(name string) {
 	}
 }
 
+// newHTTPConfig returns the REST API configuration, nil when it is disabled.
+func newHTTPConfig() *control.HTTPConfig {
+	if !viper.GetBool("HTTP.Enabled") {
+		return nil
+	}
+	return control.NewHTTPConfig(
+		viper.GetString("HTTP.Address"),
+		viper.GetString("HTTP.Token"),
+		viper.GetStringSlice("HTTP.AllowedOrigins"),
+	)
+}
+
+// newMQTTConfig returns the MQTT configuration, nil when MQTT is disabled.
+func newMQTTConfig() *control.MQTTConfig {
+	if !viper.GetBool("MQTT.Enabled") {
+		return nil
+	}
+	return control.NewMQTTConfig(
+		viper.GetString("MQTT.Broker"),
+		viper.GetString("MQTT.ClientID"),
+		viper.GetString("MQTT.Username"),
+		viper.GetString("MQTT.Password"),
+		viper.GetString("MQTT.TopicPrefix"),
+		viper.GetString("MQTT.DiscoveryPrefix"),
+	)
+}
+
 func setMpdFolder(mpdClient *mpdplayer.ReconnectingMPDClient) error {
 	if viper.GetString("MPDLibraryFolder") == defaultMpdFolder {
 		musicDir, err := mpdClient.GetConfig()
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+)
+
+// parseQueueMode parses a configured queue mode, falling back on errors.
+func parseQueueMode(mode string, fallback mpdplayer.QueueMode) mpdplayer.QueueMode {
+	queueMode, err := mpdplayer.ParseQueueMode(mode)
+	if err != nil {
+		log.Printf("%v, using %s", err, fallback)
+		return fallback
+	}
+	return queueMode
+}
+
+// queueModeOr parses a queue mode requested from a control interface,
+// fallback being used when none is requested.
+func queueModeOr(mode string, fallback mpdplayer.QueueMode) (mpdplayer.QueueMode, error) {
+	if mode == "" {
+		return fallback, nil
+	}
+	return mpdplayer.ParseQueueMode(mode)
+}
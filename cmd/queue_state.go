@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/state"
+)
+
+func defaultStateDirectory() string {
+	if stateHome := os.Getenv("XDG_STATE_HOME"); stateHome != "" {
+		return filepath.Join(stateHome, AppName)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", AppName)
+	}
+	return filepath.Join("/var/lib", AppName)
+}
+
+// newStateStore returns the store of name in the state directory.
+func newStateStore(name string) *state.Store {
+	return state.NewStore(filepath.Join(viper.GetString("StateDirectory"), name))
+}
+
+// newQueueStore returns the store of the queue saved before inserted media
+// replace it, nil when the queue is not restored.
+func newQueueStore() *state.Store {
+	if !viper.GetBool("RestoreQueue") {
+		return nil
+	}
+	return newStateStore("queue.json")
+}
+
+// saveQueue snapshots the queue before it is replaced by removable media.
+// A saved queue is kept if the current one only holds removable media,
+// e.g. when a USB drive replaces a disc that replaced the radio.
+func (p *Player) saveQueue(mode mpdplayer.QueueMode) {
+	if p.queueStore == nil || mode != mpdplayer.QueueReplace {
+		return
+	}
+	snapshot, err := p.Client.SnapshotQueue()
+	if err != nil {
+		log.Printf("Failed to save the queue: %v", err)
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+	var saved mpdplayer.QueueSnapshot
+	if err := p.queueStore.Load(&saved); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	if len(saved.Files) > 0 && p.onlyMedia(snapshot.Files) {
+		log.Printf("Keeping the queue saved at %s", saved.Time.Format("2006-01-02 15:04:05"))
+		return
+	}
+	if err := p.queueStore.Save(snapshot); err != nil {
+		log.Printf("Failed to save the queue: %v", err)
+		return
+	}
+	log.Printf("Saved the queue of %d files", len(snapshot.Files))
+}
+
+// restoreQueue brings the saved queue back once removed media left the
+// queue empty.
+func (p *Player) restoreQueue() {
+	if p.queueStore == nil {
+		return
+	}
+	var saved mpdplayer.QueueSnapshot
+	if err := p.queueStore.Load(&saved); err != nil {
+		log.Printf("Failed to restore the queue: %v", err)
+		return
+	}
+	if len(saved.Files) == 0 {
+		return
+	}
+	restored, err := p.Client.RestoreQueue(&saved)
+	if err != nil {
+		log.Printf("Failed to restore the queue: %v", err)
+	}
+	if !restored && err == nil {
+		// Other media is still queued, the saved queue waits for its removal
+		return
+	}
+	if err := p.queueStore.Remove(); err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+// onlyMedia reports whether all files are disc tracks or mounted USB files.
+func (p *Player) onlyMedia(files []string) bool {
+	mounts := p.Mounter.Mounts()
+	for _, file := range files {
+		if !isMediaFile(file, mounts) {
+			return false
+		}
+	}
+	return true
+}
+
+func isMediaFile(file string, mounts map[string]string) bool {
+	if strings.HasPrefix(file, mpdplayer.CDDAPathPrefix) {
+		return true
+	}
+	for _, relPath := range mounts {
+		if strings.HasPrefix(file, relPath+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-mpd-discplayer/control"
+	"github.com/b0bbywan/go-mpd-discplayer/events"
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer/mpdtest"
+)
+
+// replaySteps replays a recording one device event at a time, each released
+// by the test once it checked the outcome of the previous one.
+type replaySteps struct {
+	source *detect.ReplaySource
+	next   chan struct{}
+}
+
+func (r *replaySteps) Run(ctx context.Context, out chan<- detect.DeviceEvent) error {
+	replayed := make(chan detect.DeviceEvent)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(replayed)
+		errc <- r.source.Run(ctx, replayed)
+	}()
+	for ev := range replayed {
+		select {
+		case <-ctx.Done():
+			continue
+		case <-r.next:
+		}
+		select {
+		case <-ctx.Done():
+		case out <- ev:
+		}
+	}
+	return <-errc
+}
+
+// replayPlayer is a player fed with a recording of ../hwcontrol/detect/testdata,
+// playing to a fake MPD server.
+type replayPlayer struct {
+	*Player
+	server   *mpdtest.Server
+	steps    *replaySteps
+	outcomes <-chan events.Event
+}
+
+// startReplay starts a player configured by env, on top of the settings
+// running it against a fake MPD server mounting drives itself, in temporary
+// directories.
+func startReplay(t *testing.T, recording string, env map[string]string) *replayPlayer {
+	t.Helper()
+	server, err := mpdtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	// keep the configuration of the user running the tests out
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	settings := map[string]string{
+		"MPD_DISCPLAYER_MPDCONNECTION_TYPE":          server.Network,
+		"MPD_DISCPLAYER_MPDCONNECTION_ADDRESS":       server.Addr,
+		"MPD_DISCPLAYER_MPDCONNECTION_RECONNECTWAIT": "1",
+		"MPD_DISCPLAYER_MPDLIBRARYFOLDER":            t.TempDir(),
+		"MPD_DISCPLAYER_STATEDIRECTORY":              t.TempDir(),
+		"MPD_DISCPLAYER_MOUNTCONFIG":                 "mpd",
+		"MPD_DISCPLAYER_AUDIOBACKEND":                "none",
+	}
+	for key, value := range env {
+		settings[key] = value
+	}
+	for key, value := range settings {
+		t.Setenv(key, value)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	player, err := NewPlayer(ctx, cancel)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	outcomes, unsubscribe := player.Events.Subscribe(16)
+	steps := &replaySteps{
+		source: detect.NewReplaySource(filepath.Join("..", "hwcontrol", "detect", "testdata", recording)),
+		next:   make(chan struct{}),
+	}
+	player.StartWithSource(steps)
+	t.Cleanup(func() {
+		player.Close()
+		unsubscribe()
+	})
+	return &replayPlayer{
+		Player:   player,
+		server:   server,
+		steps:    steps,
+		outcomes: outcomes,
+	}
+}
+
+// step dispatches the next recorded event, and fails unless its handler
+// succeeded.
+func (r *replayPlayer) step(t *testing.T, want detect.EventType) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	select {
+	case r.steps.next <- struct{}{}:
+	case <-timeout:
+		t.Fatalf("no %s event left to replay", want)
+	}
+	for {
+		select {
+		case ev := <-r.outcomes:
+			switch ev.Type {
+			case events.HandlerFailed:
+				t.Fatalf("%s %s %s failed: %s", ev.Kind, ev.Device, ev.Action, ev.Error)
+			case events.HandlerSucceeded:
+				if ev.Action != string(want) {
+					t.Fatalf("%s %s %s handled, want %s", ev.Kind, ev.Device, ev.Action, want)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("%s not handled", want)
+		}
+	}
+}
+
+// release lets the next recorded event through, without waiting for its
+// dispatch.
+func (r *replayPlayer) release(t *testing.T) {
+	t.Helper()
+	select {
+	case r.steps.next <- struct{}{}:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no event left to replay")
+	}
+}
+
+// announce dispatches the next recorded event, a device present at startup
+// left alone by the handlers.
+func (r *replayPlayer) announce(t *testing.T) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	select {
+	case r.steps.next <- struct{}{}:
+	case <-timeout:
+		t.Fatal("no startup event left to replay")
+	}
+	for {
+		select {
+		case ev := <-r.outcomes:
+			switch ev.Type {
+			case events.DeviceAdded:
+				return
+			case events.HandlerFailed, events.HandlerSucceeded:
+				t.Fatalf("%s %s %s handled at startup", ev.Kind, ev.Device, ev.Action)
+			}
+		case <-timeout:
+			t.Fatal("startup device not announced")
+		}
+	}
+}
+
+func (r *replayPlayer) assertQueue(t *testing.T, want ...string) {
+	t.Helper()
+	if got := r.server.Queue(); !slices.Equal(got, want) {
+		t.Fatalf("queue = %v, want %v", got, want)
+	}
+}
+
+func (r *replayPlayer) assertMounted(t *testing.T, name string, want bool) {
+	t.Helper()
+	if _, mounted := r.server.Mounts()[name]; mounted != want {
+		t.Fatalf("%s mounted in MPD = %v, want %v: %v", name, mounted, want, r.server.Mounts())
+	}
+}
+
+func TestReplayUSBStick(t *testing.T) {
+	r := startReplay(t, "usb_stick.yaml", nil)
+	r.server.AddNeighbor("udisks://by-uuid-1234-ABCD", "MUSIC")
+	r.server.AddToDatabase("MUSIC/01 Intro.flac", "MUSIC/02 Song.flac", "radio/stream.m3u")
+	r.server.SetQueue("radio/stream.m3u")
+	r.server.Play(0)
+
+	r.step(t, detect.DeviceAdded)
+	r.assertMounted(t, "MUSIC", true)
+	r.assertQueue(t, "MUSIC/01 Intro.flac", "MUSIC/02 Song.flac")
+	if state, song := r.server.State(); state != "play" || song != 0 {
+		t.Fatalf("state = %s song %d, want the stick playing", state, song)
+	}
+
+	r.step(t, detect.DeviceRemoved)
+	r.assertMounted(t, "MUSIC", false)
+	r.assertQueue(t)
+}
+
+func TestReplayUSBStickRestoresQueue(t *testing.T) {
+	r := startReplay(t, "usb_stick.yaml", map[string]string{
+		"MPD_DISCPLAYER_USBQUEUEMODE": "replace",
+		"MPD_DISCPLAYER_RESTOREQUEUE": "true",
+	})
+	r.server.AddNeighbor("udisks://by-uuid-1234-ABCD", "MUSIC")
+	r.server.AddToDatabase("MUSIC/01 Intro.flac", "radio/morning.m3u", "radio/stream.m3u")
+	r.server.SetQueue("radio/morning.m3u", "radio/stream.m3u")
+	r.server.Play(1)
+	r.server.SetElapsed(42.5)
+
+	r.step(t, detect.DeviceAdded)
+	r.assertQueue(t, "MUSIC/01 Intro.flac")
+
+	r.step(t, detect.DeviceRemoved)
+	r.assertQueue(t, "radio/morning.m3u", "radio/stream.m3u")
+	if state, song := r.server.State(); state != "play" || song != 1 {
+		t.Fatalf("state = %s song %d, want the stream playing again", state, song)
+	}
+	if elapsed := r.server.Elapsed(); elapsed != 42.5 {
+		t.Fatalf("elapsed = %v, want 42.5", elapsed)
+	}
+}
+
+func TestReplayUSBStickEjected(t *testing.T) {
+	r := startReplay(t, "usb_stick_ejected.yaml", nil)
+	r.server.AddNeighbor("udisks://by-uuid-1234-ABCD", "MUSIC")
+	r.server.AddToDatabase("MUSIC/01 Intro.flac")
+
+	r.step(t, detect.DeviceAdded)
+	if err := r.Eject("/dev/sda1"); err != nil {
+		t.Fatal(err)
+	}
+	r.assertMounted(t, "MUSIC", false)
+	r.assertQueue(t)
+	if devices := r.Devices(); len(devices) != 0 {
+		t.Fatalf("devices = %v, want the stick gone", devices)
+	}
+	removed := false
+	for len(r.outcomes) > 0 {
+		if ev := <-r.outcomes; ev.Type == events.DeviceRemoved && ev.Device == "/dev/sda1" {
+			removed = true
+		}
+	}
+	if !removed {
+		t.Fatal("ejection not published as a removal")
+	}
+
+	// pulled out, then plugged in again
+	r.release(t)
+	r.release(t)
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case ev := <-r.outcomes:
+			if ev.Type == events.DeviceRemoved || ev.Action == string(detect.DeviceRemoved) {
+				t.Fatalf("removal of the ejected stick handled again: %+v", ev)
+			}
+			if ev.Type == events.HandlerFailed {
+				t.Fatalf("%s %s %s failed: %s", ev.Kind, ev.Device, ev.Action, ev.Error)
+			}
+			if ev.Type == events.HandlerSucceeded {
+				r.assertMounted(t, "MUSIC", true)
+				r.assertQueue(t, "MUSIC/01 Intro.flac")
+				return
+			}
+		case <-timeout:
+			t.Fatal("stick plugged in again not handled")
+		}
+	}
+}
+
+func TestReplayStartupDeviceNotPlayed(t *testing.T) {
+	r := startReplay(t, "usb_stick_present.yaml", nil)
+	r.server.AddNeighbor("udisks://by-uuid-1234-ABCD", "MUSIC")
+	r.server.AddToDatabase("MUSIC/01 Intro.flac", "radio/stream.m3u")
+	r.server.SetQueue("radio/stream.m3u")
+	r.server.Play(0)
+
+	r.announce(t)
+	want := []control.DeviceInfo{{Path: "/dev/sda1", Kind: string(detect.DeviceUSB)}}
+	if devices := r.Devices(); !slices.Equal(devices, want) {
+		t.Fatalf("devices = %v, want %v", devices, want)
+	}
+	r.assertQueue(t, "radio/stream.m3u")
+	if state, song := r.server.State(); state != "play" || song != 0 {
+		t.Fatalf("state = %s song %d, want the radio still playing", state, song)
+	}
+
+	if err := r.Play("/dev/sda1", ""); err != nil {
+		t.Fatal(err)
+	}
+	r.assertMounted(t, "MUSIC", true)
+	r.assertQueue(t, "MUSIC/01 Intro.flac")
+}
+
+func TestReplayStartupAutoplay(t *testing.T) {
+	r := startReplay(t, "usb_stick_present.yaml", map[string]string{
+		"MPD_DISCPLAYER_STARTUPAUTOPLAY": "true",
+	})
+	r.server.AddNeighbor("udisks://by-uuid-1234-ABCD", "MUSIC")
+	r.server.AddToDatabase("MUSIC/01 Intro.flac")
+
+	r.step(t, detect.DeviceAdded)
+	r.assertMounted(t, "MUSIC", true)
+	r.assertQueue(t, "MUSIC/01 Intro.flac")
+}
+
+func TestReplayDataDisc(t *testing.T) {
+	r := startReplay(t, "data_disc.yaml", nil)
+	r.server.AddNeighbor("udisks://by-uuid-2023-11-05-18-22-31-00", "MP3_COLLECTION")
+	r.server.AddToDatabase("MP3_COLLECTION/a.mp3", "MP3_COLLECTION/b.mp3")
+
+	r.step(t, detect.DeviceAdded)
+	r.assertMounted(t, "MP3_COLLECTION", true)
+	r.assertQueue(t, "MP3_COLLECTION/a.mp3", "MP3_COLLECTION/b.mp3")
+
+	r.step(t, detect.DeviceRemoved)
+	r.assertMounted(t, "MP3_COLLECTION", false)
+	r.assertQueue(t)
+}
+
+func TestReplayAudioDisc(t *testing.T) {
+	// reading the disc needs the drive, only its removal is replayed
+	r := startReplay(t, "audio_disc.yaml", map[string]string{
+		"MPD_DISCPLAYER_DISCAUTOPLAY": "false",
+	})
+	r.server.SetQueue("radio/stream.m3u", "cdda:///dev/sr0/1", "cdda:///dev/sr0/2", "cdda:///dev/sr1/1")
+
+	r.step(t, detect.DeviceAdded)
+	r.assertQueue(t, "radio/stream.m3u", "cdda:///dev/sr0/1", "cdda:///dev/sr0/2", "cdda:///dev/sr1/1")
+
+	r.step(t, detect.DeviceRemoved)
+	r.assertQueue(t, "radio/stream.m3u", "cdda:///dev/sr1/1")
+}
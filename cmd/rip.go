@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/events"
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/notifications"
+	"github.com/b0bbywan/go-mpd-discplayer/ripper"
+)
+
+// discRips rips audio discs into a folder of the MPD library.
+type discRips struct {
+	ripper *ripper.Ripper
+	// onInsert rips discs when inserted, play playing them meanwhile
+	onInsert bool
+	play     bool
+	// folder is where discs are ripped, relative to the MPD library
+	folder string
+	// pending are the drives whose disc is ripped once MPD stops reading
+	// it, cdparanoia and MPD competing for the drive otherwise
+	pending map[string]bool
+}
+
+func newDiscRips(client *mpdplayer.ReconnectingMPDClient) *discRips {
+	folder := viper.GetString("Rip.Folder")
+	config := ripper.NewConfig(
+		filepath.Join(viper.GetString("MPDLibraryFolder"), folder),
+		parseRipFormat(viper.GetString("Rip.Format")),
+		viper.GetInt("Rip.OpusBitrate"),
+	)
+	return &discRips{
+		ripper:   ripper.NewRipper(config, ripAlbum(client)),
+		onInsert: viper.GetBool("Rip.OnInsert"),
+		play:     viper.GetBool("Rip.Play"),
+		folder:   folder,
+		pending:  make(map[string]bool),
+	}
+}
+
+func parseRipFormat(value string) ripper.Format {
+	format, err := ripper.ParseFormat(value)
+	if err != nil {
+		log.Printf("%v, using %s", err, ripper.FormatFLAC)
+		return ripper.FormatFLAC
+	}
+	return format
+}
+
+// ripAlbum resolves the metadata of discs from their cue sheet, the one
+// played, discs without metadata being ripped in a folder named after their
+// disc ID.
+func ripAlbum(client *mpdplayer.ReconnectingMPDClient) ripper.Resolver {
+	return func(device string) (*ripper.Album, error) {
+		cueFilePath, err := client.DiscCue(device)
+		if err == nil {
+			return ripper.ReadCue(cueFilePath)
+		}
+		log.Printf("[rip] No metadata for %s, ripping it untagged: %v", device, err)
+		id, err := mpdplayer.DiscID(device)
+		if err != nil {
+			return nil, err
+		}
+		return &ripper.Album{Title: "Disc " + id}, nil
+	}
+}
+
+// startRip rips the disc in device in the background.
+func (p *Player) startRip(device string) error {
+	if p.verifier.running(device) {
+		return fmt.Errorf("verification running on %s", device)
+	}
+	if err := p.rips.ripper.Start(p.ctx, device, p.drives.get(device).ReadOffset, p.ripProgress, p.ripDone); err != nil {
+		return err
+	}
+	delete(p.rips.pending, device)
+	p.Events.Publish(events.Event{Type: events.RipStarted, Device: device, Kind: string(detect.DeviceDisc)})
+	return nil
+}
+
+func (p *Player) ripProgress(progress ripper.Progress) {
+	log.Printf("[rip] Ripping track %d/%d of %s", progress.Track, progress.Tracks, progress.Device)
+	p.Events.Publish(events.Event{
+		Type:   events.RipProgress,
+		Device: progress.Device,
+		Kind:   string(detect.DeviceDisc),
+		Track:  progress.Track,
+		Tracks: progress.Tracks,
+	})
+}
+
+// ripDone updates the ripped album in the MPD database, so it stays in the
+// library once the disc is removed.
+func (p *Player) ripDone(device, folder string, err error) {
+	ev := events.Event{Device: device, Kind: string(detect.DeviceDisc)}
+	if folder != "" {
+		ev.URI = path.Join(p.rips.folder, folder)
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		log.Printf("[rip] Rip of %s cancelled", device)
+		ev.Type = events.RipCancelled
+	case err != nil:
+		log.Printf("[rip] Rip of %s failed: %v", device, err)
+		ev.Type = events.RipFailed
+		ev.Error = err.Error()
+		p.NotifyEvent(notifications.EventError)
+	default:
+		ev.Type = events.RipFinished
+		if err := p.Client.UpdateDB(ev.URI); err != nil {
+			log.Printf("[rip] %v", err)
+		}
+	}
+	p.Events.Publish(ev)
+}
+
+// ripAfterPlayback rips the disc in device once it is not played anymore.
+func (p *Player) ripAfterPlayback(device string) {
+	log.Printf("[rip] %s will be ripped once not played anymore", device)
+	p.rips.pending[device] = true
+}
+
+// checkPendingRips starts the rips waiting for the playback of their disc to
+// end: none of its tracks is the current song, or MPD is stopped.
+func (p *Player) checkPendingRips() {
+	playbacks := p.readDiscPlayback(func(device string) bool { return p.rips.pending[device] })
+	if len(playbacks) == 0 {
+		return
+	}
+	status, statusErr := p.Client.Status()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if statusErr != nil {
+		log.Printf("[rip] Failed to check the playback state: %v", statusErr)
+		return
+	}
+	for _, playback := range playbacks {
+		device := playback.dev.Path()
+		if !p.rips.pending[device] {
+			// removed or ripped while MPD was queried
+			continue
+		}
+		if playback.err != nil {
+			log.Printf("[rip] Failed to check the playback of %s: %v", device, playback.err)
+			continue
+		}
+		current := playback.progress == mpdplayer.DiscCurrent || playback.progress == mpdplayer.DiscLastTrack
+		if current && status.State != "stop" {
+			continue
+		}
+		if err := p.startRip(device); err != nil {
+			log.Printf("[rip] %v", err)
+		}
+	}
+}
+
+// cancelRip stops the rip of the disc in device, if any, or forgets the one
+// waiting for its playback to end.
+func (p *Player) cancelRip(device string) bool {
+	if p.rips.pending[device] {
+		delete(p.rips.pending, device)
+		log.Printf("[rip] Cancelling pending rip of %s", device)
+		return true
+	}
+	if !p.rips.ripper.Cancel(device) {
+		return false
+	}
+	log.Printf("[rip] Cancelling rip of %s", device)
+	return true
+}
+
+// Rip rips a disc, the first one by default, into the MPD library.
+func (p *Player) Rip(device string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	drive, err := p.audioDisc(device)
+	if err != nil {
+		return err
+	}
+	return p.startRip(drive)
+}
+
+// CancelRip stops the rip of a disc, the first one by default. The tracks
+// already ripped are kept.
+func (p *Player) CancelRip(device string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if device == "" {
+		dev, err := p.findDevice(device)
+		if err != nil {
+			return err
+		}
+		device = dev.Path()
+	}
+	if !p.cancelRip(device) {
+		return fmt.Errorf("no rip running on %s", device)
+	}
+	return nil
+}
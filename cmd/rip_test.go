@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-mpd-discplayer/events"
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+)
+
+func TestRipWaitsForPlaybackToEnd(t *testing.T) {
+	r := startReplay(t, "audio_disc.yaml", map[string]string{
+		"MPD_DISCPLAYER_DISCAUTOPLAY": "false",
+	})
+	// no metadata lookup, the rip fails reading the missing drive
+	r.Client.SetCuerConfig(nil)
+	r.step(t, detect.DeviceAdded)
+	// the disc played as inserted with Rip.OnInsert and Rip.Play
+	r.server.SetQueue("cdda:///dev/sr0/1", "radio/stream.m3u")
+	r.server.Play(0)
+	r.mu.Lock()
+	r.ripAfterPlayback("/dev/sr0")
+	r.mu.Unlock()
+
+	r.checkPendingRips()
+	if r.rips.ripper.Running("/dev/sr0") || !r.pendingRip("/dev/sr0") {
+		t.Fatal("disc ripped while played")
+	}
+
+	r.server.Play(1)
+	r.checkPendingRips()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-r.outcomes:
+			if ev.Type != events.RipStarted {
+				continue
+			}
+			if ev.Device != "/dev/sr0" {
+				t.Fatalf("rip started on %s", ev.Device)
+			}
+			if r.pendingRip("/dev/sr0") {
+				t.Fatal("started rip still pending")
+			}
+			return
+		case <-timeout:
+			t.Fatal("rip not started once the disc was not played anymore")
+		}
+	}
+}
+
+func (r *replayPlayer) pendingRip(device string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rips.pending[device]
+}
@@ -2,26 +2,50 @@ package cmd
 
 import (
 	"fmt"
+
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol"
 )
 
 const (
-	ActionPlay = "play"
-	ActionStop = "stop"
+	ActionPlay  = "play"
+	ActionStop  = "stop"
+	ActionEject = "eject"
 )
 
 // executeAction handles the main logic for each action (add or remove).
-func (player *Player) ExecuteAction(device, action string) error {
+// Without device, play and eject use the default drive and stop removes every
+// disc.
+// An empty mode uses the drive queue mode.
+func (player *Player) ExecuteAction(device, action, mode string) error {
 	switch action {
 	case ActionPlay:
-		if err := player.Client.StartDiscPlayback(device); err != nil {
+		if device == "" {
+			device = defaultDrive
+		}
+		queueMode, err := queueModeOr(mode, player.drives.get(device).QueueMode)
+		if err != nil {
+			return err
+		}
+		if err := player.Client.StartDiscPlayback(device, queueMode); err != nil {
 			return fmt.Errorf("error adding tracks: %w", err)
 		}
 		return nil
 	case ActionStop:
-		if err := player.Client.StopDiscPlayback(); err != nil {
+		if err := player.Client.StopDiscPlayback(device); err != nil {
 			return fmt.Errorf("error adding tracks: %w", err)
 		}
 		return nil
+	case ActionEject:
+		if device == "" {
+			device = defaultDrive
+		}
+		if err := player.Client.StopDiscPlayback(device); err != nil {
+			return fmt.Errorf("error removing tracks: %w", err)
+		}
+		if err := hwcontrol.EjectDisc(device); err != nil {
+			return fmt.Errorf("error ejecting %s: %w", device, err)
+		}
+		return nil
 	default:
 		return fmt.Errorf("unknown action: %s", action)
 	}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
 
+	"github.com/b0bbywan/go-mpd-discplayer/control"
+	"github.com/b0bbywan/go-mpd-discplayer/events"
 	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
 	"github.com/b0bbywan/go-mpd-discplayer/notifications"
 )
@@ -15,10 +19,11 @@ type scheduler struct {
 }
 
 type ScheduleUri struct {
-	schedule string
-	uri      string
-	callback func()
-	jobId    cron.EntryID
+	schedule  string
+	uri       string
+	queueMode mpdplayer.QueueMode
+	callback  func()
+	jobId     cron.EntryID
 }
 
 func newScheduler(schedulers []*ScheduleUri) *scheduler {
@@ -33,7 +38,7 @@ func newScheduler(schedulers []*ScheduleUri) *scheduler {
 			log.Printf("Failed to add %s cron, check syntax: %v", v.schedule, err)
 		}
 		v.jobId = jobId
-		log.Printf("Added schedule: cron='%s' uri='%s'", v.schedule, v.uri)
+		log.Printf("Added schedule: cron='%s' uri='%s' queue='%s'", v.schedule, v.uri, v.queueMode)
 	}
 	s := &scheduler{
 		c:        c,
@@ -50,6 +55,26 @@ func (s *scheduler) Close() {
 	}
 }
 
+// List returns the registered schedules and their next run.
+func (s *scheduler) List() []control.ScheduleInfo {
+	schedules := []control.ScheduleInfo{}
+	if s == nil {
+		return schedules
+	}
+	for _, v := range s.schedule {
+		info := control.ScheduleInfo{
+			Schedule:  v.schedule,
+			URI:       v.uri,
+			QueueMode: string(v.queueMode),
+		}
+		if entry := s.c.Entry(v.jobId); entry.Valid() {
+			info.Next = entry.Next
+		}
+		schedules = append(schedules, info)
+	}
+	return schedules
+}
+
 func (p *Player) StopScheduler() {
 	if p.scheduler != nil {
 		p.scheduler.c.Stop()
@@ -62,37 +87,86 @@ func (p *Player) StartScheduler() {
 	}
 }
 
+// newSchedulerUris builds the schedules from the Schedule configuration,
+// where each cron maps to an URI, or to an URI and its queue mode:
+//
+//	"0 9 * * 6": "cdda://"
+//	"0 21 * * 7": {URI: "usb_label", QueueMode: "append"}
 func newSchedulerUris(
-	mpdClient *mpdplayer.ReconnectingMPDClient,
-	notifier *notifications.Notifier,
-	schedules map[string]string,
+	play func(schedule, uri string, mode mpdplayer.QueueMode) error,
+	schedules map[string]interface{},
+	defaultMode mpdplayer.QueueMode,
 ) []*ScheduleUri {
 	var schedulers []*ScheduleUri
 	for k, v := range schedules {
-		schedulers = append(schedulers, newSchedulerUri(mpdClient, notifier, k, v))
+		uri, mode, err := parseScheduleEntry(v, defaultMode)
+		if err != nil {
+			log.Printf("Invalid %s schedule, discarded: %v", k, err)
+			continue
+		}
+		schedulers = append(schedulers, newSchedulerUri(play, k, uri, mode))
 	}
 	return schedulers
 }
 
+func parseScheduleEntry(entry interface{}, defaultMode mpdplayer.QueueMode) (string, mpdplayer.QueueMode, error) {
+	switch v := entry.(type) {
+	case string:
+		return v, defaultMode, nil
+	case map[string]interface{}:
+		// viper lower cases nested keys
+		uri, _ := v["uri"].(string)
+		if uri == "" {
+			return "", "", fmt.Errorf("missing URI")
+		}
+		mode, _ := v["queuemode"].(string)
+		queueMode, err := queueModeOr(mode, defaultMode)
+		if err != nil {
+			return "", "", err
+		}
+		return uri, queueMode, nil
+	default:
+		return "", "", fmt.Errorf("unexpected value %v", entry)
+	}
+}
+
+func scheduleQueueMode() mpdplayer.QueueMode {
+	return parseQueueMode(viper.GetString("ScheduleQueueMode"), mpdplayer.QueueReplace)
+}
+
 func newSchedulerUri(
-	mpdClient *mpdplayer.ReconnectingMPDClient,
-	notifier *notifications.Notifier,
+	play func(schedule, uri string, mode mpdplayer.QueueMode) error,
 	schedule, uri string,
+	mode mpdplayer.QueueMode,
 ) *ScheduleUri {
 	callback := func() {
-		if notifier != nil {
-			notifier.PlayEvent(notifications.EventAdd)
-		}
-		if err := mpdClient.StartPlayback(uri); err != nil {
-			if notifier != nil {
-				notifier.PlayError()
-			}
+		if err := play(schedule, uri, mode); err != nil {
 			log.Printf("Failed to play %s: %v", uri, err)
 		}
 	}
 	return &ScheduleUri{
-		schedule: schedule,
-		uri:      uri,
-		callback: callback,
+		schedule:  schedule,
+		uri:       uri,
+		queueMode: mode,
+		callback:  callback,
+	}
+}
+
+// playScheduled starts the playback of uri, with notifications and events,
+// saving the queue it replaces as inserted media do. schedule is empty when
+// triggered from a control interface.
+func (p *Player) playScheduled(schedule, uri string, mode mpdplayer.QueueMode) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.NotifyEvent(notifications.EventAdd)
+	p.saveQueue(mode)
+	ev := events.Event{Type: events.ScheduleFired, Schedule: schedule, URI: uri}
+	err := p.Client.StartPlayback(uri, mode)
+	if err != nil {
+		p.NotifyEvent(notifications.EventError)
+		ev.Type = events.ScheduleFailed
+		ev.Error = err.Error()
 	}
+	p.Events.Publish(ev)
+	return err
 }
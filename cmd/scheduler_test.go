@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+)
+
+func TestTriggerSavesReplacedQueue(t *testing.T) {
+	r := startReplay(t, "usb_stick.yaml", map[string]string{
+		"MPD_DISCPLAYER_RESTOREQUEUE": "true",
+	})
+	r.server.AddToDatabase("radio/stream.m3u", "radio/morning.m3u")
+	r.server.SetQueue("radio/stream.m3u")
+
+	if err := r.Trigger("radio/morning.m3u", ""); err != nil {
+		t.Fatal(err)
+	}
+	r.assertQueue(t, "radio/morning.m3u")
+	var saved mpdplayer.QueueSnapshot
+	if err := r.queueStore.Load(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(saved.Files, []string{"radio/stream.m3u"}) {
+		t.Fatalf("saved queue = %v", saved.Files)
+	}
+
+	// an appended schedule leaves the queue in place
+	if err := r.Trigger("radio/stream.m3u", "append"); err != nil {
+		t.Fatal(err)
+	}
+	r.assertQueue(t, "radio/morning.m3u", "radio/stream.m3u")
+	if err := r.queueStore.Load(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(saved.Files, []string{"radio/stream.m3u"}) {
+		t.Fatalf("saved queue after append = %v", saved.Files)
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/state"
+)
+
+// usbResume remembers the queue order and playback of each USB drive when it
+// was removed, so it resumes there on its next insertion.
+type usbResume struct {
+	store *state.Store
+	// expiry is how long a position is kept, forever when zero
+	expiry time.Duration
+}
+
+// usbPositions are the saved positions by filesystem UUID.
+type usbPositions map[string]mpdplayer.USBPosition
+
+// newUSBResume returns the USB positions keeper, nil when disabled.
+func newUSBResume() *usbResume {
+	if !viper.GetBool("USBResume.Enabled") {
+		return nil
+	}
+	return &usbResume{
+		store:  newStateStore("usb.json"),
+		expiry: time.Duration(viper.GetInt("USBResume.ExpiryDays")) * 24 * time.Hour,
+	}
+}
+
+func (u *usbResume) load() usbPositions {
+	positions := make(usbPositions)
+	if err := u.store.Load(&positions); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	for uuid, position := range positions {
+		if expired(position.Time, u.expiry) {
+			delete(positions, uuid)
+		}
+	}
+	return positions
+}
+
+// usbUUID returns the filesystem UUID of a USB drive, empty if unknown.
+func usbUUID(dev detect.Device) string {
+	return dev.Udev().PropertyValue("ID_FS_UUID")
+}
+
+// usbPosition returns the saved position of a USB drive, nil if none.
+func (p *Player) usbPosition(dev detect.Device) *mpdplayer.USBPosition {
+	uuid := usbUUID(dev)
+	if p.usbResume == nil || uuid == "" {
+		return nil
+	}
+	position, ok := p.usbResume.load()[uuid]
+	if !ok {
+		return nil
+	}
+	log.Printf("Resuming USB drive %s", uuid)
+	return &position
+}
+
+// saveUSBPosition records the queue order and playback of a USB drive
+// mounted at relPath, before its files leave the queue.
+func (p *Player) saveUSBPosition(dev detect.Device, relPath string) {
+	uuid := usbUUID(dev)
+	if p.usbResume == nil || uuid == "" {
+		return
+	}
+	position, err := p.Client.USBPosition(relPath)
+	if err != nil {
+		log.Printf("Failed to save USB drive %s position: %v", uuid, err)
+		return
+	}
+	if position == nil {
+		return
+	}
+	positions := p.usbResume.load()
+	positions[uuid] = *position
+	if err := p.usbResume.store.Save(positions); err != nil {
+		log.Printf("Failed to save USB drive %s position: %v", uuid, err)
+		return
+	}
+	log.Printf("Saved USB drive %s position: %d files, at %q", uuid, len(position.Files), position.File)
+}
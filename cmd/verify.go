@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+
+	"github.com/b0bbywan/go-mpd-discplayer/accuraterip"
+	"github.com/b0bbywan/go-mpd-discplayer/control"
+	"github.com/b0bbywan/go-mpd-discplayer/events"
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
+	"github.com/b0bbywan/go-mpd-discplayer/jobs"
+	"github.com/b0bbywan/go-mpd-discplayer/notifications"
+	"github.com/b0bbywan/go-mpd-discplayer/ripper"
+)
+
+// discVerifier verifies discs against the AccurateRip database in the
+// background, one job per drive. Database responses and reports are kept in
+// the accuraterip folder of the state directory.
+type discVerifier struct {
+	fetcher accuraterip.Fetcher
+	jobs    *jobs.Group
+}
+
+func newDiscVerifier() *discVerifier {
+	folder := filepath.Join(viper.GetString("StateDirectory"), "accuraterip")
+	return &discVerifier{
+		fetcher: accuraterip.NewCachedFetcher(folder, accuraterip.NewHTTPFetcher(viper.GetString("AccurateRip.URL"))),
+		jobs:    jobs.NewGroup(),
+	}
+}
+
+// start runs verify in the background, unless device is already verified.
+// done is called once the verification no longer runs.
+func (v *discVerifier) start(ctx context.Context, device string, verify func(ctx context.Context), done func()) error {
+	if !v.jobs.Start(ctx, device, verify, done) {
+		return fmt.Errorf("verification already running on %s", device)
+	}
+	return nil
+}
+
+// cancel stops the verification of device, if any.
+func (v *discVerifier) cancel(device string) {
+	if v.jobs.Cancel(device) {
+		log.Printf("[accuraterip] Cancelling verification of %s", device)
+	}
+}
+
+// wait blocks until the verifications end, so no drive is read anymore.
+func (v *discVerifier) wait() {
+	v.jobs.Wait()
+}
+
+func (v *discVerifier) running(device string) bool {
+	return v.jobs.Running(device)
+}
+
+// verifyResult is the outcome of the verification of a disc.
+type verifyResult struct {
+	device string
+	report *accuraterip.Report
+	err    error
+}
+
+// Verify checks a disc, the first one by default, against the AccurateRip
+// database, and returns its report once every track is read.
+func (p *Player) Verify(device string) (*control.VerifyReport, error) {
+	results, err := p.startVerify(device)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case result := <-results:
+		if result.err != nil {
+			return nil, result.err
+		}
+		return newVerifyReport(result.device, result.report), nil
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+}
+
+// startVerify verifies a disc in the background, its result being sent on
+// the returned channel.
+func (p *Player) startVerify(device string) (<-chan verifyResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	device, err := p.audioDisc(device)
+	if err != nil {
+		return nil, err
+	}
+	if p.rips.ripper.Running(device) {
+		return nil, fmt.Errorf("%w on %s", ripper.ErrRunning, device)
+	}
+	offset := p.drives.get(device).ReadOffset
+	results := make(chan verifyResult, 1)
+	var result verifyResult
+	err = p.verifier.start(p.ctx, device, func(ctx context.Context) {
+		report, err := p.verifyDisc(ctx, device, offset)
+		result = verifyResult{device: device, report: report, err: err}
+	}, func() {
+		results <- result
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// verifyDisc reads each track of the disc in device and returns its report,
+// the tracks whose checksums are not in the database being reported as a
+// playback error.
+func (p *Player) verifyDisc(ctx context.Context, device string, offset int) (*accuraterip.Report, error) {
+	ev := events.Event{Device: device, Kind: string(detect.DeviceDisc)}
+	publish := func(t events.Type) {
+		ev.Type = t
+		p.Events.Publish(ev)
+	}
+	publish(events.VerifyStarted)
+	toc, err := accuraterip.ReadTOC(device)
+	if err != nil {
+		return nil, p.verifyFailed(ev, err)
+	}
+	open := func(ctx context.Context, track int) (io.ReadCloser, error) {
+		return ripper.OpenTrack(ctx, device, track, offset)
+	}
+	report, err := accuraterip.Verify(ctx, toc, p.verifier.fetcher, open, func(track, tracks int) {
+		log.Printf("[accuraterip] Verifying track %d/%d of %s", track, tracks, device)
+		ev.Track, ev.Tracks = track, tracks
+		publish(events.VerifyProgress)
+	})
+	ev.Track, ev.Tracks = 0, toc.Tracks()
+	if errors.Is(err, context.Canceled) {
+		log.Printf("[accuraterip] Verification of %s cancelled", device)
+		publish(events.VerifyCancelled)
+		return nil, fmt.Errorf("verification of %s cancelled", device)
+	}
+	if err != nil {
+		return nil, p.verifyFailed(ev, err)
+	}
+	for _, track := range report.Tracks {
+		log.Printf("[accuraterip] %s track %d: %s (v1 %s, v2 %s, confidence %d) %s",
+			device, track.Track, track.Status, track.V1, track.V2, track.Confidence, track.Error)
+	}
+	store := newStateStore(filepath.Join("accuraterip", toc.FreedbID+".json"))
+	if err := store.Save(report); err != nil {
+		log.Printf("[accuraterip] Failed to save report: %v", err)
+	} else {
+		ev.Report = store.Path()
+	}
+	if failed := report.Failed(); failed > 0 {
+		ev.Error = fmt.Sprintf("%d of %d tracks not accurate, the disc may be scratched or misread", failed, len(report.Tracks))
+		log.Printf("[accuraterip] %s: %s", device, ev.Error)
+		p.NotifyEvent(notifications.EventError)
+	} else {
+		log.Printf("[accuraterip] %s: all %d tracks accurate", device, len(report.Tracks))
+	}
+	publish(events.VerifyFinished)
+	return report, nil
+}
+
+// newVerifyReport returns the report of the disc in device for the control
+// clients.
+func newVerifyReport(device string, report *accuraterip.Report) *control.VerifyReport {
+	tracks := make([]control.VerifiedTrack, 0, len(report.Tracks))
+	for _, track := range report.Tracks {
+		tracks = append(tracks, control.VerifiedTrack{
+			Track:      track.Track,
+			Status:     string(track.Status),
+			V1:         track.V1,
+			V2:         track.V2,
+			Confidence: track.Confidence,
+			Error:      track.Error,
+		})
+	}
+	return &control.VerifyReport{
+		Device:    device,
+		FreedbID:  report.FreedbID,
+		Pressings: report.Pressings,
+		Tracks:    tracks,
+	}
+}
+
+// verifyFailed publishes the failure of the verification of a disc, and
+// returns it.
+func (p *Player) verifyFailed(ev events.Event, err error) error {
+	log.Printf("[accuraterip] Failed to verify %s: %v", ev.Device, err)
+	ev.Type = events.VerifyFailed
+	ev.Error = err.Error()
+	p.Events.Publish(ev)
+	return fmt.Errorf("failed to verify %s: %w", ev.Device, err)
+}
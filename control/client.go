@@ -0,0 +1,67 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// ErrUnavailable is returned when no daemon listens on the control socket.
+var ErrUnavailable = errors.New("mpd-discplayer daemon is not running")
+
+const (
+	clientTimeout = 2 * time.Minute
+	// verifyTimeout leaves the time to read a whole disc
+	verifyTimeout = 30 * time.Minute
+)
+
+// Send connects to the control socket, sends a single request and returns
+// the daemon response.
+func Send(path string, req Request) (*Response, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("warning: failed to close control connection: %v", err)
+		}
+	}()
+	timeout := clientTimeout
+	if req.Command == CommandVerify {
+		timeout = verifyTimeout
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
+
+// Call sends a request and turns a daemon side failure into an error.
+func Call(path string, req Request) (json.RawMessage, error) {
+	resp, err := Send(path, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("%s failed: %s", req.Command, resp.Error)
+	}
+	return resp.Data, nil
+}
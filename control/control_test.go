@@ -0,0 +1,129 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeController records the actions run against it, failing those on
+// devices listed in fail.
+type fakeController struct {
+	mu      sync.Mutex
+	actions []string
+	fail    map[string]bool
+	status  Status
+}
+
+func newFakeController() *fakeController {
+	return &fakeController{
+		fail: map[string]bool{"/dev/broken": true},
+		status: Status{
+			State:   "play",
+			Title:   "Song",
+			Devices: []DeviceInfo{{Path: "/dev/sr0", Kind: "disc"}},
+		},
+	}
+}
+
+func (c *fakeController) run(action, arg, mode string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.actions = append(c.actions, fmt.Sprintf("%s %s %s", action, arg, mode))
+	if c.fail[arg] {
+		return fmt.Errorf("%s: %w", arg, ErrUnknownDevice)
+	}
+	return nil
+}
+
+// waitActions waits for the actions run to be want.
+func (c *fakeController) waitActions(t *testing.T, want ...string) {
+	t.Helper()
+	var got []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		c.mu.Lock()
+		got = slices.Clone(c.actions)
+		c.mu.Unlock()
+		if slices.Equal(got, want) {
+			return
+		}
+	}
+	t.Fatalf("actions = %q, want %q", got, want)
+}
+
+func (c *fakeController) Status() (*Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := c.status
+	return &status, nil
+}
+
+func (c *fakeController) Devices() []DeviceInfo {
+	return []DeviceInfo{{Path: "/dev/sr0", Kind: "disc"}}
+}
+
+func (c *fakeController) Mounts() []MountInfo {
+	return []MountInfo{{Device: "/dev/sda1", Path: ".udisks/MUSIC"}}
+}
+
+func (c *fakeController) Schedules() []ScheduleInfo {
+	return nil
+}
+
+func (c *fakeController) Play(device, mode string) error {
+	return c.run(CommandPlay, device, mode)
+}
+
+func (c *fakeController) Stop(device string) error {
+	return c.run(CommandStop, device, "")
+}
+
+func (c *fakeController) Eject(device string) error {
+	return c.run(CommandEject, device, "")
+}
+
+func (c *fakeController) Rescan() error {
+	return c.run(CommandRescan, "", "")
+}
+
+func (c *fakeController) Reload() error {
+	return errors.New("reload not supported")
+}
+
+func (c *fakeController) Trigger(uri, mode string) error {
+	return c.run(CommandTrigger, uri, mode)
+}
+
+func (c *fakeController) Rip(device string) error {
+	return c.run(CommandRip, device, "")
+}
+
+func (c *fakeController) CancelRip(device string) error {
+	return c.run(CommandCancelRip, device, "")
+}
+
+func (c *fakeController) Verify(device string) (*VerifyReport, error) {
+	if err := c.run(CommandVerify, device, ""); err != nil {
+		return nil, err
+	}
+	return &VerifyReport{
+		Device:    device,
+		FreedbID:  "0a00b903",
+		Pressings: 2,
+		Tracks: []VerifiedTrack{
+			{Track: 1, Status: "accurate", V1: "0139f9fe", V2: "0139f9fe", Confidence: 5},
+			{Track: 2, Status: "mismatch", V1: "ff5724d0", V2: "ffffeda0"},
+		},
+	}, nil
+}
+
+func (c *fakeController) Import(device string) error {
+	return c.run(CommandImport, device, "")
+}
+
+func (c *fakeController) CancelImport(device string) error {
+	return c.run(CommandCancelImport, device, "")
+}
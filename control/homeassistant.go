@@ -0,0 +1,80 @@
+package control
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// haEntity is a Home Assistant MQTT discovery payload.
+// See https://www.home-assistant.io/integrations/mqtt/#mqtt-discovery
+type haEntity struct {
+	Name                string   `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	Icon                string   `json:"icon,omitempty"`
+	StateTopic          string   `json:"state_topic,omitempty"`
+	ValueTemplate       string   `json:"value_template,omitempty"`
+	JSONAttributesTopic string   `json:"json_attributes_topic,omitempty"`
+	JSONAttributesTpl   string   `json:"json_attributes_template,omitempty"`
+	CommandTopic        string   `json:"command_topic,omitempty"`
+	PayloadPress        *string  `json:"payload_press,omitempty"`
+	AvailabilityTopic   string   `json:"availability_topic"`
+	Device              haDevice `json:"device"`
+
+	component string
+	objectID  string
+}
+
+type haDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+	Model       string   `json:"model"`
+}
+
+// publishDiscovery announces the player to Home Assistant as a device with
+// sensors for the playback state and buttons for the commands.
+func (b *MQTTBridge) publishDiscovery() {
+	if b.config.DiscoveryPrefix == "" {
+		return
+	}
+	nodeID := unsafeNodeID.ReplaceAllString(b.config.ClientID, "_")
+	device := haDevice{
+		Identifiers: []string{nodeID},
+		Name:        "MPD Disc Player",
+		Model:       "mpd-discplayer",
+	}
+	status := b.topic("status")
+	empty := ""
+	entities := []haEntity{
+		{component: "sensor", objectID: "state", Name: "State", Icon: "mdi:disc-player",
+			StateTopic: status, ValueTemplate: "{{ value_json.state }}"},
+		{component: "sensor", objectID: "artist", Name: "Artist", Icon: "mdi:account-music",
+			StateTopic: status, ValueTemplate: "{{ value_json.artist | default('') }}"},
+		{component: "sensor", objectID: "album", Name: "Album", Icon: "mdi:album",
+			StateTopic: status, ValueTemplate: "{{ value_json.album | default('') }}"},
+		{component: "sensor", objectID: "title", Name: "Title", Icon: "mdi:music",
+			StateTopic: status, ValueTemplate: "{{ value_json.title | default('') }}"},
+		{component: "sensor", objectID: "devices", Name: "Devices", Icon: "mdi:usb-flash-drive",
+			StateTopic: status, ValueTemplate: "{{ value_json.devices | count }}",
+			JSONAttributesTopic: status, JSONAttributesTpl: "{{ {'devices': value_json.devices} | tojson }}"},
+		{component: "sensor", objectID: "last_error", Name: "Last error", Icon: "mdi:alert",
+			StateTopic: b.topic("error"), ValueTemplate: "{{ value_json.error }}"},
+		{component: "button", objectID: "play", Name: "Play disc", Icon: "mdi:play",
+			CommandTopic: b.topic("command", CommandPlay), PayloadPress: &empty},
+		{component: "button", objectID: "stop", Name: "Stop", Icon: "mdi:stop",
+			CommandTopic: b.topic("command", CommandStop), PayloadPress: &empty},
+		{component: "button", objectID: "eject", Name: "Eject disc", Icon: "mdi:eject",
+			CommandTopic: b.topic("command", CommandEject), PayloadPress: &empty},
+	}
+	for _, entity := range entities {
+		entity.UniqueID = nodeID + "_" + entity.objectID
+		entity.AvailabilityTopic = b.topic("availability")
+		entity.Device = device
+		data, err := json.Marshal(entity)
+		if err != nil {
+			log.Printf("[mqtt] Failed to encode %s discovery: %v", entity.objectID, err)
+			continue
+		}
+		topic := b.config.DiscoveryPrefix + "/" + entity.component + "/" + nodeID + "/" + entity.objectID + "/config"
+		b.publishRaw(topic, data, true)
+	}
+}
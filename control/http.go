@@ -0,0 +1,314 @@
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/b0bbywan/go-mpd-discplayer/events"
+)
+
+const (
+	shutdownTimeout   = 5 * time.Second
+	keepAliveInterval = 30 * time.Second
+	eventsBuffer      = 32
+)
+
+// HTTPServer exposes the controller as a REST API:
+//
+//	GET  /api/status
+//	GET  /api/devices
+//	GET  /api/mounts
+//	GET  /api/schedules
+//	POST /api/play?device=/dev/sr0[&mode=append]
+//	POST /api/stop[?device=/dev/sdb1]
+//	POST /api/eject[?device=/dev/sr0]
+//	POST /api/trigger?uri=cdda://[&mode=next]
+//	POST /api/rip[?device=/dev/sr0]
+//	POST /api/rip/cancel[?device=/dev/sr0]
+//	POST /api/verify[?device=/dev/sr0]
+//	POST /api/import[?device=/dev/sda1]
+//	POST /api/import/cancel[?device=/dev/sda1]
+//	GET  /api/events
+//
+// /api/verify answers the AccurateRip report of the disc once all its tracks
+// are read. /api/events is a server-sent events stream of the bus events,
+// each sent as its JSON payload under its type name.
+//
+// Actions must be posted as application/json, and are refused from the
+// pages of other origins than the configured ones, so a web page can't make
+// the browser of a listener run them. Every request needs the bearer token
+// when one is configured.
+type HTTPServer struct {
+	config     *HTTPConfig
+	server     *http.Server
+	controller Controller
+	bus        *events.Bus
+}
+
+type HTTPConfig struct {
+	Address string
+	// Token is the bearer token required by every request, empty disables authentication
+	Token string
+	// AllowedOrigins are the web origins allowed to call the API from a browser,
+	// e.g. "http://dashboard.local:3000"
+	AllowedOrigins []string
+}
+
+func NewHTTPConfig(address, token string, allowedOrigins []string) *HTTPConfig {
+	return &HTTPConfig{
+		Address:        address,
+		Token:          token,
+		AllowedOrigins: allowedOrigins,
+	}
+}
+
+func NewHTTPServer(config *HTTPConfig, controller Controller, bus *events.Bus) (*HTTPServer, error) {
+	s := &HTTPServer{config: config, controller: controller, bus: bus}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", s.handleStatus)
+	mux.HandleFunc("GET /api/devices", s.handleDevices)
+	mux.HandleFunc("GET /api/mounts", s.handleMounts)
+	mux.HandleFunc("GET /api/schedules", s.handleSchedules)
+	mux.HandleFunc("POST /api/play", s.handlePlay)
+	mux.HandleFunc("POST /api/stop", s.handleAction(controller.Stop))
+	mux.HandleFunc("POST /api/eject", s.handleAction(controller.Eject))
+	mux.HandleFunc("POST /api/trigger", s.handleTrigger)
+	mux.HandleFunc("POST /api/rip", s.handleAction(controller.Rip))
+	mux.HandleFunc("POST /api/rip/cancel", s.handleAction(controller.CancelRip))
+	mux.HandleFunc("POST /api/verify", s.handleVerify)
+	mux.HandleFunc("POST /api/import", s.handleAction(controller.Import))
+	mux.HandleFunc("POST /api/import/cancel", s.handleAction(controller.CancelImport))
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+
+	csrf := http.NewCrossOriginProtection()
+	for _, origin := range config.AllowedOrigins {
+		if err := csrf.AddTrustedOrigin(origin); err != nil {
+			return nil, fmt.Errorf("invalid HTTP allowed origin: %w", err)
+		}
+	}
+	csrf.SetDenyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusForbidden, Response{Error: "cross-origin request refused"})
+	}))
+	s.server = &http.Server{
+		Addr:              config.Address,
+		Handler:           s.cors(csrf.Handler(s.authenticate(requireJSON(mux)))),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// cors lets the pages of the allowed origins call the API, answering their
+// preflight requests.
+func (s *HTTPServer) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" || !slices.Contains(s.config.AllowedOrigins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Max-Age", "600")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// authenticate refuses the requests without the configured bearer token.
+// Browsers can't set headers on event streams, so /api/events also takes it
+// as the access_token parameter.
+func (s *HTTPServer) authenticate(next http.Handler) http.Handler {
+	if s.config.Token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.config.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get("Authorization")
+		if got == "" && r.URL.Path == "/api/events" && r.URL.Query().Has("access_token") {
+			got = "Bearer " + r.URL.Query().Get("access_token")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, Response{Error: "invalid or missing token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireJSON refuses the actions not posted as application/json, which a
+// page can't send to another origin without its consent.
+func requireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				writeJSON(w, http.StatusUnsupportedMediaType, Response{Error: "actions must be posted as application/json"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve listens until ctx is cancelled, then shuts the server down gracefully.
+func (s *HTTPServer) Serve(ctx context.Context) error {
+	// Event streams never end on their own, they are closed along with ctx
+	s.server.BaseContext = func(net.Listener) context.Context { return ctx }
+	errChan := make(chan error, 1)
+	go func() {
+		log.Printf("HTTP API listening on %s", s.server.Addr)
+		errChan <- s.server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("HTTP API stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down HTTP API: %w", err)
+	}
+	return nil
+}
+
+func (s *HTTPServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.controller.Status()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *HTTPServer) handleDevices(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.controller.Devices())
+}
+
+func (s *HTTPServer) handleMounts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.controller.Mounts())
+}
+
+func (s *HTTPServer) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.controller.Schedules())
+}
+
+func (s *HTTPServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.bus == nil {
+		writeError(w, errors.New("event stream unavailable"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, errors.New("streaming unsupported"))
+		return
+	}
+	stream, unsubscribe := s.bus.Subscribe(eventsBuffer)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case ev := <-stream:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				log.Printf("Failed to encode %s event: %v", ev.Type, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func (s *HTTPServer) handlePlay(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if err := s.controller.Play(query.Get("device"), query.Get("mode")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{OK: true})
+}
+
+func (s *HTTPServer) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	uri := r.URL.Query().Get("uri")
+	if uri == "" {
+		writeJSON(w, http.StatusBadRequest, Response{Error: "missing uri"})
+		return
+	}
+	if err := s.controller.Trigger(uri, r.URL.Query().Get("mode")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{OK: true})
+}
+
+// handleVerify answers the report of the disc once verified, which takes as
+// long as reading it.
+func (s *HTTPServer) handleVerify(w http.ResponseWriter, r *http.Request) {
+	report, err := s.controller.Verify(r.URL.Query().Get("device"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleAction runs a device action, the device being read from the query.
+func (s *HTTPServer) handleAction(action func(device string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := action(r.URL.Query().Get("device")); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, Response{OK: true})
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if errors.Is(err, ErrUnknownDevice) {
+		code = http.StatusNotFound
+	}
+	writeJSON(w, code, Response{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Failed to write HTTP response: %v", err)
+	}
+}
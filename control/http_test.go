@@ -0,0 +1,138 @@
+package control
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const dashboard = "http://dashboard.local:3000"
+
+func newTestHTTPServer(t *testing.T, token string) (*HTTPServer, *fakeController) {
+	t.Helper()
+	controller := newFakeController()
+	s, err := NewHTTPServer(NewHTTPConfig("127.0.0.1:0", token, []string{dashboard}), controller, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, controller
+}
+
+func (s *HTTPServer) serve(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, r)
+	return w
+}
+
+func postJSON(target string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, nil)
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestHTTPVerify(t *testing.T) {
+	s, _ := newTestHTTPServer(t, "")
+
+	w := s.serve(postJSON("/api/verify?device=/dev/sr0"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var report VerifyReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.FreedbID != "0a00b903" || len(report.Tracks) != 2 || report.Tracks[1].Status != "mismatch" {
+		t.Fatalf("report = %s", w.Body)
+	}
+
+	if w := s.serve(postJSON("/api/verify?device=/dev/broken")); w.Code != http.StatusNotFound {
+		t.Fatalf("status of an unknown device = %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestHTTPRefusesCrossSiteActions(t *testing.T) {
+	s, controller := newTestHTTPServer(t, "")
+
+	// a form posted by a page of another site
+	form := httptest.NewRequest(http.MethodPost, "/api/eject", nil)
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if w := s.serve(form); w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("form post status = %d: %s", w.Code, w.Body)
+	}
+	crossSite := postJSON("/api/eject")
+	crossSite.Header.Set("Origin", "http://evil.example")
+	crossSite.Header.Set("Sec-Fetch-Site", "cross-site")
+	if w := s.serve(crossSite); w.Code != http.StatusForbidden {
+		t.Fatalf("cross-site post status = %d: %s", w.Code, w.Body)
+	}
+
+	fromDashboard := postJSON("/api/eject")
+	fromDashboard.Header.Set("Origin", dashboard)
+	fromDashboard.Header.Set("Sec-Fetch-Site", "cross-site")
+	w := s.serve(fromDashboard)
+	if w.Code != http.StatusOK {
+		t.Fatalf("dashboard post status = %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != dashboard {
+		t.Fatalf("Access-Control-Allow-Origin = %q", got)
+	}
+	// from the command line
+	if w := s.serve(postJSON("/api/stop")); w.Code != http.StatusOK {
+		t.Fatalf("curl post status = %d: %s", w.Code, w.Body)
+	}
+	controller.waitActions(t, "eject  ", "stop  ")
+}
+
+func TestHTTPPreflight(t *testing.T) {
+	s, _ := newTestHTTPServer(t, "secret")
+
+	for origin, allowed := range map[string]bool{dashboard: true, "http://evil.example": false} {
+		r := httptest.NewRequest(http.MethodOptions, "/api/play", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		r.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		w := s.serve(r)
+		if got := w.Header().Get("Access-Control-Allow-Origin") == origin; got != allowed {
+			t.Fatalf("preflight from %s allowed = %v, want %v", origin, got, allowed)
+		}
+		if allowed && (w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Headers") == "") {
+			t.Fatalf("preflight from %s = %d %v", origin, w.Code, w.Header())
+		}
+	}
+}
+
+func TestHTTPToken(t *testing.T) {
+	s, controller := newTestHTTPServer(t, "secret")
+
+	if w := s.serve(httptest.NewRequest(http.MethodGet, "/api/status", nil)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("status without token = %d", w.Code)
+	}
+	wrong := postJSON("/api/stop")
+	wrong.Header.Set("Authorization", "Bearer guess")
+	if w := s.serve(wrong); w.Code != http.StatusUnauthorized {
+		t.Fatalf("stop with a wrong token = %d", w.Code)
+	}
+	// only event streams take the token as parameter
+	if w := s.serve(postJSON("/api/stop?access_token=secret")); w.Code != http.StatusUnauthorized {
+		t.Fatalf("stop with the token as parameter = %d", w.Code)
+	}
+
+	// no bus in tests, the stream is unavailable once authenticated
+	if w := s.serve(httptest.NewRequest(http.MethodGet, "/api/events?access_token=secret", nil)); w.Code != http.StatusInternalServerError {
+		t.Fatalf("events with the token as parameter = %d", w.Code)
+	}
+
+	stop := postJSON("/api/stop")
+	stop.Header.Set("Authorization", "Bearer secret")
+	if w := s.serve(stop); w.Code != http.StatusOK {
+		t.Fatalf("stop with the token = %d: %s", w.Code, w.Body)
+	}
+	controller.waitActions(t, "stop  ")
+}
+
+func TestHTTPInvalidAllowedOrigin(t *testing.T) {
+	if _, err := NewHTTPServer(NewHTTPConfig("127.0.0.1:0", "", []string{"dashboard.local"}), newFakeController(), nil); err == nil {
+		t.Fatal("origin without scheme accepted")
+	}
+}
@@ -0,0 +1,268 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/b0bbywan/go-mpd-discplayer/events"
+)
+
+const (
+	mqttTimeout        = 5 * time.Second
+	mqttStatusInterval = 10 * time.Second
+	mqttOnline         = "online"
+	mqttOffline        = "offline"
+)
+
+var unsafeNodeID = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+type MQTTConfig struct {
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// TopicPrefix is the root of the state and command topics
+	TopicPrefix string
+	// DiscoveryPrefix is the Home Assistant discovery prefix, empty disables discovery
+	DiscoveryPrefix string
+}
+
+func NewMQTTConfig(broker, clientID, username, password, topicPrefix, discoveryPrefix string) *MQTTConfig {
+	return &MQTTConfig{
+		Broker:          broker,
+		ClientID:        clientID,
+		Username:        username,
+		Password:        password,
+		TopicPrefix:     topicPrefix,
+		DiscoveryPrefix: discoveryPrefix,
+	}
+}
+
+// MQTTBridge publishes the player state and bus events to a MQTT broker,
+// and runs the commands received on the command topics:
+//
+//	<prefix>/availability       online/offline (retained)
+//	<prefix>/status             playback status and present devices (retained)
+//	<prefix>/event              every bus event
+//	<prefix>/error              failed handlers and schedules
+//	<prefix>/command/play       payload: device, the first disc when empty
+//	<prefix>/command/stop       payload: device, stops playback when empty
+//	<prefix>/command/eject      payload: device, the first disc when empty
+//	<prefix>/command/trigger    payload: URI to play as a schedule would
+//	<prefix>/command/rip        payload: device, the first disc when empty
+//	<prefix>/command/cancel-rip payload: device, the first disc when empty
+//	<prefix>/command/verify     payload: device, the first disc when empty
+//	<prefix>/command/import     payload: device, the first USB drive when empty
+//	<prefix>/command/cancel-import payload: device, the first USB drive when empty
+//
+// play and trigger also accept a JSON payload overriding the queue mode:
+// {"arg": "/dev/sr0", "mode": "append"}. Retained commands are ignored.
+type MQTTBridge struct {
+	config     *MQTTConfig
+	controller Controller
+	bus        *events.Bus
+	client     paho.Client
+	mu         sync.Mutex // Protects lastStatus
+	lastStatus []byte
+}
+
+func NewMQTTBridge(config *MQTTConfig, controller Controller, bus *events.Bus) *MQTTBridge {
+	b := &MQTTBridge{
+		config:     config,
+		controller: controller,
+		bus:        bus,
+	}
+	opts := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetWill(b.topic("availability"), mqttOffline, 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(b.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("MQTT connection lost: %v", err)
+		})
+	b.client = paho.NewClient(opts)
+	return b
+}
+
+// Serve connects to the broker and forwards the bus events until ctx is
+// cancelled. Connection failures are retried in the background.
+func (b *MQTTBridge) Serve(ctx context.Context) error {
+	if b.bus == nil {
+		return fmt.Errorf("no event bus")
+	}
+	stream, unsubscribe := b.bus.Subscribe(eventsBuffer)
+	defer unsubscribe()
+
+	log.Printf("Connecting to MQTT broker %s", b.config.Broker)
+	b.client.Connect()
+	defer func() {
+		b.publish("availability", mqttOffline, true)
+		b.client.Disconnect(uint(mqttTimeout.Milliseconds()))
+	}()
+
+	ticker := time.NewTicker(mqttStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			b.publishStatus()
+		case ev := <-stream:
+			b.publishEvent(ev)
+		}
+	}
+}
+
+func (b *MQTTBridge) onConnect(client paho.Client) {
+	log.Printf("Connected to MQTT broker %s", b.config.Broker)
+	commands := map[string]func(arg, mode string) error{
+		CommandPlay:         b.controller.Play,
+		CommandStop:         ignoreMode(b.controller.Stop),
+		CommandEject:        ignoreMode(b.controller.Eject),
+		CommandTrigger:      b.controller.Trigger,
+		CommandRip:          ignoreMode(b.controller.Rip),
+		CommandCancelRip:    ignoreMode(b.controller.CancelRip),
+		CommandVerify:       ignoreMode(b.verify),
+		CommandImport:       ignoreMode(b.controller.Import),
+		CommandCancelImport: ignoreMode(b.controller.CancelImport),
+	}
+	for name, action := range commands {
+		b.subscribe(name, action)
+	}
+	b.publishDiscovery()
+	b.publish("availability", mqttOnline, true)
+	b.mu.Lock()
+	b.lastStatus = nil
+	b.mu.Unlock()
+	b.publishStatus()
+}
+
+// mqttCommand is the JSON form of a command payload.
+type mqttCommand struct {
+	Arg  string `json:"arg"`
+	Mode string `json:"mode"`
+}
+
+func parseCommand(payload []byte) mqttCommand {
+	var cmd mqttCommand
+	if len(payload) > 0 && payload[0] == '{' && json.Unmarshal(payload, &cmd) == nil {
+		return cmd
+	}
+	return mqttCommand{Arg: string(payload)}
+}
+
+func ignoreMode(action func(string) error) func(arg, mode string) error {
+	return func(arg, _ string) error {
+		return action(arg)
+	}
+}
+
+// verify verifies a disc, its report being published along with the
+// verify_finished event.
+func (b *MQTTBridge) verify(device string) error {
+	_, err := b.controller.Verify(device)
+	return err
+}
+
+func (b *MQTTBridge) subscribe(name string, action func(arg, mode string) error) {
+	topic := b.topic("command", name)
+	token := b.client.Subscribe(topic, 1, func(_ paho.Client, msg paho.Message) {
+		// a command retained by mistake would run again on every connection
+		if msg.Retained() {
+			log.Printf("[mqtt] Ignoring retained %s command", name)
+			return
+		}
+		cmd := parseCommand(msg.Payload())
+		arg := cmd.Arg
+		log.Printf("[mqtt] Received %s %s", name, arg)
+		if err := action(arg, cmd.Mode); err != nil {
+			log.Printf("[mqtt] %s failed: %v", name, err)
+			b.bus.Publish(events.Event{Type: events.CommandFailed, Action: name, Device: arg, Error: err.Error()})
+		}
+	})
+	if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+		log.Printf("Failed to subscribe to %s: %v", topic, token.Error())
+	}
+}
+
+func (b *MQTTBridge) publishEvent(ev events.Event) {
+	b.publishJSON("event", ev, false)
+	if ev.Error != "" {
+		b.publishJSON("error", ev, false)
+	}
+	b.publishStatus()
+}
+
+// publishStatus publishes the player status when it changed since the last call.
+func (b *MQTTBridge) publishStatus() {
+	status, err := b.controller.Status()
+	if err != nil {
+		log.Printf("[mqtt] %v", err)
+		return
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		log.Printf("[mqtt] Failed to encode status: %v", err)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if string(data) == string(b.lastStatus) {
+		return
+	}
+	if b.publish("status", data, true) {
+		b.lastStatus = data
+	}
+}
+
+func (b *MQTTBridge) publishJSON(topic string, v interface{}, retained bool) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[mqtt] Failed to encode %s payload: %v", topic, err)
+		return
+	}
+	b.publish(topic, data, retained)
+}
+
+// publish sends payload to a topic under the prefix, and reports whether
+// it was delivered to the broker.
+func (b *MQTTBridge) publish(topic string, payload interface{}, retained bool) bool {
+	return b.publishRaw(b.topic(topic), payload, retained)
+}
+
+func (b *MQTTBridge) publishRaw(topic string, payload interface{}, retained bool) bool {
+	if !b.client.IsConnectionOpen() {
+		return false
+	}
+	token := b.client.Publish(topic, 1, retained, payload)
+	if !token.WaitTimeout(mqttTimeout) {
+		log.Printf("[mqtt] Timeout publishing to %s", topic)
+		return false
+	}
+	if err := token.Error(); err != nil {
+		log.Printf("[mqtt] Failed to publish to %s: %v", topic, err)
+		return false
+	}
+	return true
+}
+
+func (b *MQTTBridge) topic(parts ...string) string {
+	topic := b.config.TopicPrefix
+	for _, part := range parts {
+		topic += "/" + part
+	}
+	return topic
+}
@@ -0,0 +1,247 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/b0bbywan/go-mpd-discplayer/events"
+)
+
+// broker is an embedded MQTT broker recording the messages published on it.
+type broker struct {
+	*mqtt.Server
+	address string
+
+	mu       sync.Mutex
+	messages map[string][]packets.Packet
+}
+
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+	server := mqtt.New(&mqtt.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := server.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve()
+	}()
+	t.Cleanup(func() { _ = server.Close() })
+
+	b := &broker{
+		Server:   server,
+		address:  "tcp://" + tcp.Address(),
+		messages: make(map[string][]packets.Packet),
+	}
+	err := server.Subscribe("#", 1, func(_ *mqtt.Client, _ packets.Subscription, pk packets.Packet) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.messages[pk.TopicName] = append(b.messages[pk.TopicName], pk)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// wait returns the last message published on topic, waiting for one.
+func (b *broker) wait(t *testing.T, topic string) packets.Packet {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		b.mu.Lock()
+		messages := b.messages[topic]
+		b.mu.Unlock()
+		if len(messages) > 0 {
+			return messages[len(messages)-1]
+		}
+	}
+	t.Fatalf("nothing published on %s", topic)
+	return packets.Packet{}
+}
+
+// waitPayload waits for payload to be published on topic.
+func (b *broker) waitPayload(t *testing.T, topic, payload string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		b.mu.Lock()
+		messages := b.messages[topic]
+		b.mu.Unlock()
+		for _, pk := range messages {
+			if string(pk.Payload) == payload {
+				return
+			}
+		}
+	}
+	t.Fatalf("%s not published on %s", payload, topic)
+}
+
+// startBridge runs a bridge to the broker until the test ends, once it is
+// online.
+func startBridge(t *testing.T, b *broker, controller Controller, bus *events.Bus) {
+	t.Helper()
+	config := NewMQTTConfig(b.address, "mpd-discplayer", "", "", "discplayer", "homeassistant")
+	bridge := NewMQTTBridge(config, controller, bus)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bridge.Serve(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	b.waitPayload(t, "discplayer/availability", mqttOnline)
+}
+
+func TestMQTTTopics(t *testing.T) {
+	b := newBroker(t)
+	bus := events.NewBus()
+	startBridge(t, b, newFakeController(), bus)
+
+	if pk := b.wait(t, "discplayer/availability"); !pk.FixedHeader.Retain {
+		t.Fatal("availability not retained")
+	}
+	pk := b.wait(t, "discplayer/status")
+	var status Status
+	if err := json.Unmarshal(pk.Payload, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.State != "play" || status.Title != "Song" || len(status.Devices) != 1 || !pk.FixedHeader.Retain {
+		t.Fatalf("status = %s, retained %v", pk.Payload, pk.FixedHeader.Retain)
+	}
+
+	bus.Publish(events.Event{Type: events.HandlerFailed, Device: "/dev/sr0", Error: "no disc"})
+	for _, topic := range []string{"discplayer/event", "discplayer/error"} {
+		pk := b.wait(t, topic)
+		var ev events.Event
+		if err := json.Unmarshal(pk.Payload, &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != events.HandlerFailed || ev.Error != "no disc" || pk.FixedHeader.Retain {
+			t.Fatalf("%s = %s, retained %v", topic, pk.Payload, pk.FixedHeader.Retain)
+		}
+	}
+}
+
+func TestMQTTDiscovery(t *testing.T) {
+	b := newBroker(t)
+	startBridge(t, b, newFakeController(), events.NewBus())
+
+	tests := []struct {
+		topic string
+		want  haEntity
+	}{
+		{"homeassistant/sensor/mpd-discplayer/state/config", haEntity{
+			Name:          "State",
+			UniqueID:      "mpd-discplayer_state",
+			StateTopic:    "discplayer/status",
+			ValueTemplate: "{{ value_json.state }}",
+		}},
+		{"homeassistant/sensor/mpd-discplayer/last_error/config", haEntity{
+			Name:          "Last error",
+			UniqueID:      "mpd-discplayer_last_error",
+			StateTopic:    "discplayer/error",
+			ValueTemplate: "{{ value_json.error }}",
+		}},
+		{"homeassistant/button/mpd-discplayer/eject/config", haEntity{
+			Name:         "Eject disc",
+			UniqueID:     "mpd-discplayer_eject",
+			CommandTopic: "discplayer/command/eject",
+		}},
+	}
+	for _, tt := range tests {
+		pk := b.wait(t, tt.topic)
+		if !pk.FixedHeader.Retain {
+			t.Errorf("%s not retained", tt.topic)
+		}
+		var got haEntity
+		if err := json.Unmarshal(pk.Payload, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != tt.want.Name || got.UniqueID != tt.want.UniqueID ||
+			got.StateTopic != tt.want.StateTopic || got.ValueTemplate != tt.want.ValueTemplate ||
+			got.CommandTopic != tt.want.CommandTopic {
+			t.Errorf("%s = %s", tt.topic, pk.Payload)
+		}
+		if got.AvailabilityTopic != "discplayer/availability" || got.Device.Identifiers[0] != "mpd-discplayer" {
+			t.Errorf("%s availability or device = %s", tt.topic, pk.Payload)
+		}
+	}
+}
+
+func TestMQTTCommands(t *testing.T) {
+	b := newBroker(t)
+	bus := events.NewBus()
+	failures, unsubscribe := bus.Subscribe(eventsBuffer)
+	defer unsubscribe()
+	controller := newFakeController()
+	startBridge(t, b, controller, bus)
+
+	for _, command := range []struct{ topic, payload string }{
+		{"discplayer/command/play", `{"arg": "/dev/sr0", "mode": "append"}`},
+		{"discplayer/command/stop", "/dev/sda1"},
+		{"discplayer/command/trigger", "radio/stream.m3u"},
+		{"discplayer/command/eject", "/dev/broken"},
+	} {
+		if err := b.Publish(command.topic, []byte(command.payload), false, 1); err != nil {
+			t.Fatal(err)
+		}
+		// commands are handled concurrently, wait for each to keep their order
+		b.waitPayload(t, command.topic, command.payload)
+		time.Sleep(50 * time.Millisecond)
+	}
+	controller.waitActions(t,
+		"play /dev/sr0 append",
+		"stop /dev/sda1 ",
+		"trigger radio/stream.m3u ",
+		"eject /dev/broken ",
+	)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-failures:
+			if ev.Type != events.CommandFailed {
+				continue
+			}
+			if ev.Action != CommandEject || ev.Device != "/dev/broken" || ev.Error == "" {
+				t.Fatalf("failure = %+v", ev)
+			}
+			return
+		case <-timeout:
+			t.Fatal("failed command not published")
+		}
+	}
+}
+
+func TestMQTTIgnoresRetainedCommands(t *testing.T) {
+	b := newBroker(t)
+	// left by a client publishing its commands retained
+	if err := b.Publish("discplayer/command/play", []byte("/dev/sr0"), true, 1); err != nil {
+		t.Fatal(err)
+	}
+	controller := newFakeController()
+	startBridge(t, b, controller, events.NewBus())
+
+	if err := b.Publish("discplayer/command/play", []byte("/dev/sr1"), false, 1); err != nil {
+		t.Fatal(err)
+	}
+	controller.waitActions(t, "play /dev/sr1 ")
+}
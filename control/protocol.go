@@ -0,0 +1,188 @@
+// Package control exposes the running player over a local unix socket, with
+// a small JSON protocol, and provides the matching client. The same
+// controller is optionally served over HTTP and bridged to MQTT.
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	CommandStatus       = "status"
+	CommandPlay         = "play"
+	CommandStop         = "stop"
+	CommandEject        = "eject"
+	CommandRescan       = "rescan"
+	CommandListDevices  = "list-devices"
+	CommandReload       = "reload"
+	CommandTrigger      = "trigger"
+	CommandRip          = "rip"
+	CommandCancelRip    = "cancel-rip"
+	CommandVerify       = "verify"
+	CommandImport       = "import"
+	CommandCancelImport = "cancel-import"
+)
+
+// ErrUnknownDevice is returned when acting on a device the player does not know.
+var ErrUnknownDevice = errors.New("unknown device")
+
+// Request is a single command sent to the daemon, one JSON object per line.
+// Mode overrides the configured queue mode of play and trigger.
+type Request struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Mode    string   `json:"mode,omitempty"`
+}
+
+// Response answers a Request. Data holds the command result, if any.
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// DeviceInfo describes a device known to the player.
+type DeviceInfo struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+}
+
+// MountInfo describes a USB device mounted in the MPD library.
+type MountInfo struct {
+	Device string `json:"device"`
+	Path   string `json:"path"`
+}
+
+// ScheduleInfo describes a scheduled playback.
+type ScheduleInfo struct {
+	Schedule  string    `json:"schedule"`
+	URI       string    `json:"uri"`
+	QueueMode string    `json:"queue_mode"`
+	Next      time.Time `json:"next,omitzero"`
+}
+
+// Status is the playback state as seen by the player.
+type Status struct {
+	State       string       `json:"state"`
+	File        string       `json:"file,omitempty"`
+	Artist      string       `json:"artist,omitempty"`
+	Album       string       `json:"album,omitempty"`
+	Title       string       `json:"title,omitempty"`
+	Song        int          `json:"song"`
+	Elapsed     float64      `json:"elapsed"`
+	QueueLength int          `json:"queue_length"`
+	Devices     []DeviceInfo `json:"devices"`
+}
+
+// VerifyReport is the verification of a disc against the AccurateRip
+// database.
+type VerifyReport struct {
+	Device    string          `json:"device"`
+	FreedbID  string          `json:"freedb_id"`
+	Pressings int             `json:"pressings"`
+	Tracks    []VerifiedTrack `json:"tracks"`
+}
+
+// VerifiedTrack is the verification of a track: accurate, mismatch when its
+// checksums are not in the database, or error when it could not be read.
+type VerifiedTrack struct {
+	Track  int    `json:"track"`
+	Status string `json:"status"`
+	V1     string `json:"v1,omitempty"`
+	V2     string `json:"v2,omitempty"`
+	// Confidence is how many users ripped the same checksum
+	Confidence int    `json:"confidence"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Controller is implemented by the player to act on its live state.
+// An empty queue mode uses the configured one.
+type Controller interface {
+	Status() (*Status, error)
+	Devices() []DeviceInfo
+	Mounts() []MountInfo
+	Schedules() []ScheduleInfo
+	Play(device, mode string) error
+	Stop(device string) error
+	Eject(device string) error
+	Rescan() error
+	Reload() error
+	// Trigger plays uri the way a schedule would
+	Trigger(uri, mode string) error
+	// Rip rips a disc into the MPD library in the background
+	Rip(device string) error
+	CancelRip(device string) error
+	// Verify checks a disc against the AccurateRip database, returning its
+	// report once every track is read
+	Verify(device string) (*VerifyReport, error)
+	// Import copies the audio files of a USB drive into the MPD library in
+	// the background
+	Import(device string) error
+	CancelImport(device string) error
+}
+
+// Handle runs a request against the controller.
+func Handle(c Controller, req Request) Response {
+	var data interface{}
+	var err error
+
+	switch req.Command {
+	case CommandStatus:
+		data, err = c.Status()
+	case CommandListDevices:
+		data = c.Devices()
+	case CommandPlay:
+		err = c.Play(optionalArg(req.Args), req.Mode)
+	case CommandStop:
+		err = c.Stop(optionalArg(req.Args))
+	case CommandEject:
+		err = c.Eject(optionalArg(req.Args))
+	case CommandRip:
+		err = c.Rip(optionalArg(req.Args))
+	case CommandCancelRip:
+		err = c.CancelRip(optionalArg(req.Args))
+	case CommandVerify:
+		data, err = c.Verify(optionalArg(req.Args))
+	case CommandImport:
+		err = c.Import(optionalArg(req.Args))
+	case CommandCancelImport:
+		err = c.CancelImport(optionalArg(req.Args))
+	case CommandRescan:
+		err = c.Rescan()
+	case CommandReload:
+		err = c.Reload()
+	case CommandTrigger:
+		if len(req.Args) == 0 {
+			err = fmt.Errorf("%s requires an URI", req.Command)
+			break
+		}
+		err = c.Trigger(req.Args[0], req.Mode)
+	default:
+		err = fmt.Errorf("unknown command: %s", req.Command)
+	}
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	return newResponse(data)
+}
+
+func newResponse(data interface{}) Response {
+	if data == nil {
+		return Response{OK: true}
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("failed to encode response: %v", err)}
+	}
+	return Response{OK: true, Data: raw}
+}
+
+func optionalArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
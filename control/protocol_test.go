@@ -0,0 +1,31 @@
+package control
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHandleVerify(t *testing.T) {
+	controller := newFakeController()
+
+	resp := Handle(controller, Request{Command: CommandVerify, Args: []string{"/dev/sr0"}})
+	if !resp.OK {
+		t.Fatalf("verify failed: %s", resp.Error)
+	}
+	var report VerifyReport
+	if err := json.Unmarshal(resp.Data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Device != "/dev/sr0" || report.Pressings != 2 || len(report.Tracks) != 2 {
+		t.Fatalf("report = %s", resp.Data)
+	}
+	if track := report.Tracks[0]; track.Status != "accurate" || track.Confidence != 5 {
+		t.Fatalf("track 1 = %+v", track)
+	}
+
+	resp = Handle(controller, Request{Command: CommandVerify, Args: []string{"/dev/broken"}})
+	if resp.OK || resp.Error == "" || resp.Data != nil {
+		t.Fatalf("verify of an unknown device = %+v", resp)
+	}
+	controller.waitActions(t, "verify /dev/sr0 ", "verify /dev/broken ")
+}
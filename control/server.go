@@ -0,0 +1,109 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Server answers control requests on a unix socket.
+type Server struct {
+	path       string
+	controller Controller
+	listener   net.Listener
+	wg         sync.WaitGroup
+}
+
+func NewServer(path string, controller Controller) (*Server, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		log.Printf("warning: failed to restrict control socket permissions: %v", err)
+	}
+	return &Server{
+		path:       path,
+		controller: controller,
+		listener:   listener,
+	}, nil
+}
+
+// Serve accepts connections until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		if err := s.listener.Close(); err != nil {
+			log.Printf("warning: failed to close control socket: %v", err)
+		}
+	}()
+
+	log.Printf("Control socket listening on %s", s.path)
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Control socket accept error: %v", err)
+			}
+			break
+		}
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+	s.wg.Wait()
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("warning: failed to close control connection: %v", err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req Request
+		resp := Response{}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %v", err)
+		} else {
+			log.Printf("[control] %s %v", req.Command, req.Args)
+			resp = Handle(s.controller, req)
+		}
+		if err := encoder.Encode(resp); err != nil {
+			log.Printf("Failed to send control response: %v", err)
+			return
+		}
+	}
+}
+
+// removeStaleSocket removes a socket left over by a previous run, and fails
+// if another daemon is still listening on it.
+func removeStaleSocket(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("control socket %s already in use, is another instance running?", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale control socket %s: %w", path, err)
+	}
+	return nil
+}
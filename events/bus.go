@@ -0,0 +1,106 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	DeviceAdded      Type = "device_added"
+	DeviceRemoved    Type = "device_removed"
+	HandlerSucceeded Type = "handler_succeeded"
+	HandlerFailed    Type = "handler_failed"
+	ScheduleFired    Type = "schedule_fired"
+	ScheduleFailed   Type = "schedule_failed"
+	CommandFailed    Type = "command_failed"
+	RipStarted       Type = "rip_started"
+	RipProgress      Type = "rip_progress"
+	RipFinished      Type = "rip_finished"
+	RipCancelled     Type = "rip_cancelled"
+	RipFailed        Type = "rip_failed"
+	VerifyStarted    Type = "verify_started"
+	VerifyProgress   Type = "verify_progress"
+	VerifyFinished   Type = "verify_finished"
+	VerifyCancelled  Type = "verify_cancelled"
+	VerifyFailed     Type = "verify_failed"
+	ImportStarted    Type = "import_started"
+	ImportProgress   Type = "import_progress"
+	ImportFinished   Type = "import_finished"
+	ImportCancelled  Type = "import_cancelled"
+	ImportFailed     Type = "import_failed"
+)
+
+// Event is a typed payload published on the bus.
+// Fields not relevant to the event type are left empty.
+type Event struct {
+	Type     Type      `json:"type"`
+	Time     time.Time `json:"time"`
+	Device   string    `json:"device,omitempty"`
+	Kind     string    `json:"kind,omitempty"`
+	Action   string    `json:"action,omitempty"`
+	Schedule string    `json:"schedule,omitempty"`
+	URI      string    `json:"uri,omitempty"`
+	Report   string    `json:"report,omitempty"`
+	File     string    `json:"file,omitempty"`
+	Track    int       `json:"track,omitempty"`
+	Tracks   int       `json:"tracks,omitempty"`
+	Index    int       `json:"index,omitempty"`
+	Count    int       `json:"count,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Bus fans events out to its subscribers. Publishing never blocks: events
+// are dropped for subscribers whose buffer is full.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish sends ev to every subscriber, setting its time if missing.
+// A nil bus discards events.
+func (b *Bus) Publish(ev Event) {
+	if b == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			log.Printf("[events] Subscriber too slow, dropping %s event", ev.Type)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the events published from now on,
+// and a function to call to unsubscribe, which closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
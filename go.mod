@@ -5,11 +5,16 @@ go 1.25.0
 require (
 	github.com/b0bbywan/go-disc-cuer v0.4.0
 	github.com/ebitengine/oto/v3 v3.4.0
+	github.com/eclipse/paho.mqtt.golang v1.5.1
 	github.com/fhs/gompd/v2 v2.3.0
+	github.com/godbus/dbus/v5 v5.2.2
 	github.com/jfreymuth/pulse v0.1.2
 	github.com/jochenvg/go-udev v0.0.0-20240801134859-b65ed646224b
+	github.com/mochi-mqtt/server/v2 v2.7.9
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/spf13/viper v1.21.0
+	go.uploadedlobster.com/discid v0.9.0
+	go.yaml.in/yaml/v3 v3.0.4
 	golang.org/x/sys v0.47.0
 )
 
@@ -17,16 +22,19 @@ require (
 	github.com/ebitengine/purego v0.9.0 // indirect
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.4.0 // indirect
+	github.com/gorilla/websocket v1.5.3 // indirect
+	github.com/jinzhu/copier v0.3.5 // indirect
 	github.com/jkeiser/iter v0.0.0-20200628201005-c8aa0ae784d1 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.4 // indirect
+	github.com/rs/xid v1.4.0 // indirect
 	github.com/sagikazarmark/locafero v0.11.0 // indirect
 	github.com/sourcegraph/conc v0.3.1-0.20240121214520-5f936abd7ae8 // indirect
 	github.com/spf13/afero v1.15.0 // indirect
 	github.com/spf13/cast v1.10.0 // indirect
 	github.com/spf13/pflag v1.0.10 // indirect
 	github.com/subosito/gotenv v1.6.0 // indirect
-	go.uploadedlobster.com/discid v0.9.0 // indirect
 	go.uploadedlobster.com/mbtypes v0.4.0 // indirect
-	go.yaml.in/yaml/v3 v3.0.4 // indirect
-	golang.org/x/text v0.28.0 // indirect
+	golang.org/x/net v0.44.0 // indirect
+	golang.org/x/sync v0.17.0 // indirect
+	golang.org/x/text v0.29.0 // indirect
 )
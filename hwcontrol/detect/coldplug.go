@@ -0,0 +1,101 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jochenvg/go-udev"
+)
+
+// Scan returns a synthetic DeviceAdded event for every supported device
+// currently present, e.g. a disc left in the tray across a reboot.
+func (d *UdevDetector) Scan() ([]DeviceEvent, error) {
+	return scan(&udev.Udev{})
+}
+
+// announcePresent publishes the devices present at startup, marked as such.
+func (d *UdevDetector) announcePresent(ctx context.Context, u *udev.Udev, out chan<- DeviceEvent) error {
+	events, err := scan(u)
+	if err != nil {
+		return err
+	}
+	for _, ev := range events {
+		d.markColdplugged(ev.Device.Path())
+		ev.Startup = true
+		log.Printf("Found %s device %s at startup", ev.Device.Kind(), ev.Device.Path())
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- ev:
+		}
+	}
+	return nil
+}
+
+func scan(u *udev.Udev) ([]DeviceEvent, error) {
+	enum := u.NewEnumerate()
+	if err := enum.AddMatchSubsystem("block"); err != nil {
+		return nil, fmt.Errorf("failed to add enumerate filter: %w", err)
+	}
+	if err := enum.AddMatchIsInitialized(); err != nil {
+		return nil, fmt.Errorf("failed to add enumerate filter: %w", err)
+	}
+	devices, err := enum.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate block devices: %w", err)
+	}
+
+	var events []DeviceEvent
+	for _, dev := range devices {
+		if ev := presentEvent(detectDevice(dev)); ev != nil {
+			events = append(events, *ev)
+		}
+	}
+	return events, nil
+}
+
+// presentEvent returns the synthetic event announcing an enumerated device,
+// nil if the device holds nothing to play.
+func presentEvent(device Device) *DeviceEvent {
+	switch dev := device.(type) {
+	case *DiscDevice:
+		if !onAddDiscChecker(dev.Udev()) {
+			return nil
+		}
+	case *DataDiscDevice:
+		if !onAddDataDiscChecker(dev.Udev()) {
+			return nil
+		}
+	case *USBDevice:
+	default:
+		return nil
+	}
+	return &DeviceEvent{
+		Type:   DeviceAdded,
+		Device: device,
+	}
+}
+
+func (d *UdevDetector) markColdplugged(devnode string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending[devnode] = struct{}{}
+}
+
+// isColdplugged reports whether a live event duplicates a coldplug announcement.
+// Only the first live event of a coldplugged device is checked: an add event
+// racing the enumeration is dropped, anything else clears the entry.
+func (d *UdevDetector) isColdplugged(devnode string, ev *DeviceEvent) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.pending[devnode]; !ok {
+		return false
+	}
+	delete(d.pending, devnode)
+	if ev != nil && ev.Type == DeviceAdded {
+		log.Printf("Skipping %s event for %s, already handled at startup", ev.Type, devnode)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package detect
+
+// DataDiscDevice is an optical disc holding files, e.g. a burned MP3 CD or a
+// data DVD, played like a USB drive once mounted.
+type DataDiscDevice struct {
+	path string
+	udev UdevDevice
+}
+
+func (d *DataDiscDevice) Path() string {
+	return d.path
+}
+
+func (d *DataDiscDevice) Kind() DeviceKind {
+	return DeviceDataDisc
+}
+
+func (d *DataDiscDevice) Udev() UdevDevice {
+	return d.udev
+}
+
+func (d *DataDiscDevice) DetectEvent() EventType {
+	if !checkDiscChange(d.Udev().Action()) {
+		return InvalidEvent
+	}
+	if onRemoveDiscChecker(d.Udev()) {
+		return DeviceRemoved
+	}
+	if onAddDataDiscChecker(d.Udev()) {
+		return DeviceAdded
+	}
+	return InvalidEvent
+}
+
+// onAddDataDiscChecker verifies that the inserted disc has a filesystem.
+func onAddDataDiscChecker(device UdevDevice) bool {
+	if device.Action() == EventRemove {
+		return false
+	}
+	return isDataDiscFs(device.PropertyValue("ID_FS_TYPE"))
+}
+
+// dataDiscPreChecker accepts optical devices holding a filesystem and no
+// audio track. Mixed mode discs are audio discs, their data session being
+// exposed by the disc handler.
+func dataDiscPreChecker(device UdevDevice) bool {
+	if device == nil ||
+		device.PropertyValue("ID_CDROM") != "1" ||
+		device.PropertyValue("ID_CDROM_MEDIA_TRACK_COUNT_AUDIO") != "" {
+		return false
+	}
+	return isDataDiscFs(device.PropertyValue("ID_FS_TYPE"))
+}
+
+func isDataDiscFs(fsType string) bool {
+	return fsType == "iso9660" || fsType == "udf"
+}
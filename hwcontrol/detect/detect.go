@@ -4,17 +4,22 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 
 	"github.com/jochenvg/go-udev"
 )
 
 // UdevDetector écoute les events udev et publie des DeviceEvent
 type UdevDetector struct {
-	// éventuellement des filtres spécifiques
+	// pending holds devnodes announced by coldplug, awaiting their first live event
+	pending map[string]struct{}
+	mu      sync.Mutex
 }
 
 func NewUdevDetector() *UdevDetector {
-	return &UdevDetector{}
+	return &UdevDetector{
+		pending: make(map[string]struct{}),
+	}
 }
 
 func (d *UdevDetector) Run(ctx context.Context, out chan<- DeviceEvent) error {
@@ -24,11 +29,17 @@ func (d *UdevDetector) Run(ctx context.Context, out chan<- DeviceEvent) error {
 		return fmt.Errorf("failed to add filter: %w", err)
 	}
 
+	// Subscribe before enumerating so no event is lost in between,
+	// duplicates are filtered out by isColdplugged
 	deviceChan, errChan, err := monitor.DeviceChan(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to create device channel: %w", err)
 	}
 
+	if err := d.announcePresent(ctx, &u, out); err != nil {
+		log.Printf("Failed to enumerate present devices: %v", err)
+	}
+
 	log.Println("Listening for udev events...")
 
 	for {
@@ -36,12 +47,29 @@ func (d *UdevDetector) Run(ctx context.Context, out chan<- DeviceEvent) error {
 		case <-ctx.Done():
 			log.Println("Detector stopping due to context cancellation")
 			return nil
-		case device := <-deviceChan:
-			ev := d.convert(device)
+		case device, ok := <-deviceChan:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("udev monitor closed")
+			}
+			if device == nil {
+				continue
+			}
+			ev := newDeviceEvent(device)
+			if d.isColdplugged(device.Devnode(), ev) {
+				continue
+			}
 			if ev != nil {
 				out <- *ev
 			}
-		case err := <-errChan:
+		case err, ok := <-errChan:
+			if !ok {
+				// a closed channel would be selected forever
+				errChan = nil
+				continue
+			}
 			if err != nil {
 				log.Printf("udev monitor error: %v", err)
 			}
@@ -49,8 +77,10 @@ func (d *UdevDetector) Run(ctx context.Context, out chan<- DeviceEvent) error {
 	}
 }
 
-func (d *UdevDetector) convert(dev *udev.Device) *DeviceEvent {
-	device := d.detectDevice(dev)
+// newDeviceEvent converts a udev device into an event, nil if it is not
+// supported or not relevant.
+func newDeviceEvent(dev UdevDevice) *DeviceEvent {
+	device := detectDevice(dev)
 	if device == nil {
 		return nil
 	}
@@ -66,10 +96,13 @@ func (d *UdevDetector) convert(dev *udev.Device) *DeviceEvent {
 	}
 }
 
-func (d *UdevDetector) detectDevice(dev *udev.Device) Device {
+func detectDevice(dev UdevDevice) Device {
 	if discPreChecker(dev) {
 		return &DiscDevice{path: dev.Devnode(), udev: dev}
 	}
+	if dataDiscPreChecker(dev) {
+		return &DataDiscDevice{path: dev.Devnode(), udev: dev}
+	}
 	if usbPreChecker(dev) {
 		return &USBDevice{path: dev.Devnode(), udev: dev}
 	}
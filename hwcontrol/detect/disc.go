@@ -2,13 +2,11 @@ package detect
 
 import (
 	"log"
-
-	"github.com/jochenvg/go-udev"
 )
 
 type DiscDevice struct {
 	path string
-	udev *udev.Device
+	udev UdevDevice
 }
 
 func (d *DiscDevice) Path() string {
@@ -19,7 +17,7 @@ func (d *DiscDevice) Kind() DeviceKind {
 	return DeviceDisc
 }
 
-func (d *DiscDevice) Udev() *udev.Device {
+func (d *DiscDevice) Udev() UdevDevice {
 	return d.udev
 }
 
@@ -37,7 +35,7 @@ func (d *DiscDevice) DetectEvent() EventType {
 }
 
 // onRemoveDiscChecker checks if a disc removal was requested.
-func onRemoveDiscChecker(device *udev.Device) bool {
+func onRemoveDiscChecker(device UdevDevice) bool {
 	if device.Action() == EventRemove {
 		return true
 	}
@@ -46,7 +44,7 @@ func onRemoveDiscChecker(device *udev.Device) bool {
 }
 
 // onAddDiscChecker verifies that the inserted disc has audio tracks.
-func onAddDiscChecker(device *udev.Device) bool {
+func onAddDiscChecker(device UdevDevice) bool {
 	if device.Action() == EventRemove {
 		return false
 	}
@@ -55,13 +53,14 @@ func onAddDiscChecker(device *udev.Device) bool {
 }
 
 // discPreChecker ensures the device is valid and matches the target device.
-func discPreChecker(device *udev.Device) bool {
-	if device == nil ||
-		device.PropertyValue("ID_CDROM") != "1" ||
-		device.PropertyValue("ID_FS_TYPE") != "" {
+// Discs with a filesystem are only accepted with audio tracks, mixed mode
+// discs being played as audio discs.
+func discPreChecker(device UdevDevice) bool {
+	if device == nil || device.PropertyValue("ID_CDROM") != "1" {
 		return false
 	}
-	return true
+	return device.PropertyValue("ID_FS_TYPE") == "" ||
+		device.PropertyValue("ID_CDROM_MEDIA_TRACK_COUNT_AUDIO") != ""
 }
 
 // checkDiscChange validates that the action is handled.
@@ -0,0 +1,125 @@
+package detect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ReplaySource publishes events from recorded udev property dumps instead of
+// listening to udev, so the dispatch path can run without hardware.
+//
+// The recording is a JSON or YAML list of events, each holding the udev
+// properties of the device, as printed by `udevadm monitor --udev --property`,
+// an optional delay to wait before publishing it, and whether the device was
+// already present at startup.
+type ReplaySource struct {
+	path string
+}
+
+// Recording is a single recorded udev event.
+type Recording struct {
+	Delay      string            `json:"delay" yaml:"delay"`
+	Startup    bool              `json:"startup" yaml:"startup"`
+	Properties map[string]string `json:"properties" yaml:"properties"`
+}
+
+func NewReplaySource(path string) *ReplaySource {
+	return &ReplaySource{path: path}
+}
+
+func (r *ReplaySource) Run(ctx context.Context, out chan<- DeviceEvent) error {
+	recordings, err := LoadRecordings(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to load recordings: %w", err)
+	}
+
+	log.Printf("Replaying %d udev events from %s", len(recordings), r.path)
+	for i, rec := range recordings {
+		delay, err := rec.delay()
+		if err != nil {
+			return fmt.Errorf("invalid recording %d: %w", i, err)
+		}
+		select {
+		case <-ctx.Done():
+			log.Println("Replay stopping due to context cancellation")
+			return nil
+		case <-time.After(delay):
+		}
+
+		ev := newDeviceEvent(RecordedDevice(rec.Properties))
+		if ev == nil {
+			log.Printf("Skipping recording %d: unsupported device or event", i)
+			continue
+		}
+		ev.Startup = rec.Startup
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- *ev:
+		}
+	}
+	log.Printf("Replay of %s finished", r.path)
+	return nil
+}
+
+// LoadRecordings parses a recording file, YAML if its extension says so,
+// JSON otherwise.
+func LoadRecordings(path string) ([]Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var recordings []Recording
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &recordings)
+	default:
+		err = json.Unmarshal(data, &recordings)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return recordings, nil
+}
+
+func (r Recording) delay() (time.Duration, error) {
+	if r.Delay == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(r.Delay)
+}
+
+// RecordedDevice is a udev device rebuilt from its recorded properties.
+type RecordedDevice map[string]string
+
+func (r RecordedDevice) Action() string {
+	return r["ACTION"]
+}
+
+func (r RecordedDevice) Devnode() string {
+	return r["DEVNAME"]
+}
+
+func (r RecordedDevice) Sysname() string {
+	if devpath := r["DEVPATH"]; devpath != "" {
+		return filepath.Base(devpath)
+	}
+	return filepath.Base(r.Devnode())
+}
+
+func (r RecordedDevice) PropertyValue(key string) string {
+	return r[key]
+}
+
+func (r RecordedDevice) Properties() map[string]string {
+	return r
+}
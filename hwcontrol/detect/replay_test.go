@@ -0,0 +1,102 @@
+package detect
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+type replayed struct {
+	Type EventType
+	Kind DeviceKind
+	Path string
+}
+
+func replay(t *testing.T, recording string) []replayed {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out := make(chan DeviceEvent)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out)
+		errc <- NewReplaySource(filepath.Join("testdata", recording)).Run(ctx, out)
+	}()
+	var got []replayed
+	for ev := range out {
+		got = append(got, replayed{ev.Type, ev.Device.Kind(), ev.Device.Path()})
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("replay timed out")
+	}
+	return got
+}
+
+func TestReplaySource(t *testing.T) {
+	tests := []struct {
+		recording string
+		want      []replayed
+	}{
+		{"usb_stick.yaml", []replayed{
+			{DeviceAdded, DeviceUSB, "/dev/sda1"},
+			{DeviceRemoved, DeviceUSB, "/dev/sda1"},
+		}},
+		{"data_disc.yaml", []replayed{
+			{DeviceAdded, DeviceDataDisc, "/dev/sr0"},
+			{DeviceRemoved, DeviceDataDisc, "/dev/sr0"},
+		}},
+		{"audio_disc.yaml", []replayed{
+			{DeviceAdded, DeviceDisc, "/dev/sr0"},
+			{DeviceRemoved, DeviceDisc, "/dev/sr0"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.recording, func(t *testing.T) {
+			if got := replay(t, tt.recording); !slices.Equal(got, tt.want) {
+				t.Fatalf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayedDeviceKeepsProperties(t *testing.T) {
+	recordings, err := LoadRecordings(filepath.Join("testdata", "usb_stick.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := RecordedDevice(recordings[1].Properties)
+	if dev.Devnode() != "/dev/sda1" || dev.Sysname() != "sda1" || dev.Action() != EventAdd {
+		t.Fatalf("device = %s %s %s", dev.Devnode(), dev.Sysname(), dev.Action())
+	}
+	if uuid := dev.PropertyValue("ID_FS_UUID"); uuid != "1234-ABCD" {
+		t.Fatalf("ID_FS_UUID = %q", uuid)
+	}
+	if delay, err := recordings[2].delay(); err != nil || delay != 10*time.Millisecond {
+		t.Fatalf("delay = %v, %v", delay, err)
+	}
+}
+
+func TestReplaySourceRejectsBadRecordings(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"bad.json":   `{"properties": {}}`,
+		"delay.yaml": "- delay: soon\n  properties: {ACTION: add}\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewReplaySource(path).Run(context.Background(), make(chan DeviceEvent)); err == nil {
+			t.Errorf("%s: replay succeeded", name)
+		}
+	}
+	if err := NewReplaySource(filepath.Join(dir, "missing.yaml")).Run(context.Background(), nil); err == nil {
+		t.Error("replay of a missing recording succeeded")
+	}
+}
@@ -1,7 +1,7 @@
 package detect
 
 import (
-	"github.com/jochenvg/go-udev"
+	"context"
 )
 
 type DeviceKind string
@@ -17,18 +17,44 @@ const (
 	DeviceAdded   EventType = EventAdd
 	DeviceRemoved EventType = EventRemove
 
-	DeviceDisc DeviceKind = "disc"
-	DeviceUSB  DeviceKind = "usb"
+	DeviceDisc     DeviceKind = "disc"
+	DeviceDataDisc DeviceKind = "datadisc"
+	DeviceUSB      DeviceKind = "usb"
 )
 
+// EventSource publishes DeviceEvents until its context is cancelled or it runs
+// out of events.
+type EventSource interface {
+	Run(ctx context.Context, out chan<- DeviceEvent) error
+}
+
+// Scanner is implemented by sources able to list the devices currently
+// present, as DeviceAdded events.
+type Scanner interface {
+	Scan() ([]DeviceEvent, error)
+}
+
+// UdevDevice is the subset of udev device accessors the checkers rely on.
+// It is satisfied by *udev.Device and by RecordedDevice.
+type UdevDevice interface {
+	Action() string
+	Devnode() string
+	Sysname() string
+	PropertyValue(key string) string
+	Properties() map[string]string
+}
+
 type Device interface {
 	Path() string
 	Kind() DeviceKind
 	DetectEvent() EventType
-	Udev() *udev.Device
+	Udev() UdevDevice
 }
 
 type DeviceEvent struct {
 	Type   EventType
 	Device Device
+	// Startup marks a device found already present when the source started,
+	// rather than plugged in
+	Startup bool
 }
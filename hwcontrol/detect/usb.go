@@ -3,14 +3,12 @@ package detect
 import (
 	"log"
 
-	"github.com/jochenvg/go-udev"
-
 	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/mounts"
 )
 
 type USBDevice struct {
 	path string
-	udev *udev.Device
+	udev UdevDevice
 }
 
 func (d *USBDevice) Path() string {
@@ -21,7 +19,7 @@ func (d *USBDevice) Kind() DeviceKind {
 	return DeviceUSB
 }
 
-func (d *USBDevice) Udev() *udev.Device {
+func (d *USBDevice) Udev() UdevDevice {
 	return d.udev
 }
 
@@ -36,15 +34,15 @@ func (d *USBDevice) DetectEvent() EventType {
 	return InvalidEvent
 }
 
-func onRemoveUSBChecker(device *udev.Device, action string) bool {
+func onRemoveUSBChecker(device UdevDevice, action string) bool {
 	return action == EventRemove
 }
 
-func onAddUSBChecker(device *udev.Device, action string) bool {
+func onAddUSBChecker(device UdevDevice, action string) bool {
 	return action == EventAdd
 }
 
-func usbPreChecker(device *udev.Device) bool {
+func usbPreChecker(device UdevDevice) bool {
 	if device == nil ||
 		device.PropertyValue("ID_USB_DRIVER") != "usb-storage" ||
 		device.PropertyValue("DEVTYPE") != "partition" ||
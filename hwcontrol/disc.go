@@ -10,6 +10,8 @@ import (
 
 const (
 	CDROM_SET_SPEED      = 0x5322 // ioctl command for setting speed
+	CDROMEJECT           = 0x5309 // ioctl command for opening the tray
+	CDROM_LOCKDOOR       = 0x5329 // ioctl command for locking the tray
 	CDROM_PROC_FILE_INFO = "/proc/sys/dev/cdrom/info"
 )
 
@@ -35,3 +37,56 @@ func SetDiscSpeed(device string, speed int) error {
 
 	return nil
 }
+
+// openDrive opens device without waiting for a medium, so an empty drive can
+// be controlled too.
+func openDrive(device string) (*os.File, error) {
+	file, err := os.OpenFile(device, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open device: %w", err)
+	}
+	return file, nil
+}
+
+func closeDrive(file *os.File) {
+	if err := file.Close(); err != nil {
+		log.Printf("failed to close device file: %s", err)
+	}
+}
+
+// LockDoor locks the tray of device, or unlocks it, so that its eject button
+// is ignored.
+func LockDoor(device string, lock bool) error {
+	file, err := openDrive(device)
+	if err != nil {
+		return err
+	}
+	defer closeDrive(file)
+
+	value := 0
+	if lock {
+		value = 1
+	}
+	if err := unix.IoctlSetInt(int(file.Fd()), CDROM_LOCKDOOR, value); err != nil {
+		return fmt.Errorf("failed to lock door: %w", err)
+	}
+	return nil
+}
+
+// EjectDisc unlocks the tray of device and opens it. The drive must not be
+// in use anymore, MPD reading a track of the disc makes it fail as busy.
+func EjectDisc(device string) error {
+	file, err := openDrive(device)
+	if err != nil {
+		return err
+	}
+	defer closeDrive(file)
+
+	if err := unix.IoctlSetInt(int(file.Fd()), CDROM_LOCKDOOR, 0); err != nil {
+		log.Printf("failed to unlock door of %s: %s", device, err)
+	}
+	if err := unix.IoctlSetInt(int(file.Fd()), CDROMEJECT, 0); err != nil {
+		return fmt.Errorf("failed to eject: %w", err)
+	}
+	return nil
+}
@@ -36,3 +36,14 @@ func (c *protectedCache) GetCache(source string) (string, error) {
 	}
 	return "", fmt.Errorf("%s mount point does not exist in cache", source)
 }
+
+// Snapshot returns a copy of the cache content.
+func (c *protectedCache) Snapshot() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshot := make(map[string]string, len(c.cache))
+	for k, v := range c.cache {
+		snapshot[k] = v
+	}
+	return snapshot
+}
@@ -0,0 +1,188 @@
+package mounts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// kernelMountFlags mount filesystems read-only, MPD only reading them.
+const kernelMountFlags = unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC
+
+// kernelFilesystem is how a filesystem type reported by udev is mounted.
+type kernelFilesystem struct {
+	// Type is the kernel filesystem type
+	Type string
+	// Options are the charset options, so names read as UTF-8, and the
+	// permissions of filesystems without owners, so MPD can read them
+	Options string
+}
+
+var kernelFilesystems = map[string]kernelFilesystem{
+	"vfat":    {Type: "vfat", Options: "iocharset=utf8,shortname=mixed,umask=0022"},
+	"exfat":   {Type: "exfat", Options: "iocharset=utf8,umask=0022"},
+	"ntfs":    {Type: "ntfs3", Options: "iocharset=utf8,umask=0022"},
+	"ntfs3":   {Type: "ntfs3", Options: "iocharset=utf8,umask=0022"},
+	"ext4":    {Type: "ext4"},
+	"ext3":    {Type: "ext3"},
+	"ext2":    {Type: "ext2"},
+	"iso9660": {Type: "iso9660", Options: "iocharset=utf8"},
+	"udf":     {Type: "udf", Options: "iocharset=utf8"},
+}
+
+// kernelMounter mounts filesystems itself with mount(2), read-only in the
+// MPD USB folder of the library, for systems without any automounter.
+type kernelMounter struct {
+	usbFolder string
+	namer     *mountNamer
+	// mounted are the mount points of the devices mounted by the player
+	mounted *protectedCache
+}
+
+func newKernelMounter(mpdLibraryFolder, mpdUSBFolder string, namer *mountNamer) (*kernelMounter, error) {
+	if err := checkMountCapability(); err != nil {
+		return nil, err
+	}
+	return &kernelMounter{
+		usbFolder: filepath.Join(mpdLibraryFolder, mpdUSBFolder),
+		namer:     namer,
+		mounted:   newCache(),
+	}, nil
+}
+
+// checkMountCapability fails unless the process may call mount(2).
+func checkMountCapability() error {
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&header, &data[0]); err != nil {
+		return fmt.Errorf("failed to read process capabilities: %w", err)
+	}
+	if data[unix.CAP_SYS_ADMIN/32].Effective&(1<<(unix.CAP_SYS_ADMIN%32)) == 0 {
+		return fmt.Errorf("MountConfig kernel requires the CAP_SYS_ADMIN capability to mount drives: run mpd-discplayer as root, or grant it with AmbientCapabilities=CAP_SYS_ADMIN in its systemd unit")
+	}
+	return nil
+}
+
+// validate and clear have nothing to do, drives being mounted in the
+// library already.
+func (k *kernelMounter) validate(device BlockDevice, mountpoint, target string) (string, error) {
+	return mountpoint, nil
+}
+
+func (k *kernelMounter) clear(device BlockDevice, mountpoint string) (string, error) {
+	return mountpoint, nil
+}
+
+func (k *kernelMounter) mount(device BlockDevice) (string, error) {
+	devnode := device.Devnode()
+	if mountPoint, err := seekMountPoint(devnode); err == nil && strings.HasPrefix(mountPoint, k.usbFolder+"/") {
+		log.Printf("%s already mounted at %s", devnode, mountPoint)
+		k.mounted.AddCache(devnode, mountPoint)
+		return mountPoint, nil
+	}
+	fsType := device.PropertyValue("ID_FS_TYPE")
+	fs, ok := kernelFilesystems[fsType]
+	if !ok {
+		fs = kernelFilesystem{Type: fsType}
+	}
+	if fs.Type == "" {
+		return "", fmt.Errorf("unknown filesystem on %s", devnode)
+	}
+	target := k.target(device)
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create mount point %s: %w", target, err)
+	}
+	if err := unix.Mount(devnode, target, fs.Type, kernelMountFlags, fs.Options); err != nil {
+		if rmErr := os.Remove(target); rmErr != nil {
+			log.Printf("warning: failed to remove mount point %s: %v", target, rmErr)
+		}
+		return "", fmt.Errorf("failed to mount %s (%s) at %s: %w", devnode, fs.Type, target, err)
+	}
+	k.mounted.AddCache(devnode, target)
+	log.Printf("Mounted %s (%s) read-only at %s", devnode, fs.Type, target)
+	return target, nil
+}
+
+// target returns the mount point of device, a free folder of the MPD USB
+// folder named after it.
+func (k *kernelMounter) target(device BlockDevice) string {
+	name := k.namer.unique(device, func(name string) bool {
+		return !isFreeMountPoint(filepath.Join(k.usbFolder, name))
+	})
+	return filepath.Join(k.usbFolder, name)
+}
+
+// isFreeMountPoint reports whether path is missing, or an empty folder where
+// nothing is mounted.
+func isFreeMountPoint(path string) bool {
+	entries, err := os.ReadDir(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	if err != nil || len(entries) > 0 {
+		return false
+	}
+	mounted := false
+	if err := readMountsFile(func(device, mountPoint string) {
+		mounted = mounted || mountPoint == path
+	}); err != nil {
+		return false
+	}
+	return !mounted
+}
+
+// unmount detaches the filesystem lazily, so files still open, e.g. the song
+// MPD is reading, don't make it fail, then removes the mount point. Nothing
+// is lost as the filesystem is read-only.
+func (k *kernelMounter) unmount(device BlockDevice) error {
+	devnode := device.Devnode()
+	target, err := k.mounted.GetCache(devnode)
+	if err != nil {
+		return nil
+	}
+	defer k.mounted.RemoveCache(devnode)
+	return detach(devnode, target)
+}
+
+func (k *kernelMounter) owns(devnode string) bool {
+	_, err := k.mounted.GetCache(devnode)
+	return err == nil
+}
+
+// restore takes back the unmount of a recorded mount, still mounted.
+func (k *kernelMounter) restore(record mountRecord) error {
+	k.mounted.AddCache(record.Device, record.MountPoint)
+	return nil
+}
+
+// release detaches the recorded mount of a drive removed while the player
+// was not running, or only removes its mount point if the system restarted
+// meanwhile.
+func (k *kernelMounter) release(record mountRecord) error {
+	if !strings.HasPrefix(record.MountPoint, k.usbFolder+"/") {
+		return fmt.Errorf("mount point %s not in the USB folder, skipping cleanup", record.MountPoint)
+	}
+	if isMountedAt(record.Device, record.MountPoint) {
+		return detach(record.Device, record.MountPoint)
+	}
+	if err := os.Remove(record.MountPoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove mount point %s: %w", record.MountPoint, err)
+	}
+	return nil
+}
+
+func detach(devnode, target string) error {
+	if err := unix.Unmount(target, unix.MNT_DETACH); err != nil && !errors.Is(err, unix.EINVAL) {
+		return fmt.Errorf("failed to unmount %s from %s: %w", devnode, target, err)
+	}
+	if err := os.Remove(target); err != nil {
+		return fmt.Errorf("failed to remove mount point %s: %w", target, err)
+	}
+	log.Printf("Unmounted %s from %s", devnode, target)
+	return nil
+}
@@ -1,15 +1,16 @@
 package mounts
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/jochenvg/go-udev"
-
 	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/state"
 )
 
 const (
@@ -20,51 +21,124 @@ const (
 type MountManager struct {
 	config      *MountConfig
 	mountPoints *protectedCache
+	relPaths    *protectedCache
 	mounter     Mounter
+	records     *mountRecords
+	namer       *mountNamer
+}
+
+// BlockDevice is the subset of udev device accessors mounters rely on.
+type BlockDevice interface {
+	Devnode() string
+	PropertyValue(key string) string
 }
 
 type Mounter interface {
-	validate(device *udev.Device, mountpoint, target string) (string, error)
-	clear(device *udev.Device, target string) (string, error)
+	validate(device BlockDevice, mountpoint, target string) (string, error)
+	clear(device BlockDevice, target string) (string, error)
+	// restore takes back the recorded mount of a present device, failing if
+	// it was lost
+	restore(record mountRecord) error
+	// release cleans up what is left of a recorded mount not restored
+	release(record mountRecord) error
 }
 
 type MountConfig struct {
 	MPDLibraryFolder string
 	MPDUSBSubFolder  string
 	Method           string
+	// Names are the mount names pinned by filesystem UUID
+	Names map[string]string
 }
 
-func NewMountManager(config *MountConfig, client *mpdplayer.ReconnectingMPDClient) (*MountManager, error) {
-	mounter, err := newMounter(config, client)
+// NewMountManager returns the mount manager, records keeping its mounts
+// across restarts.
+func NewMountManager(config *MountConfig, client *mpdplayer.ReconnectingMPDClient, records *state.Store) (*MountManager, error) {
+	namer := newMountNamer(config.Names)
+	mounter, err := newMounter(config, client, namer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Mounter: %w", err)
 	}
 	m := &MountManager{
 		config:      config,
 		mountPoints: newCache(),
+		relPaths:    newCache(),
 		mounter:     mounter,
+		records:     loadMountRecords(records),
+		namer:       namer,
 	}
 	populateMountPointCache(m)
 	return m, nil
 }
 
-func NewMountConfig(base, sub, method string) *MountConfig {
+func NewMountConfig(base, sub, method string, names map[string]string) *MountConfig {
 	return &MountConfig{
 		MPDLibraryFolder: base,
 		MPDUSBSubFolder:  sub,
 		Method:           method,
+		Names:            names,
 	}
 }
 
-func (m *MountManager) Mount(device *udev.Device) (string, error) {
+func (m *MountManager) Mount(device BlockDevice) (string, error) {
+	if relPath, ok := m.mounted(device); ok {
+		return relPath, nil
+	}
 	mountPoint, err := m.FindDevicePathAndCache(device)
 	if err != nil {
 		return "", fmt.Errorf("failed to find a mountpoint for %s while mounting: %w", device.Devnode(), err)
 	}
-	return m.FindRelPath(mountPoint)
+	relPath, err := m.FindRelPath(mountPoint)
+	if err != nil {
+		return "", err
+	}
+	m.relPaths.AddCache(device.Devnode(), relPath)
+	m.record(device, mountPoint)
+	return relPath, nil
 }
 
-func (m *MountManager) Unmount(device *udev.Device) (string, error) {
+// mounted returns the path of a device recorded as mounted, e.g. restored
+// at startup.
+func (m *MountManager) mounted(device BlockDevice) (string, bool) {
+	record, ok := m.records.get(device.Devnode())
+	if !ok || record.UUID != device.PropertyValue("ID_FS_UUID") {
+		return "", false
+	}
+	relPath, err := m.relPaths.GetCache(device.Devnode())
+	return relPath, err == nil
+}
+
+// record saves the mount of device at target, to take it back after a
+// restart.
+func (m *MountManager) record(device BlockDevice, target string) {
+	devnode := device.Devnode()
+	mountPoint, err := m.mountPoints.GetCache(devnode)
+	if err != nil {
+		mountPoint = target
+	}
+	record := mountRecord{
+		Device:     devnode,
+		UUID:       device.PropertyValue("ID_FS_UUID"),
+		Label:      device.PropertyValue("ID_FS_LABEL"),
+		MountPoint: mountPoint,
+		Target:     target,
+		Method:     m.config.Method,
+		Time:       time.Now(),
+	}
+	if self, ok := m.mounter.(selfMounter); ok {
+		record.Owned = self.owns(devnode)
+	}
+	if mpd, ok := m.mounter.(*mpdFinder); ok {
+		record.Storage = mpd.storage(devnode)
+	}
+	if err := m.records.add(record); err != nil {
+		log.Printf("warning: failed to record mount of %s: %v", devnode, err)
+	}
+}
+
+func (m *MountManager) Unmount(device BlockDevice) (string, error) {
+	defer m.forget(device.Devnode())
+	defer m.relPaths.RemoveCache(device.Devnode())
 	mountPoint, err := m.SeekMountPointAndClearCache(device)
 	if err != nil {
 		return "", fmt.Errorf("failed to find a mountpoint for %s while unmounting: %w", device.Devnode(), err)
@@ -72,6 +146,95 @@ func (m *MountManager) Unmount(device *udev.Device) (string, error) {
 	return m.FindRelPath(mountPoint)
 }
 
+func (m *MountManager) forget(devnode string) {
+	if err := m.records.remove(devnode); err != nil {
+		log.Printf("warning: failed to forget mount of %s: %v", devnode, err)
+	}
+}
+
+// Reconcile takes back the mounts recorded before the player restarted,
+// against the devices present: the mounts of present devices still mounted
+// are restored, what is left of the others is cleaned up.
+func (m *MountManager) Reconcile(present []BlockDevice) {
+	devices := make(map[string]BlockDevice, len(present))
+	for _, device := range present {
+		devices[device.Devnode()] = device
+	}
+	for _, record := range m.records.list() {
+		device, ok := devices[record.Device]
+		switch {
+		case record.Method != m.config.Method:
+			log.Printf("Forgetting %s mount of %s, mounts are made with %s now", record.Method, record.Device, m.config.Method)
+		case !ok || device.PropertyValue("ID_FS_UUID") != record.UUID:
+			log.Printf("%s was removed, releasing its mount %s", record.Device, record.Target)
+			m.release(record)
+		default:
+			err := m.restore(record)
+			if err == nil {
+				log.Printf("Restored mount %s of %s", record.Target, record.Device)
+				continue
+			}
+			log.Printf("Mount %s of %s lost, releasing it: %v", record.Target, record.Device, err)
+			m.release(record)
+		}
+		m.forget(record.Device)
+	}
+}
+
+// restore takes back the recorded mount of a present device, checking the
+// system still mounts it at the recorded mount point.
+func (m *MountManager) restore(record mountRecord) error {
+	if m.config.Method != "mpd" && !isMountedAt(record.Device, record.MountPoint) {
+		return fmt.Errorf("%s is not mounted at %s anymore", record.Device, record.MountPoint)
+	}
+	if err := m.mounter.restore(record); err != nil {
+		return err
+	}
+	relPath, err := m.FindRelPath(record.Target)
+	if err != nil {
+		return err
+	}
+	m.mountPoints.AddCache(record.Device, record.MountPoint)
+	m.relPaths.AddCache(record.Device, relPath)
+	return nil
+}
+
+func (m *MountManager) release(record mountRecord) {
+	if err := m.mounter.release(record); err != nil {
+		log.Printf("warning: %v", err)
+	}
+}
+
+// RelPath returns the path of a mounted device relative to the MPD library.
+func (m *MountManager) RelPath(devnode string) (string, error) {
+	return m.relPaths.GetCache(devnode)
+}
+
+// Mounts returns the mounted devices and their path relative to the MPD library.
+func (m *MountManager) Mounts() map[string]string {
+	return m.relPaths.Snapshot()
+}
+
+// Name returns the name of device as its mounts are named: the name pinned
+// in the configuration or its label, made a safe path element, with a suffix
+// from its UUID when taken reports the name as used by another drive.
+func (m *MountManager) Name(device BlockDevice, taken func(name string) bool) string {
+	return m.namer.unique(device, taken)
+}
+
+// MountPoint returns where the filesystem of a mounted device is on the
+// system, to read files MPD doesn't know about. Drives mounted by MPD are
+// mounted on the system by udisks.
+func (m *MountManager) MountPoint(devnode string) (string, error) {
+	if mountPoint, err := seekMountPoint(devnode); err == nil {
+		return mountPoint, nil
+	}
+	if m.config.Method == "mpd" {
+		return "", fmt.Errorf("%s is not mounted on the system", devnode)
+	}
+	return m.mountPoints.GetCache(devnode)
+}
+
 func (m *MountManager) FindRelPath(mountPoint string) (string, error) {
 	relPath, err := filepath.Rel(m.config.MPDLibraryFolder, mountPoint)
 	if err != nil {
@@ -80,7 +243,7 @@ func (m *MountManager) FindRelPath(mountPoint string) (string, error) {
 	return relPath, nil
 }
 
-func (m *MountManager) SeekMountPointAndClearCache(device *udev.Device) (string, error) {
+func (m *MountManager) SeekMountPointAndClearCache(device BlockDevice) (string, error) {
 	defer m.mountPoints.RemoveCache(device.Devnode())
 	if m.config.Method == "mpd" {
 		return m.unmountMPD(device)
@@ -88,34 +251,39 @@ func (m *MountManager) SeekMountPointAndClearCache(device *udev.Device) (string,
 	return m.unmountOS(device)
 }
 
-func (m *MountManager) unmountMPD(device *udev.Device) (string, error) {
-	return m.mounter.clear(device, "")
+func (m *MountManager) unmountMPD(device BlockDevice) (string, error) {
+	mountPoint, _ := m.mountPoints.GetCache(device.Devnode())
+	return m.mounter.clear(device, mountPoint)
 }
 
-func (m *MountManager) unmountOS(device *udev.Device) (string, error) {
+func (m *MountManager) unmountOS(device BlockDevice) (string, error) {
 	mountPoint, err := m.seekMountPointWithCacheFallback(device.Devnode())
 	if err != nil {
 		return "", fmt.Errorf("unknown device %s: %w", device.Devnode(), err)
 	}
 
-	if strings.HasPrefix(mountPoint, m.config.MPDLibraryFolder) {
-		return mountPoint, nil
+	if !strings.HasPrefix(mountPoint, m.config.MPDLibraryFolder) {
+		if mountPoint, err = m.mounter.clear(device, mountPoint); err != nil {
+			return "", fmt.Errorf("failed to unmount: %w", err)
+		}
 	}
-
-	if mountPoint, err = m.mounter.clear(device, mountPoint); err != nil {
-		return "", fmt.Errorf("failed to unmount: %w", err)
+	// a pulled drive can't be unmounted, its files still leave the queue
+	if self, ok := m.mounter.(selfMounter); ok {
+		if err := self.unmount(device); err != nil {
+			log.Printf("warning: %v", err)
+		}
 	}
 	return mountPoint, nil
 }
 
-func (m *MountManager) FindDevicePathAndCache(device *udev.Device) (string, error) {
+func (m *MountManager) FindDevicePathAndCache(device BlockDevice) (string, error) {
 	if m.config.Method == "mpd" {
 		return m.mountMPD(device)
 	}
 	return m.mountOS(device)
 }
 
-func (m *MountManager) mountMPD(device *udev.Device) (string, error) {
+func (m *MountManager) mountMPD(device BlockDevice) (string, error) {
 	validatedPath, err := m.mounter.validate(device, "", "")
 	if err != nil {
 		return "", fmt.Errorf("mounter validation failed: %w", err)
@@ -124,9 +292,9 @@ func (m *MountManager) mountMPD(device *udev.Device) (string, error) {
 	return validatedPath, nil
 }
 
-func (m *MountManager) mountOS(device *udev.Device) (string, error) {
+func (m *MountManager) mountOS(device BlockDevice) (string, error) {
 	devnode := device.Devnode()
-	mountPoint, err := m.findMountPointWithRetry(devnode, RetryTimeout, RetryInterval)
+	mountPoint, err := m.findMountPoint(device)
 	if err != nil {
 		return "", fmt.Errorf("error finding mountpoint for device %s: %w", devnode, err)
 	}
@@ -136,7 +304,7 @@ func (m *MountManager) mountOS(device *udev.Device) (string, error) {
 		return mountPoint, nil
 	}
 
-	target := generateTarget(m.config, mountPoint)
+	target := m.target(device)
 	validatedPath, err := m.mounter.validate(device, mountPoint, target)
 	if err != nil {
 		return "", fmt.Errorf("mounter validation failed: %w", err)
@@ -144,6 +312,46 @@ func (m *MountManager) mountOS(device *udev.Device) (string, error) {
 	return validatedPath, nil
 }
 
+// target returns the symlink of device in the MPD USB folder, named after it.
+func (m *MountManager) target(device BlockDevice) string {
+	folder := filepath.Join(m.config.MPDLibraryFolder, m.config.MPDUSBSubFolder)
+	name := m.namer.unique(device, func(name string) bool {
+		return isTakenTarget(filepath.Join(folder, name))
+	})
+	return filepath.Join(folder, name)
+}
+
+// isTakenTarget reports whether something is at path. A dead symlink, left
+// behind by a drive pulled while the player was down, is removed so the name
+// is free again.
+func isTakenTarget(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return true
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("warning: failed to remove dead symlink %s: %v", path, err)
+		return true
+	}
+	log.Printf("Removed dead symlink %s", path)
+	return false
+}
+
+// findMountPoint mounts the device when the mounter does it itself, or waits
+// for an automounter to mount it.
+func (m *MountManager) findMountPoint(device BlockDevice) (string, error) {
+	if self, ok := m.mounter.(selfMounter); ok {
+		return self.mount(device)
+	}
+	return m.findMountPointWithRetry(device.Devnode(), RetryTimeout, RetryInterval)
+}
+
 func (m *MountManager) findMountPointWithRetry(device string, timeout, interval time.Duration) (string, error) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -172,12 +380,16 @@ func (m *MountManager) seekMountPointWithCacheFallback(device string) (string, e
 	return "", fmt.Errorf("failed to find %s in mount file and cache", device)
 }
 
-func newMounter(config *MountConfig, client *mpdplayer.ReconnectingMPDClient) (Mounter, error) {
+func newMounter(config *MountConfig, client *mpdplayer.ReconnectingMPDClient, namer *mountNamer) (Mounter, error) {
 	switch config.Method {
 	case "symlink":
 		return newSymlinkFinder(config.MPDLibraryFolder, config.MPDUSBSubFolder), nil
 	case "mpd":
-		return newMpdFinder(client, config.MPDLibraryFolder)
+		return newMpdFinder(client, config.MPDLibraryFolder, namer), nil
+	case "udisks":
+		return newUdisksMounter(config.MPDLibraryFolder, config.MPDUSBSubFolder)
+	case "kernel":
+		return newKernelMounter(config.MPDLibraryFolder, config.MPDUSBSubFolder, namer)
 	default:
 		return nil, fmt.Errorf("unsupported mount type: %s", config.Method)
 	}
@@ -0,0 +1,36 @@
+package mounts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTargetTakesBackDeadSymlinks(t *testing.T) {
+	library := t.TempDir()
+	folder := filepath.Join(library, "USB")
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	m := &MountManager{config: NewMountConfig(library, "USB", "symlink", nil), namer: newMountNamer(nil)}
+
+	// left behind by a drive pulled while the player was down
+	dead := filepath.Join(folder, "MUSIC")
+	if err := os.Symlink(filepath.Join(library, "gone"), dead); err != nil {
+		t.Fatal(err)
+	}
+	if target := m.target(stick); target != dead {
+		t.Fatalf("target = %s, want the dead symlink name %s", target, dead)
+	}
+	if _, err := os.Lstat(dead); !os.IsNotExist(err) {
+		t.Fatalf("dead symlink left in place: %v", err)
+	}
+
+	// a live symlink keeps its name
+	if err := os.Symlink(library, dead); err != nil {
+		t.Fatal(err)
+	}
+	if target := m.target(stick); target == dead {
+		t.Fatal("target took the name of a live symlink")
+	}
+}
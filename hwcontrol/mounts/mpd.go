@@ -4,65 +4,80 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
+	"sync"
 	"time"
 
-	"github.com/jochenvg/go-udev"
-
 	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
 )
 
 type mpdFinder struct {
 	client           *mpdplayer.ReconnectingMPDClient
 	mpdLibraryFolder string
+	namer            *mountNamer
+	mu               sync.Mutex
+	// storages are the URIs of the storages mounted, by device
+	storages map[string]string
 }
 
-func newMpdFinder(client *mpdplayer.ReconnectingMPDClient, mpdLibraryFolder string) (*mpdFinder, error) {
-	if err := client.ClearMounts(); err != nil {
-		return nil, fmt.Errorf("failed to clear mounts: %w", err)
-	}
+func newMpdFinder(client *mpdplayer.ReconnectingMPDClient, mpdLibraryFolder string, namer *mountNamer) *mpdFinder {
 	return &mpdFinder{
 		client:           client,
 		mpdLibraryFolder: mpdLibraryFolder,
-	}, nil
+		namer:            namer,
+		storages:         make(map[string]string),
+	}
 }
 
-func (m *mpdFinder) validate(device *udev.Device, mountpoint, target string) (string, error) {
+func (m *mpdFinder) validate(device BlockDevice, mountpoint, target string) (string, error) {
 	return m.mount(device, mountpoint, target)
 }
 
-func (m *mpdFinder) clear(device *udev.Device, mountpoint string) (string, error) {
-	return m.unmount(device)
+func (m *mpdFinder) clear(device BlockDevice, mountpoint string) (string, error) {
+	return m.unmount(device, mountpoint)
 }
 
-func (m *mpdFinder) mount(device *udev.Device, mountpoint, target string) (string, error) {
+// mount mounts device in MPD under its name, suffixed if another drive is
+// mounted under it.
+func (m *mpdFinder) mount(device BlockDevice, mountpoint, target string) (string, error) {
 	identifiers := neighborIdentifiers(device)
-	label := deviceLabel(device)
-	if err := m.mountWithRetry(identifiers, label); err != nil {
+	label := m.namer.unique(device, m.isMounted)
+	storage, err := m.mountWithRetry(identifiers, label)
+	if err != nil {
 		return "", fmt.Errorf("failed to mount %s -> %s: %w", device.Devnode(), label, err)
 	}
+	m.mu.Lock()
+	m.storages[device.Devnode()] = storage
+	m.mu.Unlock()
 	return filepath.Join(m.mpdLibraryFolder, label), nil
 }
 
-func (m *mpdFinder) mountWithRetry(identifiers []string, label string) error {
+func (m *mpdFinder) mountWithRetry(identifiers []string, label string) (string, error) {
 	ticker := time.NewTicker(RetryInterval)
 	defer ticker.Stop()
 	timeoutChan := time.After(2 * RetryTimeout)
 
 	for {
-		err := m.client.Mount(identifiers, label)
+		storage, err := m.client.Mount(identifiers, label)
 		if err == nil {
-			return nil
+			return storage, nil
 		}
 		select {
 		case <-ticker.C:
 			log.Printf("Polling for neighbor matching %v...", identifiers)
 		case <-timeoutChan:
-			return err
+			return "", err
 		}
 	}
 }
 
-func neighborIdentifiers(device *udev.Device) []string {
+// storage returns the URI of the storage mounted for devnode, if any.
+func (m *mpdFinder) storage(devnode string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.storages[devnode]
+}
+
+func neighborIdentifiers(device BlockDevice) []string {
 	var ids []string
 	if uuid := device.PropertyValue("ID_FS_UUID"); uuid != "" {
 		ids = append(ids, uuid)
@@ -73,17 +88,76 @@ func neighborIdentifiers(device *udev.Device) []string {
 	return ids
 }
 
-func (m *mpdFinder) unmount(device *udev.Device) (string, error) {
-	label := deviceLabel(device)
+// unmount unmounts device from MPD, mounted at mountpoint in the library, or
+// under its name if unknown.
+func (m *mpdFinder) unmount(device BlockDevice, mountpoint string) (string, error) {
+	label := m.namer.name(device)
+	if mountpoint != "" {
+		label = filepath.Base(mountpoint)
+	}
 	if err := m.client.Unmount(label); err != nil {
 		return "", fmt.Errorf("failed to unmount %s: %w", device.Devnode(), err)
 	}
+	m.mu.Lock()
+	delete(m.storages, device.Devnode())
+	m.mu.Unlock()
 	return filepath.Join(m.mpdLibraryFolder, label), nil
 }
 
-func deviceLabel(device *udev.Device) string {
-	if label := device.PropertyValue("ID_FS_LABEL"); label != "" {
-		return label
+// restore checks MPD still has the recorded mount, as MPD forgets its mounts
+// when restarted, and that it is the recorded storage and not another one
+// mounted under the same name since.
+func (m *mpdFinder) restore(record mountRecord) error {
+	label := filepath.Base(record.Target)
+	storage, mounted, err := m.mounted(label)
+	if err != nil {
+		return err
+	}
+	if !mounted {
+		return fmt.Errorf("%s is not mounted in MPD anymore", label)
+	}
+	if record.Storage != "" && storage != record.Storage {
+		return fmt.Errorf("%s is now %s in MPD, not %s", label, storage, record.Storage)
+	}
+	m.mu.Lock()
+	m.storages[record.Device] = storage
+	m.mu.Unlock()
+	return nil
+}
+
+// release unmounts the recorded mount from MPD, if still there. The other
+// MPD mounts, e.g. network shares or another drive mounted under the same
+// name, are left alone.
+func (m *mpdFinder) release(record mountRecord) error {
+	label := filepath.Base(record.Target)
+	storage, mounted, err := m.mounted(label)
+	if err != nil || !mounted {
+		return err
+	}
+	if record.Storage != "" && storage != record.Storage {
+		log.Printf("Leaving %s mounted in MPD, now %s and not %s", label, storage, record.Storage)
+		return nil
+	}
+	_, err = m.unmount(record, record.Target)
+	return err
+}
+
+// mounted returns the storage mounted at label in MPD, if any.
+func (m *mpdFinder) mounted(label string) (string, bool, error) {
+	mounts, err := m.client.Mounts()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check MPD mount %s: %w", label, err)
+	}
+	storage, ok := mounts[label]
+	return storage, ok, nil
+}
+
+// isMounted reports whether something is mounted in MPD at label, as far as
+// MPD tells.
+func (m *mpdFinder) isMounted(label string) bool {
+	_, mounted, err := m.mounted(label)
+	if err != nil {
+		log.Printf("warning: %v", err)
 	}
-	return device.PropertyValue("ID_FS_UUID")
+	return mounted
 }
@@ -0,0 +1,86 @@
+package mounts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer/mpdtest"
+)
+
+// mpdMounts returns a fake MPD server whose neighbors are stick and another
+// drive labelled the same, and a client to it.
+func mpdMounts(t *testing.T) (*mpdtest.Server, *mpdplayer.ReconnectingMPDClient) {
+	t.Helper()
+	server, err := mpdtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	server.AddNeighbor("udisks://by-uuid-1234-ABCD", "MUSIC")
+	server.AddNeighbor("udisks://by-uuid-5678-EF01", "MUSIC")
+	conn, err := mpdplayer.NewMPDConnection(server.Network, server.Addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client := mpdplayer.NewReconnectingMPDClient(ctx, conn)
+	t.Cleanup(client.Disconnect)
+	return server, client
+}
+
+func TestMPDRecordsStorage(t *testing.T) {
+	server, client := mpdMounts(t)
+	m := newMpdFinder(client, "/var/lib/mpd/music", newMountNamer(nil))
+
+	if _, err := m.mount(stick, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if storage := m.storage(stick.Devnode()); storage != "udisks://by-uuid-1234-ABCD" {
+		t.Fatalf("storage = %s", storage)
+	}
+	record := mountRecord{Device: stick.Devnode(), Target: "/var/lib/mpd/music/MUSIC", Storage: m.storage(stick.Devnode())}
+
+	// the mount taken back after a restart
+	restarted := newMpdFinder(client, "/var/lib/mpd/music", newMountNamer(nil))
+	if err := restarted.restore(record); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.storage(stick.Devnode()) != record.Storage {
+		t.Fatal("restored storage not kept")
+	}
+	if err := restarted.release(record); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Mounts()["MUSIC"]; ok {
+		t.Fatal("released mount still in MPD")
+	}
+}
+
+func TestMPDLeavesAnotherStorageUnderTheName(t *testing.T) {
+	server, client := mpdMounts(t)
+	m := newMpdFinder(client, "/var/lib/mpd/music", newMountNamer(nil))
+	record := mountRecord{Device: stick.Devnode(), Target: "/var/lib/mpd/music/MUSIC", Storage: "udisks://by-uuid-1234-ABCD"}
+
+	// another drive mounted under the name while the player was down
+	if _, err := client.Mount([]string{"5678-EF01"}, "MUSIC"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.restore(record); err == nil {
+		t.Fatal("restored the mount of another drive")
+	}
+	if err := m.release(record); err != nil {
+		t.Fatal(err)
+	}
+	if storage := server.Mounts()["MUSIC"]; storage != "udisks://by-uuid-5678-EF01" {
+		t.Fatalf("mount of another drive released, MUSIC = %q", storage)
+	}
+
+	// records made before storages were recorded are still taken back
+	record.Storage = ""
+	if err := m.restore(record); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+package mounts
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// mountNamer names the mounts of drives in the MPD library, so a drive is
+// always found at the same place: after the name pinned for its UUID, or its
+// label, made a safe path element. A drive whose name is taken by another
+// one gets a suffix derived from its UUID.
+type mountNamer struct {
+	// names are the names pinned by lowercase filesystem UUID
+	names map[string]string
+}
+
+func newMountNamer(names map[string]string) *mountNamer {
+	pinned := make(map[string]string, len(names))
+	for uuid, name := range names {
+		pinned[strings.ToLower(uuid)] = name
+	}
+	return &mountNamer{names: pinned}
+}
+
+// name returns the name of device, before collisions are resolved.
+func (n *mountNamer) name(device BlockDevice) string {
+	uuid := device.PropertyValue("ID_FS_UUID")
+	for _, name := range []string{
+		n.names[strings.ToLower(uuid)],
+		device.PropertyValue("ID_FS_LABEL"),
+		uuid,
+		filepath.Base(device.Devnode()),
+	} {
+		if name = sanitizeName(name); name != "" {
+			return name
+		}
+	}
+	return "usb"
+}
+
+// unique returns the name of device, or the first of its suffixed names not
+// taken, the last one if all are.
+func (n *mountNamer) unique(device BlockDevice, taken func(name string) bool) string {
+	name := n.name(device)
+	suffix := uuidSuffix(device)
+	candidates := []string{name, name + "-" + suffix}
+	if device := sanitizeName(filepath.Base(device.Devnode())); device != "" && device != suffix {
+		candidates = append(candidates, name+"-"+suffix+"-"+device)
+	}
+	for _, candidate := range candidates {
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// uuidSuffix returns the first 8 letters and digits of the UUID of device,
+// lowercased, or its device name without UUID.
+func uuidSuffix(device BlockDevice) string {
+	var suffix strings.Builder
+	for _, r := range strings.ToLower(device.PropertyValue("ID_FS_UUID")) {
+		if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			suffix.WriteRune(r)
+		}
+		if suffix.Len() == 8 {
+			break
+		}
+	}
+	if suffix.Len() == 0 {
+		return sanitizeName(filepath.Base(device.Devnode()))
+	}
+	return suffix.String()
+}
+
+// sanitizeName makes name a single path element MPD and shells handle:
+// spaces, slashes and other punctuation are replaced by underscores, and the
+// leading dots hiding it from MPD are removed.
+func sanitizeName(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.TrimSpace(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-.+()", r) {
+			b.WriteRune(r)
+			underscore = false
+			continue
+		}
+		if !underscore {
+			b.WriteRune('_')
+			underscore = true
+		}
+	}
+	return strings.Trim(b.String(), "_.")
+}
@@ -0,0 +1,97 @@
+package mounts
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"MUSIC", "MUSIC"},
+		{"My Music", "My_Music"},
+		{"  Road   Trip  ", "Road_Trip"},
+		{"AC/DC", "AC_DC"},
+		{"a//b  c", "a_b_c"},
+		{".hidden", "hidden"},
+		{"../etc", "etc"},
+		{"...", ""},
+		{"Été 2024", "Été_2024"},
+		{"Best-Of (Disc 1)", "Best-Of_(Disc_1)"},
+		{"v1.2+", "v1.2+"},
+		{"*?", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeName(tt.name); got != tt.want {
+			t.Errorf("sanitizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMountNamerName(t *testing.T) {
+	namer := newMountNamer(map[string]string{
+		// pinned names are looked up whatever the case of the UUID
+		"1234-abcd": "Car Music",
+		"ABCD-0000": "Podcasts",
+	})
+	tests := []struct {
+		name   string
+		device testDevice
+		want   string
+	}{
+		{"pinned", stick, "Car_Music"},
+		{"pinned uppercase", testDevice{"DEVNAME": "/dev/sdb1", "ID_FS_UUID": "abcd-0000", "ID_FS_LABEL": "PODS"}, "Podcasts"},
+		{"label", testDevice{"DEVNAME": "/dev/sdb1", "ID_FS_UUID": "5678-EF01", "ID_FS_LABEL": "My Stick"}, "My_Stick"},
+		{"uuid without label", testDevice{"DEVNAME": "/dev/sdb1", "ID_FS_UUID": "5678-EF01"}, "5678-EF01"},
+		{"uuid when the label is not a name", testDevice{"DEVNAME": "/dev/sdb1", "ID_FS_UUID": "5678-EF01", "ID_FS_LABEL": "..."}, "5678-EF01"},
+		{"device name without uuid", testDevice{"DEVNAME": "/dev/sdb1"}, "sdb1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := namer.name(tt.device); got != tt.want {
+				t.Fatalf("name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMountNamerUnique(t *testing.T) {
+	namer := newMountNamer(nil)
+	noUUID := testDevice{"DEVNAME": "/dev/sdb1", "ID_FS_LABEL": "MUSIC"}
+	tests := []struct {
+		name   string
+		device testDevice
+		taken  []string
+		want   string
+	}{
+		{"free", stick, nil, "MUSIC"},
+		{"taken", stick, []string{"MUSIC"}, "MUSIC-1234abcd"},
+		{"suffix taken", stick, []string{"MUSIC", "MUSIC-1234abcd"}, "MUSIC-1234abcd-sdx1"},
+		{"all taken", stick, []string{"MUSIC", "MUSIC-1234abcd", "MUSIC-1234abcd-sdx1"}, "MUSIC-1234abcd-sdx1"},
+		{
+			"long uuid",
+			testDevice{"DEVNAME": "/dev/sr0", "ID_FS_UUID": "2023-11-05-18-22-31-00", "ID_FS_LABEL": "MUSIC"},
+			[]string{"MUSIC"},
+			"MUSIC-20231105",
+		},
+		{"device name without uuid", noUUID, []string{"MUSIC"}, "MUSIC-sdb1"},
+		{"all taken without uuid", noUUID, []string{"MUSIC", "MUSIC-sdb1"}, "MUSIC-sdb1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := func(name string) bool {
+				return slices.Contains(tt.taken, name)
+			}
+			got := namer.unique(tt.device, taken)
+			if got != tt.want {
+				t.Fatalf("unique = %q, want %q", got, tt.want)
+			}
+			// the same drive always gets the same name
+			if again := namer.unique(tt.device, taken); again != got {
+				t.Fatalf("unique = %q then %q", got, again)
+			}
+		})
+	}
+}
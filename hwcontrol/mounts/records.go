@@ -0,0 +1,119 @@
+package mounts
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/b0bbywan/go-mpd-discplayer/state"
+)
+
+// mountRecord is a mount made by the player, kept across restarts so it is
+// taken back or cleaned up on the next start.
+type mountRecord struct {
+	Device string `json:"device"`
+	UUID   string `json:"uuid,omitempty"`
+	Label  string `json:"label,omitempty"`
+	// MountPoint is where the filesystem is mounted, in the library when
+	// mounted by MPD
+	MountPoint string `json:"mount_point"`
+	// Target is the mount in the MPD library: the mount point, a symlink to
+	// it, or the MPD mount
+	Target string `json:"target"`
+	// Storage is the URI of the MPD mount, telling it from another storage
+	// mounted under the same name since
+	Storage string `json:"storage,omitempty"`
+	Method  string `json:"method"`
+	// Owned tells the player mounted the filesystem itself, and unmounts it
+	Owned bool      `json:"owned,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Devnode and PropertyValue make a record the BlockDevice it was made for,
+// once the device is gone.
+func (r mountRecord) Devnode() string {
+	return r.Device
+}
+
+func (r mountRecord) PropertyValue(key string) string {
+	switch key {
+	case "ID_FS_UUID":
+		return r.UUID
+	case "ID_FS_LABEL":
+		return r.Label
+	}
+	return ""
+}
+
+// mountRecords are the mounts made by the player by device node. Each change
+// is saved before it is applied, so the records on disk always match those
+// in memory, even after a crash.
+type mountRecords struct {
+	store   *state.Store
+	mu      sync.Mutex
+	records map[string]mountRecord
+}
+
+func loadMountRecords(store *state.Store) *mountRecords {
+	records := make(map[string]mountRecord)
+	if err := store.Load(&records); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	return &mountRecords{
+		store:   store,
+		records: records,
+	}
+}
+
+func (r *mountRecords) get(devnode string) (mountRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[devnode]
+	return record, ok
+}
+
+// list returns the records sorted by device node.
+func (r *mountRecords) list() []mountRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]mountRecord, 0, len(r.records))
+	for _, record := range r.records {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Device < list[j].Device
+	})
+	return list
+}
+
+func (r *mountRecords) add(record mountRecord) error {
+	return r.update(func(records map[string]mountRecord) {
+		records[record.Device] = record
+	})
+}
+
+func (r *mountRecords) remove(devnode string) error {
+	if _, ok := r.get(devnode); !ok {
+		return nil
+	}
+	return r.update(func(records map[string]mountRecord) {
+		delete(records, devnode)
+	})
+}
+
+// update applies change to a copy of the records, replacing them once saved.
+func (r *mountRecords) update(change func(records map[string]mountRecord)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records := make(map[string]mountRecord, len(r.records)+1)
+	for devnode, record := range r.records {
+		records[devnode] = record
+	}
+	change(records)
+	if err := r.store.Save(records); err != nil {
+		return err
+	}
+	r.records = records
+	return nil
+}
@@ -1,14 +1,13 @@
 package mounts
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/jochenvg/go-udev"
 )
 
 type SymlinkFinder struct {
@@ -27,16 +26,16 @@ func newSymlinkFinder(mpdLibraryFolder, mpdUSBFolder string) *SymlinkFinder {
 	return s
 }
 
-func (s *SymlinkFinder) validate(device *udev.Device, mountpoint, target string) (string, error) {
+func (s *SymlinkFinder) validate(device BlockDevice, mountpoint, target string) (string, error) {
 	return s.createSymlink(device, mountpoint, target)
 }
 
-func (s *SymlinkFinder) clear(device *udev.Device, mountpoint string) (string, error) {
+func (s *SymlinkFinder) clear(device BlockDevice, mountpoint string) (string, error) {
 	return s.clearSymlinkCache(device, mountpoint)
 }
 
 // Helper function to create a symbolic link
-func (s *SymlinkFinder) createSymlink(device *udev.Device, mountpoint, target string) (string, error) {
+func (s *SymlinkFinder) createSymlink(device BlockDevice, mountpoint, target string) (string, error) {
 	// Ensure the target directory exists
 	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
 		return "", fmt.Errorf("error creating target directory: %w", err)
@@ -50,7 +49,7 @@ func (s *SymlinkFinder) createSymlink(device *udev.Device, mountpoint, target st
 	return target, nil
 }
 
-func (s *SymlinkFinder) clearSymlinkCache(device *udev.Device, mountpoint string) (string, error) {
+func (s *SymlinkFinder) clearSymlinkCache(device BlockDevice, mountpoint string) (string, error) {
 	devnode := device.Devnode()
 	path, err := s.symlinkCache.GetCache(devnode)
 	if err != nil {
@@ -69,6 +68,42 @@ func (s *SymlinkFinder) clearSymlinkCache(device *udev.Device, mountpoint string
 	return path, nil
 }
 
+// restore takes back the symlink of a recorded mount, if it still links to
+// the mount point. Drives mounted in the library have none.
+func (s *SymlinkFinder) restore(record mountRecord) error {
+	if record.Target == record.MountPoint {
+		return nil
+	}
+	if err := checkSymlink(record.MountPoint, record.Target); err != nil {
+		return err
+	}
+	s.symlinkCache.AddCache(record.Device, record.Target)
+	return nil
+}
+
+// release removes the symlink of a recorded mount, if left.
+func (s *SymlinkFinder) release(record mountRecord) error {
+	if record.Target == record.MountPoint {
+		return nil
+	}
+	info, err := os.Lstat(record.Target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat path %s: %w", record.Target, err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("path %s is not a symlink, skipping cleanup", record.Target)
+	}
+	if err := os.Remove(record.Target); err != nil {
+		return fmt.Errorf("failed to remove symlink %s: %w", record.Target, err)
+	}
+	s.symlinkCache.RemoveCache(record.Device)
+	log.Printf("Removed symlink %s of %s", record.Target, record.Device)
+	return nil
+}
+
 func validateSymlink(path string, info os.FileInfo) (string, error) {
 	if info.Mode()&os.ModeSymlink == 0 {
 		return "", fmt.Errorf("path %s is not a symlink, skipping cleanup", path)
@@ -0,0 +1,142 @@
+package mounts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	udisksService        = "org.freedesktop.UDisks2"
+	udisksManagerPath    = "/org/freedesktop/UDisks2/Manager"
+	udisksManager        = "org.freedesktop.UDisks2.Manager"
+	udisksFilesystem     = "org.freedesktop.UDisks2.Filesystem"
+	udisksAlreadyMounted = "org.freedesktop.UDisks2.Error.AlreadyMounted"
+	// udisksMountOptions mount filesystems read-only, MPD only reading them
+	udisksMountOptions = "ro,nosuid,nodev,noexec"
+)
+
+// selfMounter is a Mounter mounting filesystems itself, instead of waiting
+// for an automounter to mount them.
+type selfMounter interface {
+	mount(device BlockDevice) (string, error)
+	unmount(device BlockDevice) error
+	// owns reports whether the player mounted devnode, and unmounts it
+	owns(devnode string) bool
+}
+
+// udisksMounter mounts filesystems read-only through udisks2 over the system
+// D-Bus, DBUS_SYSTEM_BUS_ADDRESS pointing to another bus if set. udisks
+// choosing the mount point, it is linked into the MPD library as
+// SymlinkFinder does.
+type udisksMounter struct {
+	*SymlinkFinder
+	conn *dbus.Conn
+	// mounted are the devices mounted by the player, those mounted by
+	// someone else being left mounted
+	mounted *protectedCache
+}
+
+func newUdisksMounter(mpdLibraryFolder, mpdUSBFolder string) (*udisksMounter, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	return &udisksMounter{
+		SymlinkFinder: newSymlinkFinder(mpdLibraryFolder, mpdUSBFolder),
+		conn:          conn,
+		mounted:       newCache(),
+	}, nil
+}
+
+// filesystem returns the udisks object of the block device devnode.
+func (u *udisksMounter) filesystem(devnode string) (dbus.BusObject, error) {
+	var paths []dbus.ObjectPath
+	spec := map[string]dbus.Variant{"path": dbus.MakeVariant(devnode)}
+	manager := u.conn.Object(udisksService, udisksManagerPath)
+	if err := manager.Call(udisksManager+".ResolveDevice", 0, spec, map[string]dbus.Variant{}).Store(&paths); err != nil {
+		return nil, fmt.Errorf("failed to resolve %s with udisks: %w", devnode, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("udisks does not know %s", devnode)
+	}
+	return u.conn.Object(udisksService, paths[0]), nil
+}
+
+func (u *udisksMounter) mount(device BlockDevice) (string, error) {
+	devnode := device.Devnode()
+	fs, err := u.filesystem(devnode)
+	if err != nil {
+		return "", err
+	}
+	options := map[string]dbus.Variant{
+		"options":                  dbus.MakeVariant(udisksMountOptions),
+		"auth.no_user_interaction": dbus.MakeVariant(true),
+	}
+	var mountPoint string
+	err = fs.Call(udisksFilesystem+".Mount", 0, options).Store(&mountPoint)
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) && dbusErr.Name == udisksAlreadyMounted {
+		log.Printf("%s already mounted, using its mount point", devnode)
+		return udisksMountPoint(fs)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to mount %s with udisks: %w", devnode, err)
+	}
+	u.mounted.AddCache(devnode, mountPoint)
+	log.Printf("Mounted %s read-only at %s with udisks", devnode, mountPoint)
+	return mountPoint, nil
+}
+
+func (u *udisksMounter) unmount(device BlockDevice) error {
+	devnode := device.Devnode()
+	if _, err := u.mounted.GetCache(devnode); err != nil {
+		return nil
+	}
+	defer u.mounted.RemoveCache(devnode)
+	fs, err := u.filesystem(devnode)
+	if err != nil {
+		return err
+	}
+	options := map[string]dbus.Variant{"auth.no_user_interaction": dbus.MakeVariant(true)}
+	if err := fs.Call(udisksFilesystem+".Unmount", 0, options).Err; err != nil {
+		return fmt.Errorf("failed to unmount %s with udisks: %w", devnode, err)
+	}
+	log.Printf("Unmounted %s with udisks", devnode)
+	return nil
+}
+
+func (u *udisksMounter) owns(devnode string) bool {
+	_, err := u.mounted.GetCache(devnode)
+	return err == nil
+}
+
+// restore takes back the symlink of a recorded mount, and its unmount if the
+// player mounted it. Recorded mounts are released by SymlinkFinder, udisks
+// unmounting the filesystems of removed drives itself.
+func (u *udisksMounter) restore(record mountRecord) error {
+	if err := u.SymlinkFinder.restore(record); err != nil {
+		return err
+	}
+	if record.Owned {
+		u.mounted.AddCache(record.Device, record.MountPoint)
+	}
+	return nil
+}
+
+// udisksMountPoint returns the first mount point of a mounted filesystem.
+func udisksMountPoint(fs dbus.BusObject) (string, error) {
+	variant, err := fs.GetProperty(udisksFilesystem + ".MountPoints")
+	if err != nil {
+		return "", fmt.Errorf("failed to get mount points: %w", err)
+	}
+	mountPoints, ok := variant.Value().([][]byte)
+	if !ok || len(mountPoints) == 0 {
+		return "", fmt.Errorf("no mount point for mounted filesystem")
+	}
+	// udisks mount points are NUL terminated byte strings
+	return string(bytes.TrimRight(mountPoints[0], "\x00")), nil
+}
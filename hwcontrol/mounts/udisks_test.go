@@ -0,0 +1,250 @@
+package mounts
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/mounts/udiskstest"
+	"github.com/b0bbywan/go-mpd-discplayer/state"
+)
+
+// testDevice is a block device from its udev properties.
+type testDevice map[string]string
+
+func (d testDevice) Devnode() string {
+	return d["DEVNAME"]
+}
+
+func (d testDevice) PropertyValue(key string) string {
+	return d[key]
+}
+
+var stick = testDevice{
+	"DEVNAME":     "/dev/sdx1",
+	"ID_FS_UUID":  "1234-ABCD",
+	"ID_FS_LABEL": "MUSIC",
+}
+
+// udisksBus starts a private D-Bus the system bus points to, running the
+// stand-in udisks service with stick plugged in.
+func udisksBus(t *testing.T) (*udiskstest.Server, *dbus.Conn) {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read the D-Bus address: %v", err)
+	}
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", strings.TrimSpace(address))
+
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	server, err := udiskstest.NewServer(conn, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddFilesystem(stick.Devnode()); err != nil {
+		t.Fatal(err)
+	}
+	return server, conn
+}
+
+func newUdisksManager(t *testing.T, library string) *MountManager {
+	t.Helper()
+	records := state.NewStore(filepath.Join(t.TempDir(), "mounts.json"))
+	m, err := NewMountManager(NewMountConfig(library, ".udisks", "udisks", nil), nil, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func assertLink(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := os.Readlink(path)
+	if want == "" {
+		if !os.IsNotExist(err) {
+			t.Fatalf("%s still links to %s", path, got)
+		}
+		return
+	}
+	if err != nil || got != want {
+		t.Fatalf("%s links to %q (%v), want %s", path, got, err, want)
+	}
+}
+
+func TestUdisksMountAndUnmount(t *testing.T) {
+	server, _ := udisksBus(t)
+	library := t.TempDir()
+	m := newUdisksManager(t, library)
+
+	relPath, err := m.Mount(stick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relPath != ".udisks/MUSIC" {
+		t.Fatalf("relPath = %s", relPath)
+	}
+	mountPoint := server.MountPoint(stick.Devnode())
+	if mountPoint == "" {
+		t.Fatal("stick not mounted by udisks")
+	}
+	if options := server.MountOptions(stick.Devnode()); options != udisksMountOptions {
+		t.Fatalf("mount options = %s, want %s", options, udisksMountOptions)
+	}
+	link := filepath.Join(library, relPath)
+	assertLink(t, link, mountPoint)
+	if record, ok := m.records.get(stick.Devnode()); !ok || !record.Owned || record.MountPoint != mountPoint {
+		t.Fatalf("record = %+v, %v", record, ok)
+	}
+
+	if relPath, err = m.Unmount(stick); err != nil || relPath != ".udisks/MUSIC" {
+		t.Fatalf("Unmount = %s, %v", relPath, err)
+	}
+	if mountPoint := server.MountPoint(stick.Devnode()); mountPoint != "" {
+		t.Fatalf("stick still mounted at %s", mountPoint)
+	}
+	assertLink(t, link, "")
+	if _, ok := m.records.get(stick.Devnode()); ok {
+		t.Fatal("unmounted stick still recorded")
+	}
+}
+
+func TestUdisksLeavesForeignMounts(t *testing.T) {
+	server, conn := udisksBus(t)
+	library := t.TempDir()
+	m := newUdisksManager(t, library)
+
+	// mounted by a desktop automounter before the player sees it
+	fs := conn.Object(udiskstest.Service, dbus.ObjectPath(udiskstest.FilesystemPathPrefix+"sdx1"))
+	var mountPoint string
+	if err := fs.Call(udiskstest.FilesystemInterface+".Mount", 0, map[string]dbus.Variant{}).Store(&mountPoint); err != nil {
+		t.Fatal(err)
+	}
+
+	relPath, err := m.Mount(stick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLink(t, filepath.Join(library, relPath), mountPoint)
+	if record, _ := m.records.get(stick.Devnode()); record.Owned {
+		t.Fatal("stick mounted by someone else recorded as owned")
+	}
+
+	if _, err := m.Unmount(stick); err != nil {
+		t.Fatal(err)
+	}
+	if server.MountPoint(stick.Devnode()) != mountPoint {
+		t.Fatal("stick mounted by someone else unmounted")
+	}
+	assertLink(t, filepath.Join(library, relPath), "")
+}
+
+func TestUdisksUnmountOfPulledDrive(t *testing.T) {
+	server, _ := udisksBus(t)
+	library := t.TempDir()
+	m := newUdisksManager(t, library)
+
+	relPath, err := m.Mount(stick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.RemoveFilesystem(stick.Devnode())
+
+	// the files of the drive still leave the library
+	got, err := m.Unmount(stick)
+	if err != nil || got != relPath {
+		t.Fatalf("Unmount = %s, %v", got, err)
+	}
+	assertLink(t, filepath.Join(library, relPath), "")
+}
+
+func TestUdisksMountFailure(t *testing.T) {
+	server, _ := udisksBus(t)
+	library := t.TempDir()
+	m := newUdisksManager(t, library)
+
+	server.FailNext("Mount", 1)
+	if _, err := m.Mount(stick); err == nil {
+		t.Fatal("Mount succeeded")
+	}
+	if _, ok := m.records.get(stick.Devnode()); ok {
+		t.Fatal("failed mount recorded")
+	}
+	if _, err := m.Mount(testDevice{"DEVNAME": "/dev/sdy1"}); err == nil {
+		t.Fatal("Mount of a device udisks does not know succeeded")
+	}
+}
+
+func TestUdisksRestoreTakesBackOwnedMounts(t *testing.T) {
+	server, _ := udisksBus(t)
+	library := t.TempDir()
+	// the mounter of the restarted player, created before the links exist
+	restarted, err := newUdisksMounter(library, ".udisks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newUdisksManager(t, library)
+	if _, err := m.Mount(stick); err != nil {
+		t.Fatal(err)
+	}
+	record, _ := m.records.get(stick.Devnode())
+
+	if err := restarted.restore(record); err != nil {
+		t.Fatal(err)
+	}
+	if !restarted.owns(stick.Devnode()) {
+		t.Fatal("restored mount not owned")
+	}
+	if err := restarted.unmount(stick); err != nil {
+		t.Fatal(err)
+	}
+	if mountPoint := server.MountPoint(stick.Devnode()); mountPoint != "" {
+		t.Fatalf("restored mount left mounted at %s", mountPoint)
+	}
+
+	// a link not to the recorded mount point is not taken back
+	record.MountPoint = t.TempDir()
+	if err := restarted.restore(record); err == nil {
+		t.Fatal("restore of a link to another mount point succeeded")
+	}
+}
+
+func TestUdisksReconcileReleasesRemovedDrives(t *testing.T) {
+	udisksBus(t)
+	library := t.TempDir()
+	m := newUdisksManager(t, library)
+	relPath, err := m.Mount(stick)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Reconcile(nil)
+	assertLink(t, filepath.Join(library, relPath), "")
+	if _, ok := m.records.get(stick.Devnode()); ok {
+		t.Fatal("released mount still recorded")
+	}
+}
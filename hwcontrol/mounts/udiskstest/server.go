@@ -0,0 +1,216 @@
+// Package udiskstest provides a stand-in udisks2 service implementing the
+// Manager and Filesystem interfaces used by the udisks mounter, to exercise
+// it against a private D-Bus instead of the system one.
+//
+// Filesystems are not really mounted: mounting one creates an empty folder
+// under the root of the service, which tests fill as the drive content.
+// Point DBUS_SYSTEM_BUS_ADDRESS to the bus the service is connected to, e.g.
+// a dbus-daemon started with --session --print-address.
+package udiskstest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	Service              = "org.freedesktop.UDisks2"
+	ManagerPath          = "/org/freedesktop/UDisks2/Manager"
+	ManagerInterface     = "org.freedesktop.UDisks2.Manager"
+	FilesystemPathPrefix = "/org/freedesktop/UDisks2/block_devices/"
+	FilesystemInterface  = "org.freedesktop.UDisks2.Filesystem"
+	propertiesInterface  = "org.freedesktop.DBus.Properties"
+	errAlreadyMounted    = "org.freedesktop.UDisks2.Error.AlreadyMounted"
+	errNotMounted        = "org.freedesktop.UDisks2.Error.NotMounted"
+	errFailed            = "org.freedesktop.UDisks2.Error.Failed"
+)
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// Server is a stand-in udisks2 service. Create one with NewServer.
+type Server struct {
+	conn *dbus.Conn
+	root string
+
+	mu          sync.Mutex
+	filesystems map[string]*filesystem
+	failures    map[string]int
+}
+
+// filesystem is a block device holding a filesystem.
+type filesystem struct {
+	server  *Server
+	devnode string
+	path    dbus.ObjectPath
+	// mountPoint is empty when not mounted
+	mountPoint string
+	options    string
+}
+
+// manager implements the Manager interface.
+type manager struct {
+	server *Server
+}
+
+// NewServer owns the udisks2 name on the bus of conn, mounting filesystems
+// under root.
+func NewServer(conn *dbus.Conn, root string) (*Server, error) {
+	s := &Server{
+		conn:        conn,
+		root:        root,
+		filesystems: make(map[string]*filesystem),
+		failures:    make(map[string]int),
+	}
+	if err := conn.Export(&manager{server: s}, ManagerPath, ManagerInterface); err != nil {
+		return nil, fmt.Errorf("failed to export manager: %w", err)
+	}
+	reply, err := conn.RequestName(Service, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %w", Service, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, fmt.Errorf("%s already owned", Service)
+	}
+	return s, nil
+}
+
+// Close releases the udisks2 name.
+func (s *Server) Close() error {
+	_, err := s.conn.ReleaseName(Service)
+	return err
+}
+
+// AddFilesystem adds a block device holding a filesystem.
+func (s *Server) AddFilesystem(devnode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fs := &filesystem{
+		server:  s,
+		devnode: devnode,
+		path:    dbus.ObjectPath(FilesystemPathPrefix + unsafeChars.ReplaceAllString(filepath.Base(devnode), "_")),
+	}
+	if err := s.conn.Export(fs, fs.path, FilesystemInterface); err != nil {
+		return fmt.Errorf("failed to export %s: %w", devnode, err)
+	}
+	if err := s.conn.Export(fs, fs.path, propertiesInterface); err != nil {
+		return fmt.Errorf("failed to export %s properties: %w", devnode, err)
+	}
+	s.filesystems[devnode] = fs
+	return nil
+}
+
+// RemoveFilesystem removes a block device, as if pulled while mounted.
+func (s *Server) RemoveFilesystem(devnode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fs, ok := s.filesystems[devnode]
+	if !ok {
+		return
+	}
+	_ = s.conn.Export(nil, fs.path, FilesystemInterface)
+	_ = s.conn.Export(nil, fs.path, propertiesInterface)
+	delete(s.filesystems, devnode)
+}
+
+// MountPoint returns where devnode is mounted, empty when it is not.
+func (s *Server) MountPoint(devnode string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fs, ok := s.filesystems[devnode]; ok {
+		return fs.mountPoint
+	}
+	return ""
+}
+
+// MountOptions returns the options devnode was last mounted with.
+func (s *Server) MountOptions(devnode string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fs, ok := s.filesystems[devnode]; ok {
+		return fs.options
+	}
+	return ""
+}
+
+// FailNext makes the next n calls of method, e.g. Mount, fail.
+func (s *Server) FailNext(method string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = n
+}
+
+// fail reports whether method is scripted to fail, with the lock held.
+func (s *Server) fail(method string) *dbus.Error {
+	if s.failures[method] == 0 {
+		return nil
+	}
+	s.failures[method]--
+	return dbus.NewError(errFailed, []interface{}{method + " failed"})
+}
+
+// ResolveDevice returns the block devices matching the path of devspec.
+func (m *manager) ResolveDevice(devspec, options map[string]dbus.Variant) ([]dbus.ObjectPath, *dbus.Error) {
+	m.server.mu.Lock()
+	defer m.server.mu.Unlock()
+	if err := m.server.fail("ResolveDevice"); err != nil {
+		return nil, err
+	}
+	paths := []dbus.ObjectPath{}
+	devnode, _ := devspec["path"].Value().(string)
+	if fs, ok := m.server.filesystems[devnode]; ok {
+		paths = append(paths, fs.path)
+	}
+	return paths, nil
+}
+
+// Mount mounts the filesystem in a folder named after its device.
+func (fs *filesystem) Mount(options map[string]dbus.Variant) (string, *dbus.Error) {
+	fs.server.mu.Lock()
+	defer fs.server.mu.Unlock()
+	if err := fs.server.fail("Mount"); err != nil {
+		return "", err
+	}
+	if fs.mountPoint != "" {
+		return "", dbus.NewError(errAlreadyMounted, []interface{}{fs.devnode + " is already mounted at " + fs.mountPoint})
+	}
+	mountPoint := filepath.Join(fs.server.root, filepath.Base(fs.devnode))
+	if err := os.MkdirAll(mountPoint, 0o755); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	fs.options, _ = options["options"].Value().(string)
+	fs.mountPoint = mountPoint
+	return mountPoint, nil
+}
+
+// Unmount unmounts the filesystem, keeping its folder and content.
+func (fs *filesystem) Unmount(options map[string]dbus.Variant) *dbus.Error {
+	fs.server.mu.Lock()
+	defer fs.server.mu.Unlock()
+	if err := fs.server.fail("Unmount"); err != nil {
+		return err
+	}
+	if fs.mountPoint == "" {
+		return dbus.NewError(errNotMounted, []interface{}{fs.devnode + " is not mounted"})
+	}
+	fs.mountPoint = ""
+	return nil
+}
+
+// Get returns the MountPoints property, NUL terminated byte strings.
+func (fs *filesystem) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	fs.server.mu.Lock()
+	defer fs.server.mu.Unlock()
+	if iface != FilesystemInterface || name != "MountPoints" {
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{name})
+	}
+	mountPoints := [][]byte{}
+	if fs.mountPoint != "" {
+		mountPoints = append(mountPoints, append([]byte(fs.mountPoint), 0))
+	}
+	return dbus.MakeVariant(mountPoints), nil
+}
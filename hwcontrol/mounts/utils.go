@@ -4,17 +4,14 @@ import (
 	"bufio"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-var (
-	letters      = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
-	USBNameRegex = regexp.MustCompile(`^sd.*$`)
-)
+var USBNameRegex = regexp.MustCompile(`^sd.*$`)
 
 func readMountsFile(callback func(device, mountPoint string)) error {
 	mountFile := "/proc/mounts"
@@ -34,7 +31,7 @@ func readMountsFile(callback func(device, mountPoint string)) error {
 		if len(fields) < 2 {
 			continue // Malformed line
 		}
-		callback(fields[0], fields[1]) // Call the provided callback with device and mount point
+		callback(unescapeMountField(fields[0]), unescapeMountField(fields[1])) // Call the provided callback with device and mount point
 	}
 	if err := scanner.Err(); err != nil {
 		return fmt.Errorf("error reading %s: %w", mountFile, err)
@@ -42,6 +39,37 @@ func readMountsFile(callback func(device, mountPoint string)) error {
 	return nil
 }
 
+// unescapeMountField decodes the octal escapes of the spaces, tabs, newlines
+// and backslashes of a mounts file field, e.g. a label with spaces.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// isMountedAt reports whether devnode is mounted at mountPoint.
+func isMountedAt(devnode, mountPoint string) bool {
+	mounted := false
+	if err := readMountsFile(func(device, mp string) {
+		mounted = mounted || (device == devnode && mp == mountPoint)
+	}); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	return mounted
+}
+
 func isRemovableNode(devnode, mountPoint string) bool {
 	if !strings.HasPrefix(devnode, "/dev") {
 		return false
@@ -60,20 +88,3 @@ func isRemovableNode(devnode, mountPoint string) bool {
 	}
 	return true
 }
-
-func generateTarget(config *MountConfig, source string) string {
-	target := filepath.Join(config.MPDLibraryFolder, config.MPDUSBSubFolder, filepath.Base(source))
-	_, err := os.Stat(target)
-	if os.IsNotExist(err) {
-		return target
-	}
-	return fmt.Sprintf("%s-%s", target, randomString(5))
-}
-
-func randomString(n int) string {
-	s := make([]rune, n)
-	for i := range s {
-		s[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(s)
-}
@@ -0,0 +1,179 @@
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultExtensions are the audio files MPD plays.
+var DefaultExtensions = []string{
+	"flac", "mp3", "ogg", "oga", "opus", "m4a", "aac", "wav", "wv", "ape",
+	"aif", "aiff", "dsf", "dff", "mpc", "wma",
+}
+
+// Tags identify a song whatever its file.
+type Tags struct {
+	Artist string
+	Album  string
+	Disc   string
+	Track  string
+	Title  string
+}
+
+// key returns the tags compared to find duplicates, empty when they are too
+// few to tell songs apart.
+func (t Tags) key() string {
+	if t.Title == "" || (t.Artist == "" && t.Album == "") {
+		return ""
+	}
+	fields := []string{t.Artist, t.Album, tagNumber(t.Disc), tagNumber(t.Track), t.Title}
+	for i, field := range fields {
+		fields[i] = strings.ToLower(strings.TrimSpace(field))
+	}
+	return strings.Join(fields, "\x00")
+}
+
+// tagNumber strips the total of a disc or track tag, e.g. 3/12.
+func tagNumber(tag string) string {
+	number, _, _ := strings.Cut(tag, "/")
+	return strings.TrimLeft(strings.TrimSpace(number), "0")
+}
+
+// fileIndex is the hash of the imported files, by path relative to the
+// import folder.
+type fileIndex map[string]string
+
+// loadIndex reads the index, dropping the files removed from the library.
+func (im *Importer) loadIndex() fileIndex {
+	index := make(fileIndex)
+	if err := im.index.Load(&index); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	folder := filepath.Join(im.config.Library, filepath.FromSlash(im.config.Folder))
+	for file := range index {
+		if _, err := os.Stat(filepath.Join(folder, filepath.FromSlash(file))); err != nil {
+			delete(index, file)
+		}
+	}
+	return index
+}
+
+// hashes returns the imported files by hash.
+func (index fileIndex) hashes() map[string]string {
+	hashes := make(map[string]string, len(index))
+	for file, hash := range index {
+		hashes[hash] = file
+	}
+	return hashes
+}
+
+// listFiles returns the files under root with one of extensions, relative
+// to root and sorted, skipping hidden files and folders.
+func listFiles(root string, extensions []string) ([]string, error) {
+	wanted := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		wanted[strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+	var files []string
+	err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("[import] Skipping %s: %v", p, err)
+			return nil
+		}
+		if p != root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if !wanted[strings.ToLower(strings.TrimPrefix(filepath.Ext(p), "."))] {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", root, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// sameFile reports whether dst has the size and modification time of a
+// source file.
+func sameFile(dst string, src os.FileInfo) bool {
+	info, err := os.Stat(dst)
+	return err == nil && info.Size() == src.Size() && info.ModTime().Equal(src.ModTime())
+}
+
+func hashFile(ctx context.Context, file string) (string, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, contextReader{ctx, in}); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", file, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// copyFile copies src to dst through a hidden part file, renamed once
+// complete, keeping the modification time of src.
+func copyFile(ctx context.Context, src, dst string, info os.FileInfo) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	part := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".part")
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(part)
+	if _, err := io.Copy(out, contextReader{ctx, in}); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(part, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(part, dst)
+}
+
+// contextReader stops reading once ctx is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,277 @@
+// Package importer copies the audio files of USB drives into the MPD
+// library, skipping those the library already holds.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/b0bbywan/go-mpd-discplayer/jobs"
+	"github.com/b0bbywan/go-mpd-discplayer/state"
+)
+
+// Mode tells how files already imported are detected.
+type Mode string
+
+const (
+	// ModeCopy hashes every file of the drive, so changes keeping the size
+	// and modification time of a file are imported too
+	ModeCopy Mode = "copy"
+	// ModeSync skips the files whose copy has the same size and
+	// modification time, as rsync does, only hashing new or changed files
+	ModeSync Mode = "sync"
+)
+
+var Modes = []Mode{ModeCopy, ModeSync}
+
+func ParseMode(mode string) (Mode, error) {
+	for _, m := range Modes {
+		if Mode(mode) == m {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("invalid import mode %q, expected one of %v", mode, Modes)
+}
+
+// ErrRunning is returned when an import is started on a drive already
+// importing.
+var ErrRunning = errors.New("import already running")
+
+// Config tells where and how drives are imported.
+type Config struct {
+	// Library is the MPD library folder
+	Library string
+	// Folder is where drives are imported, relative to the library, in a
+	// folder per drive
+	Folder string
+	Mode   Mode
+	// Extensions are the extensions of the files imported, without dot
+	Extensions []string
+}
+
+func NewConfig(library, folder string, mode Mode, extensions []string) *Config {
+	return &Config{
+		Library:    library,
+		Folder:     folder,
+		Mode:       mode,
+		Extensions: extensions,
+	}
+}
+
+// Job is the import of a mounted drive.
+type Job struct {
+	Device string
+	// Root is where the drive is mounted on the system
+	Root string
+	// URI is the drive in the MPD library, to read the tags of its files
+	URI string
+	// Name is the folder the drive is imported into
+	Name string
+}
+
+// Progress is reported before each file is imported.
+type Progress struct {
+	Device string
+	// File is the file being imported, relative to the drive root
+	File string
+	// Index is the position of the file, from 1
+	Index int
+	Files int
+}
+
+// Summary counts what became of the files of a drive.
+type Summary struct {
+	// URI is the import folder of the drive in the MPD library
+	URI    string
+	Files  int
+	Copied int
+	// Unchanged files were imported before
+	Unchanged int
+	// Duplicates are in the library already, with the same content or tags
+	Duplicates int
+	Failed     int
+}
+
+// TagLister returns the tags of the songs under uri in the MPD library, by
+// file relative to uri.
+type TagLister func(uri string) (map[string]Tags, error)
+
+// Importer imports drives in the background, one job per drive. The hashes
+// of the imported files are kept in index to detect duplicates.
+type Importer struct {
+	config *Config
+	tags   TagLister
+	index  *state.Store
+	jobs   *jobs.Group
+	// indexMu serializes the jobs updating the index
+	indexMu sync.Mutex
+}
+
+func NewImporter(config *Config, tags TagLister, index *state.Store) *Importer {
+	return &Importer{
+		config: config,
+		tags:   tags,
+		index:  index,
+		jobs:   jobs.NewGroup(),
+	}
+}
+
+// Start imports the drive of job in the background until done or ctx is
+// cancelled. progress is called before each file, and done once the job
+// ends.
+func (im *Importer) Start(ctx context.Context, job Job, progress func(Progress), done func(job Job, summary Summary, err error)) error {
+	var summary Summary
+	var err error
+	started := im.jobs.Start(ctx, job.Device, func(ctx context.Context) {
+		summary, err = im.run(ctx, job, progress)
+	}, func() {
+		done(job, summary, err)
+	})
+	if !started {
+		return fmt.Errorf("%w on %s", ErrRunning, job.Device)
+	}
+	return nil
+}
+
+// Cancel stops the import of device, reporting whether one was running.
+// The file being copied is discarded, those copied are kept.
+func (im *Importer) Cancel(device string) bool {
+	return im.jobs.Cancel(device)
+}
+
+// Running reports whether device is being imported.
+func (im *Importer) Running(device string) bool {
+	return im.jobs.Running(device)
+}
+
+// Wait blocks until the imports end, so no file is being copied anymore.
+func (im *Importer) Wait() {
+	im.jobs.Wait()
+}
+
+func (im *Importer) run(ctx context.Context, job Job, progress func(Progress)) (Summary, error) {
+	summary := Summary{URI: path.Join(im.config.Folder, job.Name)}
+	files, err := listFiles(job.Root, im.config.Extensions)
+	if err != nil {
+		return summary, err
+	}
+	summary.Files = len(files)
+	if len(files) == 0 {
+		return summary, fmt.Errorf("no file to import on %s", job.Device)
+	}
+
+	im.indexMu.Lock()
+	defer im.indexMu.Unlock()
+	index := im.loadIndex()
+	defer func() {
+		if err := im.index.Save(index); err != nil {
+			log.Printf("[import] %v", err)
+		}
+	}()
+	hashes := index.hashes()
+	sourceTags := im.listTags(job.URI)
+	libraryTags := make(map[string]string)
+	for file, tags := range im.listTags(im.config.Folder) {
+		if key := tags.key(); key != "" {
+			libraryTags[key] = file
+		}
+	}
+
+	log.Printf("[import] Importing %d files of %s into %s", len(files), job.Device, summary.URI)
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		progress(Progress{Device: job.Device, File: file, Index: i + 1, Files: len(files)})
+		imported := path.Join(job.Name, file)
+		key := sourceTags[file].key()
+		status, err := im.importFile(ctx, job.Root, file, imported, key, index, hashes, libraryTags)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return summary, ctxErr
+			}
+			log.Printf("[import] Failed to import %s: %v", file, err)
+			summary.Failed++
+			continue
+		}
+		switch status {
+		case fileCopied:
+			summary.Copied++
+			if key != "" {
+				libraryTags[key] = imported
+			}
+		case fileUnchanged:
+			summary.Unchanged++
+		case fileDuplicate:
+			summary.Duplicates++
+		}
+	}
+	log.Printf("[import] Imported %s: %d copied, %d unchanged, %d duplicates, %d failed",
+		job.Device, summary.Copied, summary.Unchanged, summary.Duplicates, summary.Failed)
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("%d of %d files failed to import", summary.Failed, summary.Files)
+	}
+	return summary, nil
+}
+
+type fileStatus int
+
+const (
+	fileCopied fileStatus = iota
+	fileUnchanged
+	fileDuplicate
+)
+
+// importFile copies file of the drive mounted at root as imported, relative
+// to the import folder, unless it was imported before or its content or
+// tags key are in the library.
+func (im *Importer) importFile(ctx context.Context, root, file, imported, key string, index fileIndex, hashes, libraryTags map[string]string) (fileStatus, error) {
+	src := filepath.Join(root, filepath.FromSlash(file))
+	dst := filepath.Join(im.config.Library, filepath.FromSlash(im.config.Folder), filepath.FromSlash(imported))
+	info, err := os.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+	if im.config.Mode == ModeSync && sameFile(dst, info) {
+		return fileUnchanged, nil
+	}
+	if existing, ok := libraryTags[key]; ok && key != "" && existing != imported {
+		log.Printf("[import] %s is a duplicate of %s by tags", file, existing)
+		return fileDuplicate, nil
+	}
+	hash, err := hashFile(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	if existing, ok := hashes[hash]; ok {
+		if existing == imported {
+			return fileUnchanged, nil
+		}
+		log.Printf("[import] %s is a duplicate of %s", file, existing)
+		return fileDuplicate, nil
+	}
+	if err := copyFile(ctx, src, dst, info); err != nil {
+		return 0, err
+	}
+	if previous, ok := index[imported]; ok {
+		delete(hashes, previous)
+	}
+	index[imported] = hash
+	hashes[hash] = imported
+	return fileCopied, nil
+}
+
+func (im *Importer) listTags(uri string) map[string]Tags {
+	tags, err := im.tags(uri)
+	if err != nil {
+		log.Printf("[import] Not deduplicating by tags, failed to list %s: %v", uri, err)
+		return nil
+	}
+	return tags
+}
@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-mpd-discplayer/state"
+)
+
+// library is an MPD library drives are imported into, with the tags MPD
+// would list by uri.
+type library struct {
+	dir  string
+	tags map[string]map[string]Tags
+	im   *Importer
+}
+
+func newLibrary(t *testing.T, mode Mode) *library {
+	t.Helper()
+	l := &library{dir: t.TempDir(), tags: make(map[string]map[string]Tags)}
+	index := state.NewStore(filepath.Join(t.TempDir(), "imports.json"))
+	l.im = NewImporter(NewConfig(l.dir, "Imports", mode, DefaultExtensions), l.listTags, index)
+	return l
+}
+
+func (l *library) listTags(uri string) (map[string]Tags, error) {
+	return l.tags[uri], nil
+}
+
+// importDrive imports the drive at root into name, waiting for its end.
+func (l *library) importDrive(t *testing.T, root, name string) Summary {
+	t.Helper()
+	type result struct {
+		summary Summary
+		err     error
+	}
+	results := make(chan result, 1)
+	job := Job{Device: "/dev/" + name, Root: root, URI: name, Name: name}
+	err := l.im.Start(context.Background(), job, func(Progress) {}, func(_ Job, summary Summary, err error) {
+		results <- result{summary, err}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		return r.summary
+	case <-time.After(10 * time.Second):
+		t.Fatal("import not done")
+	}
+	return Summary{}
+}
+
+// imported returns the content of file imported from the drive name, empty
+// if it was not imported.
+func (l *library) imported(name, file string) string {
+	data, err := os.ReadFile(filepath.Join(l.dir, "Imports", name, filepath.FromSlash(file)))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// writeFiles writes files by path relative to root, all modified at once.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertSummary(t *testing.T, got Summary, copied, unchanged, duplicates int) {
+	t.Helper()
+	if got.Copied != copied || got.Unchanged != unchanged || got.Duplicates != duplicates || got.Failed != 0 {
+		t.Fatalf("summary = %+v, want %d copied, %d unchanged, %d duplicates", got, copied, unchanged, duplicates)
+	}
+}
+
+func TestImportSync(t *testing.T) {
+	l := newLibrary(t, ModeSync)
+	drive := t.TempDir()
+	writeFiles(t, drive, map[string]string{
+		"Album/01.flac":    "first",
+		"Album/02.MP3":     "second",
+		"Album/cover.jpg":  "cover",
+		".Trashes/03.flac": "trashed",
+	})
+
+	summary := l.importDrive(t, drive, "STICK")
+	if summary.Files != 2 || summary.URI != "Imports/STICK" {
+		t.Fatalf("summary = %+v, want the 2 audio files imported into Imports/STICK", summary)
+	}
+	assertSummary(t, summary, 2, 0, 0)
+	if got := l.imported("STICK", "Album/01.flac"); got != "first" {
+		t.Fatalf("imported 01.flac = %q", got)
+	}
+
+	// a second sync copies nothing
+	assertSummary(t, l.importDrive(t, drive, "STICK"), 0, 2, 0)
+
+	// a changed file is copied again
+	writeFiles(t, drive, map[string]string{"Album/01.flac": "first, remastered"})
+	later := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(drive, "Album", "01.flac"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	assertSummary(t, l.importDrive(t, drive, "STICK"), 1, 1, 0)
+	if got := l.imported("STICK", "Album/01.flac"); got != "first, remastered" {
+		t.Fatalf("imported 01.flac = %q, want the changed file", got)
+	}
+}
+
+func TestImportCopyHashesFiles(t *testing.T) {
+	l := newLibrary(t, ModeCopy)
+	drive := t.TempDir()
+	writeFiles(t, drive, map[string]string{"Album/01.flac": "first"})
+	assertSummary(t, l.importDrive(t, drive, "STICK"), 1, 0, 0)
+	assertSummary(t, l.importDrive(t, drive, "STICK"), 0, 1, 0)
+
+	// a change keeping the size and modification time is only seen by hash
+	writeFiles(t, drive, map[string]string{"Album/01.flac": "FIRST"})
+	assertSummary(t, l.importDrive(t, drive, "STICK"), 1, 0, 0)
+	if got := l.imported("STICK", "Album/01.flac"); got != "FIRST" {
+		t.Fatalf("imported 01.flac = %q, want the changed file", got)
+	}
+}
+
+func TestImportSkipsDuplicates(t *testing.T) {
+	l := newLibrary(t, ModeSync)
+	song := Tags{Artist: "Artist", Album: "Album", Disc: "1/1", Track: "01/12", Title: "Song"}
+	first := t.TempDir()
+	writeFiles(t, first, map[string]string{"Album/01.flac": "song"})
+	l.tags["FIRST"] = map[string]Tags{"Album/01.flac": song}
+	assertSummary(t, l.importDrive(t, first, "FIRST"), 1, 0, 0)
+	// MPD lists the imported file once its database updated
+	l.tags["Imports"] = map[string]Tags{"FIRST/Album/01.flac": song}
+
+	second := t.TempDir()
+	writeFiles(t, second, map[string]string{
+		"Copy/song.flac":    "song",
+		"Encoded/song.mp3":  "song, encoded again",
+		"Other/another.ogg": "another song",
+	})
+	// the same song, its tags written a bit differently
+	l.tags["SECOND"] = map[string]Tags{"Encoded/song.mp3": {Artist: "artist", Album: "Album ", Disc: "1", Track: "1", Title: "SONG"}}
+	assertSummary(t, l.importDrive(t, second, "SECOND"), 1, 0, 2)
+	for _, file := range []string{"Copy/song.flac", "Encoded/song.mp3"} {
+		if got := l.imported("SECOND", file); got != "" {
+			t.Fatalf("duplicate %s imported", file)
+		}
+	}
+	if got := l.imported("SECOND", "Other/another.ogg"); got != "another song" {
+		t.Fatalf("imported another.ogg = %q", got)
+	}
+}
+
+func TestTagsKey(t *testing.T) {
+	tests := []struct {
+		name string
+		tags Tags
+		want bool
+	}{
+		{"complete", Tags{Artist: "A", Album: "B", Track: "1", Title: "C"}, true},
+		{"without album", Tags{Artist: "A", Title: "C"}, true},
+		{"without title", Tags{Artist: "A", Album: "B"}, false},
+		{"title only", Tags{Title: "C"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.tags.key() != ""; got != tt.want {
+			t.Errorf("%s: keyed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Package jobs runs cancellable background jobs, one per key.
+package jobs
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs background jobs keyed by device, at most one per key.
+type Group struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewGroup() *Group {
+	return &Group{cancels: make(map[string]context.CancelFunc)}
+}
+
+// Start runs job in the background until it returns or ctx is cancelled,
+// unless a job already runs under key, and reports whether it started it.
+// done, if any, is called once the job returned and no longer runs.
+func (g *Group) Start(ctx context.Context, key string, job func(ctx context.Context), done func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.cancels[key]; ok {
+		return false
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	g.cancels[key] = cancel
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		job(ctx)
+		g.mu.Lock()
+		delete(g.cancels, key)
+		g.mu.Unlock()
+		cancel()
+		if done != nil {
+			done()
+		}
+	}()
+	return true
+}
+
+// Cancel cancels the job running under key, reporting whether there was one.
+func (g *Group) Cancel(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	cancel, ok := g.cancels[key]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// Running reports whether a job runs under key.
+func (g *Group) Running(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.cancels[key]
+	return ok
+}
+
+// Wait waits for the jobs to end, once cancelled.
+func (g *Group) Wait() {
+	g.wg.Wait()
+}
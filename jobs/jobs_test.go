@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGroupRunsOneJobPerKey(t *testing.T) {
+	g := NewGroup()
+	release := make(chan struct{})
+	done := make(chan bool, 1)
+	started := g.Start(context.Background(), "/dev/sr0", func(ctx context.Context) {
+		<-release
+	}, func() {
+		done <- g.Running("/dev/sr0")
+	})
+	if !started || !g.Running("/dev/sr0") {
+		t.Fatal("job not running")
+	}
+	if g.Start(context.Background(), "/dev/sr0", func(context.Context) {}, nil) {
+		t.Fatal("second job started on the same key")
+	}
+	if !g.Start(context.Background(), "/dev/sr1", func(context.Context) {}, nil) {
+		t.Fatal("job on another key not started")
+	}
+
+	close(release)
+	select {
+	case running := <-done:
+		if running {
+			t.Fatal("job still running once done")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("done not called")
+	}
+	g.Wait()
+	if !g.Start(context.Background(), "/dev/sr0", func(context.Context) {}, nil) {
+		t.Fatal("job not restarted once ended")
+	}
+	g.Wait()
+}
+
+func TestGroupCancel(t *testing.T) {
+	g := NewGroup()
+	if g.Cancel("/dev/sr0") {
+		t.Fatal("cancelled a job not running")
+	}
+	cancelled := make(chan error, 1)
+	g.Start(context.Background(), "/dev/sr0", func(ctx context.Context) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+	}, nil)
+	if !g.Cancel("/dev/sr0") {
+		t.Fatal("running job not cancelled")
+	}
+	g.Wait()
+	if err := <-cancelled; err != context.Canceled {
+		t.Fatalf("job context error = %v", err)
+	}
+	if g.Running("/dev/sr0") {
+		t.Fatal("cancelled job still running")
+	}
+}
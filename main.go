@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -10,6 +11,8 @@ import (
 	"syscall"
 
 	"github.com/b0bbywan/go-mpd-discplayer/cmd"
+	"github.com/b0bbywan/go-mpd-discplayer/control"
+	"github.com/b0bbywan/go-mpd-discplayer/hwcontrol/detect"
 )
 
 func main() {
@@ -17,7 +20,10 @@ func main() {
 	flag.Usage = usage
 	playFlag := flag.Bool(cmd.ActionPlay, false, "Start playback immediately")
 	stopFlag := flag.Bool(cmd.ActionStop, false, "Stop playback immediately")
-	deviceFlag := flag.String("device", "/dev/sr0", "Disc Device")
+	ejectFlag := flag.Bool(cmd.ActionEject, false, "Stop playback and eject the disc")
+	deviceFlag := flag.String("device", "", "Disc Device")
+	modeFlag := flag.String("mode", "", "Queue mode: replace, append, next or load")
+	replayFlag := flag.String("replay", "", "Replay recorded udev events from file")
 	versionFlag := flag.Bool("version", false, "Print version")
 
 	flag.Parse()
@@ -27,9 +33,30 @@ func main() {
 		return
 	}
 
-	if *playFlag && *stopFlag {
+	if flag.Arg(0) == "ctl" {
+		if err := cmd.RunCtl(flag.Args()[1:]); err != nil {
+			log.Fatalf("ctl: %v", err)
+		}
+		return
+	}
+
+	actions := 0
+	action := ""
+	for name, set := range map[string]bool{cmd.ActionPlay: *playFlag, cmd.ActionStop: *stopFlag, cmd.ActionEject: *ejectFlag} {
+		if set {
+			actions++
+			action = name
+		}
+	}
+	if actions > 1 {
 		flag.Usage()
-		log.Fatalf("Cannot use --play and --stop together. Choose one.")
+		log.Fatalf("Cannot use --play, --stop and --eject together. Choose one.")
+	}
+
+	// Handle flags
+	if action != "" {
+		runAction(*deviceFlag, action, *modeFlag)
+		return
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -40,37 +67,71 @@ func main() {
 	}
 	defer player.Close()
 
-	// Handle flags
-	if *playFlag {
-		if err := player.ExecuteAction(*deviceFlag, cmd.ActionPlay); err != nil {
-			log.Fatalf("Failed to start playback: %v", err)
-		}
-		return
-	}
-	if *stopFlag {
-		if err := player.ExecuteAction(*deviceFlag, cmd.ActionStop); err != nil {
-			log.Fatalf("Failed to stop playback: %v", err)
-		}
-		return
-	}
-
 	go signalMonitor(ctx, cancel)
 
 	// Default behavior
-	player.Start()
+	if *replayFlag != "" {
+		player.StartWithSource(detect.NewReplaySource(*replayFlag))
+	} else {
+		player.Start()
+	}
 
 	<-ctx.Done()
 }
 
+// runAction asks the running daemon to play, stop or eject, and falls back to
+// acting directly on MPD when no daemon is running.
+func runAction(device, action, mode string) {
+	err := cmd.ExecuteRemoteAction(device, action, mode)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, control.ErrUnavailable) {
+		log.Fatalf("Failed to %s: %v", action, err)
+	}
+	log.Printf("%v, running %s directly", err, action)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	player, err := cmd.NewPlayer(ctx, cancel)
+	if err != nil {
+		log.Fatalf("Failed to create player: %v", err)
+	}
+	defer player.Close()
+
+	if err := player.ExecuteAction(device, action, mode); err != nil {
+		log.Fatalf("Failed to %s: %v", action, err)
+	}
+}
+
 func usage() {
 	fmt.Println("Usage:")
 	fmt.Println("  mpd-discplayer [options]")
+	fmt.Println("  mpd-discplayer ctl <command> [args]")
 	fmt.Println("")
 	fmt.Println("Options:")
 	fmt.Println("  --play   Start playback immediately")
 	fmt.Println("  --stop   Stop playback immediately")
-	fmt.Println("  --device <device>   Set device to play from (only with --play)")
+	fmt.Println("  --eject   Stop playback and eject the disc")
+	fmt.Println("  --device <device>   Set the disc device, the first disc present (or /dev/sr0) by default")
+	fmt.Println("  --mode <mode>   Queue mode of --play: replace, append, next or load")
+	fmt.Println("  --replay <file>   Replay recorded udev events (JSON/YAML) instead of listening to udev")
 	fmt.Println("  -h, --help   Display this help message")
+	fmt.Println("")
+	fmt.Println("Control commands (sent to the running daemon):")
+	fmt.Println("  status                Show playback state and present devices")
+	fmt.Println("  list-devices          List present devices")
+	fmt.Println("  play [device]         Play a device, the first disc by default")
+	fmt.Println("  stop [device]         Stop playback, removing the device tracks when given")
+	fmt.Println("  eject [device]        Stop and remove a device, opening the tray of discs, the first disc by default")
+	fmt.Println("  rescan                Look for devices missed by the daemon")
+	fmt.Println("  reload                Reload drive settings, USB settings and schedules")
+	fmt.Println("  trigger <uri>         Play an URI as a schedule would")
+	fmt.Println("  rip [device]          Rip a disc into the MPD library, the first disc by default")
+	fmt.Println("  cancel-rip [device]   Cancel the rip of a disc, the first disc by default")
+	fmt.Println("  verify [device]       Check a disc against the AccurateRip database, the first disc by default")
+	fmt.Println("  import [device]       Import a USB drive into the MPD library, the first USB drive by default")
+	fmt.Println("  cancel-import [device] Cancel the import of a USB drive, the first USB drive by default")
+	fmt.Println("  play and trigger accept --mode <replace|append|next|load> to override the queue mode")
 }
 
 func signalMonitor(ctx context.Context, cancel context.CancelFunc) {
@@ -0,0 +1,151 @@
+// Package manifest reads the autoplay manifest at the root of USB drives,
+// declaring what the drive plays and how.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// FileName is the manifest file
+	FileName = ".mpd-discplayer.yaml"
+	// AutoplayPlaylist is a playlist played as is when there is no manifest
+	// file, whatever the playlist policy
+	AutoplayPlaylist = "autoplay.m3u"
+)
+
+// Manifest declares how a USB drive is played. Its paths are relative to
+// the root of the drive.
+type Manifest struct {
+	// Autoplay false leaves the drive mounted without playing it
+	Autoplay *bool `yaml:"autoplay"`
+	// Playlist is a m3u, pls or xspf playlist to play instead of the whole
+	// drive
+	Playlist string `yaml:"playlist"`
+	// Folder is a subfolder to play instead of the whole drive
+	Folder string `yaml:"folder"`
+	// Shuffle shuffles the tracks once queued
+	Shuffle bool  `yaml:"shuffle"`
+	Random  *bool `yaml:"random"`
+	Repeat  *bool `yaml:"repeat"`
+	Volume  *int  `yaml:"volume"`
+	// Start is the track the playback starts at, from 1
+	Start int `yaml:"start"`
+
+	// Entries are the files of the playlist, relative to the drive root
+	Entries []string `yaml:"-"`
+
+	// source is the file the manifest comes from, named in its errors
+	source string
+}
+
+// Load reads and validates the manifest of the drive mounted at root, nil
+// when the drive has none. Without a playlist or folder to play, the
+// playlists at the root of the drive are chosen from by policy.
+func Load(root string, policy PlaylistPolicy) (*Manifest, error) {
+	m, err := readManifest(root)
+	if err != nil {
+		return nil, err
+	}
+	found := m != nil
+	if m == nil {
+		m = &Manifest{}
+		if _, err := os.Stat(filepath.Join(root, AutoplayPlaylist)); err == nil {
+			log.Printf("Found %s in %s", AutoplayPlaylist, root)
+			m.Playlist = AutoplayPlaylist
+			m.source = AutoplayPlaylist
+			found = true
+		}
+	}
+	if m.Playlist == "" && m.Folder == "" {
+		if m.Playlist, m.Entries = findPlaylist(root, policy); m.Playlist != "" {
+			if m.source == "" {
+				m.source = m.Playlist
+			}
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	return m, m.validate(root)
+}
+
+// readManifest decodes the manifest file of the drive mounted at root, nil
+// when there is none.
+func readManifest(root string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(root, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	m := &Manifest{source: FileName}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid %s: %w", FileName, err)
+	}
+	log.Printf("Found %s in %s", FileName, root)
+	return m, nil
+}
+
+// AutoplayEnabled reports whether the drive is played when inserted.
+func (m *Manifest) AutoplayEnabled() bool {
+	return m.Autoplay == nil || *m.Autoplay
+}
+
+func (m *Manifest) validate(root string) error {
+	if m.Playlist != "" && m.Folder != "" {
+		return fmt.Errorf("invalid %s: playlist and folder are exclusive", m.source)
+	}
+	if m.Volume != nil && (*m.Volume < 0 || *m.Volume > 100) {
+		return fmt.Errorf("invalid %s: volume %d is not between 0 and 100", m.source, *m.Volume)
+	}
+	if m.Start < 0 {
+		return fmt.Errorf("invalid %s: start %d is not a track number", m.source, m.Start)
+	}
+	if m.Folder != "" {
+		folder, err := drivePath(m.Folder)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", m.source, err)
+		}
+		info, err := os.Stat(filepath.Join(root, folder))
+		if err != nil || !info.IsDir() {
+			return fmt.Errorf("invalid %s: folder %s not found", m.source, m.Folder)
+		}
+		m.Folder = folder
+	}
+	if m.Playlist != "" && m.Entries == nil {
+		playlist, err := drivePath(m.Playlist)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", m.source, err)
+		}
+		if m.Entries, err = readPlaylist(root, playlist); err != nil {
+			return fmt.Errorf("invalid %s: %w", m.source, err)
+		}
+		m.Playlist = playlist
+	}
+	return nil
+}
+
+// drivePath cleans a path relative to the drive root, refusing those leaving
+// the drive.
+func drivePath(p string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(filepath.ToSlash(p), "/"))
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%s is not on the drive", p)
+	}
+	return clean, nil
+}
@@ -0,0 +1,199 @@
+package manifest
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	root := writeDrive(t, map[string]string{
+		FileName: `autoplay: false
+folder: /Audiobook/
+shuffle: true
+random: true
+repeat: false
+volume: 40
+start: 3
+`,
+		"Audiobook/01.mp3": "",
+		"mix.m3u":          "Audiobook/01.mp3\n",
+	})
+	m, err := Load(root, PlaylistName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.AutoplayEnabled() {
+		t.Error("autoplay enabled")
+	}
+	// a folder to play leaves the root playlists alone
+	if m.Folder != "Audiobook" || m.Playlist != "" {
+		t.Errorf("folder = %q, playlist = %q, want the Audiobook folder", m.Folder, m.Playlist)
+	}
+	if !m.Shuffle || m.Random == nil || !*m.Random || m.Repeat == nil || *m.Repeat {
+		t.Errorf("shuffle = %v, random = %v, repeat = %v", m.Shuffle, m.Random, m.Repeat)
+	}
+	if m.Volume == nil || *m.Volume != 40 || m.Start != 3 {
+		t.Errorf("volume = %v, start = %d", m.Volume, m.Start)
+	}
+}
+
+func TestLoadWithoutManifest(t *testing.T) {
+	root := writeDrive(t, map[string]string{"Album/01.flac": ""})
+	m, err := Load(root, PlaylistName)
+	if err != nil || m != nil {
+		t.Fatalf("Load = %v, %v, want no manifest", m, err)
+	}
+}
+
+func TestLoadEmptyManifest(t *testing.T) {
+	root := writeDrive(t, map[string]string{FileName: ""})
+	m, err := Load(root, PlaylistName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || !m.AutoplayEnabled() || m.Playlist != "" || m.Folder != "" {
+		t.Fatalf("manifest = %+v, want the whole drive autoplayed", m)
+	}
+}
+
+func TestLoadAutoplayPlaylist(t *testing.T) {
+	root := writeDrive(t, map[string]string{
+		AutoplayPlaylist: "Album/02.flac\nAlbum/01.flac\n",
+		// played as is, whatever the policy
+		"a.m3u": "Album/01.flac\n",
+	})
+	m, err := Load(root, PlaylistName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Playlist != AutoplayPlaylist {
+		t.Fatalf("playlist = %q, want %s", m.Playlist, AutoplayPlaylist)
+	}
+	if want := []string{"Album/02.flac", "Album/01.flac"}; !slices.Equal(m.Entries, want) {
+		t.Fatalf("entries = %q, want %q", m.Entries, want)
+	}
+}
+
+func TestLoadRootPlaylist(t *testing.T) {
+	root := writeDrive(t, map[string]string{
+		FileName: "shuffle: true\n",
+		"b.m3u":  "Album/01.flac\n",
+		"a.m3u":  "Album/02.flac\n",
+	})
+	m, err := Load(root, PlaylistName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Playlist != "a.m3u" || !m.Shuffle {
+		t.Fatalf("playlist = %q, shuffle = %v, want a.m3u shuffled", m.Playlist, m.Shuffle)
+	}
+	if m, err := Load(root, PlaylistNone); err != nil || m.Playlist != "" {
+		t.Fatalf("Load = %+v, %v, want the root playlists ignored", m, err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// want is in the error, along with the file at fault
+		want string
+		file string
+	}{
+		{
+			name:  "unknown key",
+			files: map[string]string{FileName: "autoplay: true\nshufle: true\n"},
+			want:  "shufle",
+			file:  FileName,
+		},
+		{
+			name:  "not yaml",
+			files: map[string]string{FileName: "autoplay: [true\n"},
+			file:  FileName,
+		},
+		{
+			name: "playlist and folder",
+			files: map[string]string{
+				FileName:       "playlist: mix.m3u\nfolder: Album\n",
+				"mix.m3u":      "Album/01.flac\n",
+				"Album/01.mp3": "",
+			},
+			want: "exclusive",
+			file: FileName,
+		},
+		{
+			name:  "volume below 0",
+			files: map[string]string{FileName: "volume: -1\n"},
+			want:  "volume -1",
+			file:  FileName,
+		},
+		{
+			name:  "volume above 100",
+			files: map[string]string{FileName: "volume: 101\n"},
+			want:  "volume 101",
+			file:  FileName,
+		},
+		{
+			name:  "negative start",
+			files: map[string]string{FileName: "start: -2\n"},
+			want:  "start -2",
+			file:  FileName,
+		},
+		{
+			name:  "folder leaving the drive",
+			files: map[string]string{FileName: "folder: ../other\n"},
+			want:  "not on the drive",
+			file:  FileName,
+		},
+		{
+			name:  "folder escaping through the drive",
+			files: map[string]string{FileName: "folder: Album/../../other\n", "Album/01.mp3": ""},
+			want:  "not on the drive",
+			file:  FileName,
+		},
+		{
+			name:  "missing folder",
+			files: map[string]string{FileName: "folder: Album\n"},
+			want:  "folder Album not found",
+			file:  FileName,
+		},
+		{
+			name:  "playlist leaving the drive",
+			files: map[string]string{FileName: "playlist: ../mix.m3u\n"},
+			want:  "not on the drive",
+			file:  FileName,
+		},
+		{
+			name:  "missing playlist",
+			files: map[string]string{FileName: "playlist: mix.m3u\n"},
+			want:  "mix.m3u",
+			file:  FileName,
+		},
+		{
+			name:  "empty autoplay playlist",
+			files: map[string]string{AutoplayPlaylist: "#EXTM3U\n../outside.flac\n"},
+			want:  "empty",
+			file:  AutoplayPlaylist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeDrive(t, tt.files), PlaylistName)
+			if err == nil {
+				t.Fatal("invalid manifest loaded")
+			}
+			if !strings.Contains(err.Error(), "invalid "+tt.file) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %q, want invalid %s: ...%s...", err, tt.file, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBounds(t *testing.T) {
+	for _, manifest := range []string{"volume: 0\n", "volume: 100\n", "start: 0\n", "start: 1\n"} {
+		if _, err := Load(writeDrive(t, map[string]string{FileName: manifest}), PlaylistName); err != nil {
+			t.Fatalf("%q: %v", manifest, err)
+		}
+	}
+}
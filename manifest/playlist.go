@@ -0,0 +1,251 @@
+package manifest
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PlaylistPolicy chooses the playlist played among those at the root of a
+// drive.
+type PlaylistPolicy string
+
+const (
+	// PlaylistNone ignores the playlists, playing the whole drive
+	PlaylistNone PlaylistPolicy = "none"
+	// PlaylistName plays the first playlist by name
+	PlaylistName PlaylistPolicy = "name"
+	// PlaylistNewest plays the most recently modified playlist
+	PlaylistNewest PlaylistPolicy = "newest"
+	// PlaylistLongest plays the playlist with the most entries
+	PlaylistLongest PlaylistPolicy = "longest"
+)
+
+var PlaylistPolicies = []PlaylistPolicy{PlaylistNone, PlaylistName, PlaylistNewest, PlaylistLongest}
+
+func ParsePlaylistPolicy(policy string) (PlaylistPolicy, error) {
+	for _, p := range PlaylistPolicies {
+		if PlaylistPolicy(policy) == p {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("invalid playlist policy %q, expected one of %v", policy, PlaylistPolicies)
+}
+
+// playlistReaders parse the playlist formats by extension, returning their
+// raw entries.
+var playlistReaders = map[string]func(io.Reader) ([]string, error){
+	".m3u":  readM3U,
+	".m3u8": readM3U,
+	".pls":  readPLS,
+	".xspf": readXSPF,
+}
+
+// IsPlaylist reports whether name is a supported playlist file.
+func IsPlaylist(name string) bool {
+	_, ok := playlistReaders[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// rootPlaylist is a playlist found at the root of a drive.
+type rootPlaylist struct {
+	name    string
+	entries []string
+	modTime int64
+}
+
+// findPlaylist returns the playlist at the root of the drive mounted at root
+// chosen by policy, with its entries. Empty if there is none.
+func findPlaylist(root string, policy PlaylistPolicy) (string, []string) {
+	if policy == PlaylistNone {
+		return "", nil
+	}
+	dirEntries, err := os.ReadDir(root)
+	if err != nil {
+		log.Printf("warning: failed to look for playlists in %s: %v", root, err)
+		return "", nil
+	}
+	var playlists []rootPlaylist
+	for _, entry := range dirEntries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !IsPlaylist(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		entries, err := readPlaylist(root, entry.Name())
+		if err != nil {
+			log.Printf("warning: ignoring playlist %s: %v", entry.Name(), err)
+			continue
+		}
+		playlists = append(playlists, rootPlaylist{
+			name:    entry.Name(),
+			entries: entries,
+			modTime: info.ModTime().UnixNano(),
+		})
+	}
+	if len(playlists) == 0 {
+		return "", nil
+	}
+	// Ties are broken by name, so the choice doesn't depend on the directory order
+	sort.SliceStable(playlists, func(i, j int) bool {
+		switch policy {
+		case PlaylistNewest:
+			if playlists[i].modTime != playlists[j].modTime {
+				return playlists[i].modTime > playlists[j].modTime
+			}
+		case PlaylistLongest:
+			if len(playlists[i].entries) != len(playlists[j].entries) {
+				return len(playlists[i].entries) > len(playlists[j].entries)
+			}
+		}
+		return playlists[i].name < playlists[j].name
+	})
+	chosen := playlists[0]
+	if len(playlists) > 1 {
+		log.Printf("Found %d playlists in %s, playing %s (%s)", len(playlists), root, chosen.name, policy)
+	} else {
+		log.Printf("Found playlist %s in %s", chosen.name, root)
+	}
+	return chosen.name, chosen.entries
+}
+
+// readPlaylist returns the entries of a playlist on the drive, relative to
+// the drive root. Remote URIs are kept as is.
+func readPlaylist(root, playlist string) ([]string, error) {
+	reader, ok := playlistReaders[strings.ToLower(path.Ext(playlist))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported playlist format %s", playlist)
+	}
+	f, err := os.Open(filepath.Join(root, playlist))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open playlist: %w", err)
+	}
+	defer f.Close()
+
+	raw, err := reader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playlist %s: %w", playlist, err)
+	}
+	var entries []string
+	for _, entry := range raw {
+		file, err := resolveEntry(root, path.Dir(playlist), entry)
+		if err != nil {
+			log.Printf("warning: skipping %s from %s: %v", entry, playlist, err)
+			continue
+		}
+		entries = append(entries, file)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("playlist %s is empty", playlist)
+	}
+	return entries, nil
+}
+
+// resolveEntry returns a playlist entry relative to the drive root, dir being
+// the playlist folder. Relative entries may use Windows separators, those
+// starting with one being relative to the drive root, as written on Windows.
+func resolveEntry(root, dir, entry string) (string, error) {
+	if strings.Contains(entry, "://") {
+		return entry, nil
+	}
+	if !path.IsAbs(entry) {
+		entry = strings.ReplaceAll(entry, `\`, "/")
+		if path.IsAbs(entry) {
+			return drivePath(entry)
+		}
+		return drivePath(path.Join(dir, entry))
+	}
+	// Absolute entries only work if written with the drive mount point
+	rel, err := filepath.Rel(root, entry)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("not on the drive")
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func readM3U(r io.Reader) ([]string, error) {
+	var entries []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		entry := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// readPLS returns the FileN entries of a pls playlist, in N order.
+func readPLS(r io.Reader) ([]string, error) {
+	files := make(map[int]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || !strings.HasPrefix(strings.ToLower(key), "file") {
+			continue
+		}
+		n, err := strconv.Atoi(key[len("file"):])
+		if err != nil {
+			continue
+		}
+		files[n] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	numbers := make([]int, 0, len(files))
+	for n := range files {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	entries := make([]string, 0, len(numbers))
+	for _, n := range numbers {
+		entries = append(entries, files[n])
+	}
+	return entries, nil
+}
+
+type xspfPlaylist struct {
+	Tracks []struct {
+		Location string `xml:"location"`
+	} `xml:"trackList>track"`
+}
+
+// readXSPF returns the track locations of a xspf playlist, file URLs being
+// turned into paths.
+func readXSPF(r io.Reader) ([]string, error) {
+	var playlist xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&playlist); err != nil {
+		return nil, err
+	}
+	var entries []string
+	for _, track := range playlist.Tracks {
+		location := strings.TrimSpace(track.Location)
+		if location == "" {
+			continue
+		}
+		u, err := url.Parse(location)
+		if err != nil {
+			entries = append(entries, location)
+			continue
+		}
+		if u.Scheme == "file" || u.Scheme == "" {
+			entries = append(entries, u.Path)
+		} else {
+			entries = append(entries, location)
+		}
+	}
+	return entries, nil
+}
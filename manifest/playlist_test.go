@@ -0,0 +1,189 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeDrive writes files, by path relative to the drive root, to a new
+// drive root.
+func writeDrive(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestReadPlaylist(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist string
+		// content of the playlist, ROOT standing for the drive root
+		content string
+		want    []string
+	}{
+		{
+			name:     "m3u",
+			playlist: "mix.m3u",
+			content:  "\ufeff#EXTM3U\n#EXTINF:123,Intro\nAlbum/01 Intro.flac\n\n  Album/02 Song.flac  \n",
+			want:     []string{"Album/01 Intro.flac", "Album/02 Song.flac"},
+		},
+		{
+			name:     "m3u8 with Windows separators",
+			playlist: "mix.m3u8",
+			content:  "Album\\01 Intro.flac\r\n\\Album\\02 Song.flac\r\n",
+			want:     []string{"Album/01 Intro.flac", "Album/02 Song.flac"},
+		},
+		{
+			name:     "entries relative to the playlist folder",
+			playlist: "lists/mix.m3u",
+			content:  "../Album/01 Intro.flac\nlocal.flac\n",
+			want:     []string{"Album/01 Intro.flac", "lists/local.flac"},
+		},
+		{
+			name:     "entries leaving the drive skipped",
+			playlist: "mix.m3u",
+			content:  "../other/01.flac\nAlbum/../../02.flac\nAlbum/03.flac\n",
+			want:     []string{"Album/03.flac"},
+		},
+		{
+			name:     "absolute entries under the mount point",
+			playlist: "mix.m3u",
+			content:  "ROOT/Album/01 Intro.flac\n/media/other/02.flac\n",
+			want:     []string{"Album/01 Intro.flac"},
+		},
+		{
+			name:     "URLs kept",
+			playlist: "radio.m3u",
+			content:  "http://radio.example/stream.mp3\nAlbum/01 Intro.flac\n",
+			want:     []string{"http://radio.example/stream.mp3", "Album/01 Intro.flac"},
+		},
+		{
+			name:     "pls in number order",
+			playlist: "mix.pls",
+			content:  "[playlist]\nFile2=Album/02 Song.flac\nTitle2=Song\nfile1=Album/01 Intro.flac\nFileX=ignored.flac\nNumberOfEntries=2\nVersion=2\n",
+			want:     []string{"Album/01 Intro.flac", "Album/02 Song.flac"},
+		},
+		{
+			name:     "xspf",
+			playlist: "mix.xspf",
+			content: `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <trackList>
+    <track><location>file://ROOT/My%20Album/01.flac</location></track>
+    <track><location>Album/02%20Song.flac</location></track>
+    <track><location>https://radio.example/stream</location></track>
+    <track><title>no location</title></track>
+  </trackList>
+</playlist>`,
+			want: []string{"My Album/01.flac", "Album/02 Song.flac", "https://radio.example/stream"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			content := strings.ReplaceAll(tt.content, "ROOT", filepath.ToSlash(root))
+			file := filepath.Join(root, filepath.FromSlash(tt.playlist))
+			if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readPlaylist(root, tt.playlist)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("entries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPlaylistWithoutEntries(t *testing.T) {
+	root := writeDrive(t, map[string]string{"mix.m3u": "#EXTM3U\n../outside.flac\n"})
+	if _, err := readPlaylist(root, "mix.m3u"); err == nil {
+		t.Fatal("read a playlist without entries on the drive")
+	}
+}
+
+func TestFindPlaylist(t *testing.T) {
+	root := writeDrive(t, map[string]string{
+		"b.m3u":            "1.flac\n2.flac\n",
+		"a.pls":            "[playlist]\nFile1=1.flac\nFile2=2.flac\n",
+		"c.xspf":           `<playlist><trackList><track><location>1.flac</location></track></trackList></playlist>`,
+		"empty.m3u":        "#EXTM3U\n",
+		".hidden.m3u":      "1.flac\n2.flac\n3.flac\n",
+		"notes.txt":        "1.flac\n2.flac\n3.flac\n",
+		"Album/inner.m3u8": "1.flac\n2.flac\n3.flac\n",
+	})
+	// c is the newest, a and b are as old
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"a.pls", "b.m3u", "empty.m3u"} {
+		if err := os.Chtimes(filepath.Join(root, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		policy PlaylistPolicy
+		want   string
+	}{
+		{PlaylistNone, ""},
+		{PlaylistName, "a.pls"},
+		{PlaylistNewest, "c.xspf"},
+		// a and b are as long, the tie is broken by name
+		{PlaylistLongest, "a.pls"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			got, entries := findPlaylist(root, tt.policy)
+			if got != tt.want {
+				t.Fatalf("playlist = %q, want %q", got, tt.want)
+			}
+			if got != "" && len(entries) == 0 {
+				t.Fatal("playlist chosen without its entries")
+			}
+		})
+	}
+}
+
+func TestFindPlaylistNewestTieBreak(t *testing.T) {
+	root := writeDrive(t, map[string]string{
+		"b.m3u": "1.flac\n",
+		"a.m3u": "1.flac\n",
+	})
+	now := time.Now()
+	for _, name := range []string{"a.m3u", "b.m3u"} {
+		if err := os.Chtimes(filepath.Join(root, name), now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, _ := findPlaylist(root, PlaylistNewest); got != "a.m3u" {
+		t.Fatalf("playlist = %q, want a.m3u", got)
+	}
+}
+
+func TestParsePlaylistPolicy(t *testing.T) {
+	for _, policy := range PlaylistPolicies {
+		if got, err := ParsePlaylistPolicy(string(policy)); err != nil || got != policy {
+			t.Fatalf("ParsePlaylistPolicy(%s) = %s, %v", policy, got, err)
+		}
+	}
+	if _, err := ParsePlaylistPolicy("shortest"); err == nil {
+		t.Fatal("parsed an unknown policy")
+	}
+}
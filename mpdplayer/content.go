@@ -0,0 +1,184 @@
+package mpdplayer
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// ContentOrder tells how the files of a USB drive are sorted when queued.
+type ContentOrder string
+
+const (
+	// OrderDatabase keeps the MPD database order
+	OrderDatabase ContentOrder = "database"
+	// OrderPath sorts the files by path
+	OrderPath ContentOrder = "path"
+	// OrderTags sorts the files by album, disc and track number
+	OrderTags ContentOrder = "tags"
+	// OrderModified sorts the files from the oldest modified
+	OrderModified ContentOrder = "modified"
+)
+
+var ContentOrders = []ContentOrder{OrderDatabase, OrderPath, OrderTags, OrderModified}
+
+func ParseContentOrder(order string) (ContentOrder, error) {
+	for _, o := range ContentOrders {
+		if ContentOrder(order) == o {
+			return o, nil
+		}
+	}
+	return "", fmt.Errorf("invalid content order %q, expected one of %v", order, ContentOrders)
+}
+
+// systemFolders are created by operating systems on removable drives.
+var systemFolders = map[string]bool{
+	"System Volume Information": true,
+	"$RECYCLE.BIN":              true,
+	"RECYCLER":                  true,
+	"LOST.DIR":                  true,
+	"lost+found":                true,
+	"__MACOSX":                  true,
+}
+
+// USBContent selects and sorts the files of USB drives. Glob patterns are
+// matched against the path relative to the drive root, and against each
+// file and folder name in it.
+type USBContent struct {
+	Order ContentOrder
+	// Include patterns, files must match one of them when set
+	Include []string
+	// Exclude patterns, files matching one of them are skipped
+	Exclude []string
+	// SkipHidden skips the hidden files and folders, and those created by
+	// operating systems
+	SkipHidden bool
+}
+
+func NewUSBContent(order ContentOrder, include, exclude []string, skipHidden bool) *USBContent {
+	return &USBContent{
+		Order:      order,
+		Include:    include,
+		Exclude:    exclude,
+		SkipHidden: skipHidden,
+	}
+}
+
+// addToQueue adds the selected files found under uri, in the USB drive
+// mounted at label, in order.
+func (content *USBContent) addToQueue(client *mpd.Client, label, uri string) error {
+	songs, err := client.ListAllInfo(uri)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", uri, err)
+	}
+	songs = content.filter(label, songs)
+	if len(songs) == 0 {
+		return fmt.Errorf("no file to play in %s", uri)
+	}
+	content.sort(songs)
+	for _, song := range songs {
+		if err := addUri(client, song["file"]); err != nil {
+			return err
+		}
+	}
+	log.Printf("Added %d files of %s to queue, in %s order", len(songs), uri, content.Order)
+	return nil
+}
+
+func (content *USBContent) filter(label string, songs []mpd.Attrs) []mpd.Attrs {
+	selected := songs[:0]
+	for _, song := range songs {
+		file, ok := labelFile(label, song["file"])
+		if !ok {
+			continue
+		}
+		if content.SkipHidden && isHidden(file) {
+			continue
+		}
+		if len(content.Include) > 0 && !matchAny(content.Include, file) {
+			continue
+		}
+		if matchAny(content.Exclude, file) {
+			continue
+		}
+		selected = append(selected, song)
+	}
+	return selected
+}
+
+func (content *USBContent) sort(songs []mpd.Attrs) {
+	switch content.Order {
+	case OrderPath:
+		sort.SliceStable(songs, func(i, j int) bool {
+			return songs[i]["file"] < songs[j]["file"]
+		})
+	case OrderTags:
+		sort.SliceStable(songs, func(i, j int) bool {
+			return tagsLess(songs[i], songs[j])
+		})
+	case OrderModified:
+		// RFC 3339 dates in UTC sort as strings
+		sort.SliceStable(songs, func(i, j int) bool {
+			if songs[i]["Last-Modified"] != songs[j]["Last-Modified"] {
+				return songs[i]["Last-Modified"] < songs[j]["Last-Modified"]
+			}
+			return songs[i]["file"] < songs[j]["file"]
+		})
+	}
+}
+
+// tagsLess sorts songs by album, disc and track, then path.
+func tagsLess(a, b mpd.Attrs) bool {
+	if a["Album"] != b["Album"] {
+		return a["Album"] < b["Album"]
+	}
+	if discA, discB := tagNumber(a["Disc"]), tagNumber(b["Disc"]); discA != discB {
+		return discA < discB
+	}
+	if trackA, trackB := tagNumber(a["Track"]), tagNumber(b["Track"]); trackA != trackB {
+		return trackA < trackB
+	}
+	return a["file"] < b["file"]
+}
+
+// tagNumber parses a disc or track tag, e.g. 3 or 3/12.
+func tagNumber(tag string) int {
+	number, _, _ := strings.Cut(tag, "/")
+	return atoiOr(strings.TrimSpace(number), 0)
+}
+
+// isHidden reports whether file is, or is in, a hidden or system folder.
+func isHidden(file string) bool {
+	for _, name := range strings.Split(file, "/") {
+		if strings.HasPrefix(name, ".") || systemFolders[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches pattern against file and each of its names.
+func matchGlob(pattern, file string) bool {
+	if ok, _ := path.Match(pattern, file); ok {
+		return true
+	}
+	for _, name := range strings.Split(file, "/") {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
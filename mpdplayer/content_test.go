@@ -0,0 +1,118 @@
+package mpdplayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer/mpdtest"
+)
+
+// seedDrive fills the database with the USB drive mounted at MUSIC, holding
+// hidden files, a system folder, a cover, a bonus folder and albums tagged
+// out of path order.
+func seedDrive(server *mpdtest.Server) {
+	server.AddToDatabase(
+		"MUSIC/._01.flac",
+		"MUSIC/.hidden/x.flac",
+		"MUSIC/System Volume Information/x.flac",
+		"MUSIC/B/01.flac",
+		"MUSIC/B/02.flac",
+		"MUSIC/B/Bonus/99.flac",
+		"MUSIC/B/cd2/01.flac",
+		"MUSIC/B/cover.jpg",
+		"MUSIC/Z/z.flac",
+		"OTHER/a.flac",
+	)
+	server.SetTags("MUSIC/B/01.flac", map[string]string{"Album": "B", "Disc": "2/2", "Track": "10/12", "Last-Modified": "2024-01-01T10:00:00Z"})
+	server.SetTags("MUSIC/B/02.flac", map[string]string{"Album": "B", "Disc": "2/2", "Track": "2/12", "Last-Modified": "2024-01-02T10:00:00Z"})
+	server.SetTags("MUSIC/B/cd2/01.flac", map[string]string{"Album": "B", "Disc": "1/2", "Track": "1", "Last-Modified": "2024-01-01T10:00:00Z"})
+	server.SetTags("MUSIC/Z/z.flac", map[string]string{"Album": "A", "Track": "1", "Last-Modified": "2024-01-03T10:00:00Z"})
+}
+
+func TestStartUSBPlaybackContent(t *testing.T) {
+	filtered := func(order mpdplayer.ContentOrder) *mpdplayer.USBContent {
+		return mpdplayer.NewUSBContent(order, []string{"*.flac"}, []string{"Bonus"}, true)
+	}
+	tests := []struct {
+		name    string
+		content *mpdplayer.USBContent
+		want    []string
+	}{
+		{
+			name:    "database",
+			content: filtered(mpdplayer.OrderDatabase),
+			want:    []string{"MUSIC/B/01.flac", "MUSIC/B/02.flac", "MUSIC/B/cd2/01.flac", "MUSIC/Z/z.flac"},
+		},
+		{
+			name:    "path",
+			content: filtered(mpdplayer.OrderPath),
+			want:    []string{"MUSIC/B/01.flac", "MUSIC/B/02.flac", "MUSIC/B/cd2/01.flac", "MUSIC/Z/z.flac"},
+		},
+		{
+			// by album, then disc and track numbers out of their totals
+			name:    "tags",
+			content: filtered(mpdplayer.OrderTags),
+			want:    []string{"MUSIC/Z/z.flac", "MUSIC/B/cd2/01.flac", "MUSIC/B/02.flac", "MUSIC/B/01.flac"},
+		},
+		{
+			// files modified at once are sorted by path
+			name:    "modified",
+			content: filtered(mpdplayer.OrderModified),
+			want:    []string{"MUSIC/B/01.flac", "MUSIC/B/cd2/01.flac", "MUSIC/B/02.flac", "MUSIC/Z/z.flac"},
+		},
+		{
+			name:    "hidden files kept",
+			content: mpdplayer.NewUSBContent(mpdplayer.OrderPath, []string{"*.flac"}, nil, false),
+			want: []string{
+				"MUSIC/._01.flac",
+				"MUSIC/.hidden/x.flac",
+				"MUSIC/B/01.flac",
+				"MUSIC/B/02.flac",
+				"MUSIC/B/Bonus/99.flac",
+				"MUSIC/B/cd2/01.flac",
+				"MUSIC/System Volume Information/x.flac",
+				"MUSIC/Z/z.flac",
+			},
+		},
+		{
+			// patterns also match the path from the drive root
+			name:    "path patterns",
+			content: mpdplayer.NewUSBContent(mpdplayer.OrderPath, []string{"B/*"}, []string{"B/cover.jpg"}, true),
+			want:    []string{"MUSIC/B/01.flac", "MUSIC/B/02.flac"},
+		},
+	}
+	for network, server := range servers(t) {
+		client := newClient(t, server, time.Second)
+		seedDrive(server)
+		for _, tt := range tests {
+			t.Run(network+"/"+tt.name, func(t *testing.T) {
+				server.SetQueue()
+				options := &mpdplayer.USBOptions{Content: tt.content}
+				if err := client.StartUSBPlayback("MUSIC", mpdplayer.QueueReplace, options); err != nil {
+					t.Fatal(err)
+				}
+				assertQueue(t, server, tt.want...)
+			})
+		}
+		t.Run(network+"/nothing selected", func(t *testing.T) {
+			options := &mpdplayer.USBOptions{
+				Content: mpdplayer.NewUSBContent(mpdplayer.OrderPath, []string{"*.ogg"}, nil, true),
+			}
+			if err := client.StartUSBPlayback("MUSIC", mpdplayer.QueueReplace, options); err == nil {
+				t.Fatal("played a drive without a selected file")
+			}
+		})
+	}
+}
+
+func TestParseContentOrder(t *testing.T) {
+	for _, order := range mpdplayer.ContentOrders {
+		if got, err := mpdplayer.ParseContentOrder(string(order)); err != nil || got != order {
+			t.Fatalf("ParseContentOrder(%s) = %s, %v", order, got, err)
+		}
+	}
+	if _, err := mpdplayer.ParseContentOrder("size"); err == nil {
+		t.Fatal("parsed an unknown order")
+	}
+}
@@ -0,0 +1,269 @@
+package mpdplayer_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer"
+	"github.com/b0bbywan/go-mpd-discplayer/mpdplayer/mpdtest"
+)
+
+// servers starts a fake MPD server over TCP and one over a unix socket, so
+// each test runs against both transports.
+func servers(t *testing.T) map[string]*mpdtest.Server {
+	t.Helper()
+	tcp, err := mpdtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tcp.Close)
+	unix, err := mpdtest.NewUnixServer(filepath.Join(t.TempDir(), "mpd.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(unix.Close)
+	return map[string]*mpdtest.Server{"tcp": tcp, "unix": unix}
+}
+
+func newClient(t *testing.T, server *mpdtest.Server, reconnectWait time.Duration) *mpdplayer.ReconnectingMPDClient {
+	t.Helper()
+	conn, err := mpdplayer.NewMPDConnection(server.Network, server.Addr, reconnectWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client := mpdplayer.NewReconnectingMPDClient(ctx, conn)
+	t.Cleanup(client.Disconnect)
+	return client
+}
+
+func countCommands(server *mpdtest.Server, name string) int {
+	count := 0
+	for _, command := range server.Commands() {
+		if command == name || strings.HasPrefix(command, name+" ") {
+			count++
+		}
+	}
+	return count
+}
+
+func assertQueue(t *testing.T, server *mpdtest.Server, want ...string) {
+	t.Helper()
+	if got := server.Queue(); !slices.Equal(got, want) {
+		t.Fatalf("queue = %v, want %v", got, want)
+	}
+}
+
+func assertState(t *testing.T, server *mpdtest.Server, wantState string, wantSong int) {
+	t.Helper()
+	if state, song := server.State(); state != wantState || song != wantSong {
+		t.Fatalf("state = %s song %d, want %s song %d", state, song, wantState, wantSong)
+	}
+}
+
+func TestStartPlaybackQueueModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      mpdplayer.QueueMode
+		playing   bool
+		wantQueue []string
+		wantState string
+		wantSong  int
+	}{
+		{"replace", mpdplayer.QueueReplace, true, []string{"new/1.flac", "new/2.flac"}, "play", 0},
+		{"append", mpdplayer.QueueAppend, true, []string{"old/1.flac", "old/2.flac", "new/1.flac", "new/2.flac"}, "play", 0},
+		{"append stopped", mpdplayer.QueueAppend, false, []string{"old/1.flac", "old/2.flac", "new/1.flac", "new/2.flac"}, "play", 2},
+		{"next", mpdplayer.QueueNext, true, []string{"old/1.flac", "new/1.flac", "new/2.flac", "old/2.flac"}, "play", 0},
+		{"load", mpdplayer.QueueLoad, false, []string{"old/1.flac", "old/2.flac", "new/1.flac", "new/2.flac"}, "stop", -1},
+	}
+	for network, server := range servers(t) {
+		client := newClient(t, server, time.Second)
+		server.AddToDatabase("old/1.flac", "old/2.flac", "new/1.flac", "new/2.flac")
+		for _, tt := range tests {
+			t.Run(network+"/"+tt.name, func(t *testing.T) {
+				server.SetQueue("old/1.flac", "old/2.flac")
+				if tt.playing {
+					server.Play(0)
+				}
+				if err := client.StartPlayback("new", tt.mode); err != nil {
+					t.Fatal(err)
+				}
+				assertQueue(t, server, tt.wantQueue...)
+				assertState(t, server, tt.wantState, tt.wantSong)
+			})
+		}
+	}
+}
+
+func TestExecuteReconnectsAndRetries(t *testing.T) {
+	for network, server := range servers(t) {
+		t.Run(network, func(t *testing.T) {
+			client := newClient(t, server, time.Second)
+			server.AddToDatabase("album/1.flac")
+			if err := client.Connect(); err != nil {
+				t.Fatal(err)
+			}
+			server.DropOn("add", 1)
+			if err := client.StartPlayback("album", mpdplayer.QueueReplace); err != nil {
+				t.Fatalf("StartPlayback after a dropped connection: %v", err)
+			}
+			assertQueue(t, server, "album/1.flac")
+			if n := countCommands(server, "add"); n != 2 {
+				t.Fatalf("add sent %d times, want 2: the dropped one and its retry", n)
+			}
+
+			// a connection closed behind the client's back is reopened too
+			server.DropConnections()
+			if _, err := client.Status(); err != nil {
+				t.Fatalf("Status after the connections were closed: %v", err)
+			}
+		})
+	}
+}
+
+func TestExecuteDoesNotRetryCommandErrors(t *testing.T) {
+	for network, server := range servers(t) {
+		t.Run(network, func(t *testing.T) {
+			client := newClient(t, server, time.Second)
+			server.AddToDatabase("album/1.flac")
+			server.FailOn("add", 1, "disk on fire")
+			err := client.StartPlayback("album", mpdplayer.QueueReplace)
+			if err == nil || !strings.Contains(err.Error(), "disk on fire") {
+				t.Fatalf("StartPlayback error = %v, want the ACK error", err)
+			}
+			if n := countCommands(server, "add"); n != 1 {
+				t.Fatalf("add sent %d times, want 1", n)
+			}
+		})
+	}
+}
+
+func TestExecuteGivesUpWhenServerIsGone(t *testing.T) {
+	server, err := mpdtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(t, server, 200*time.Millisecond)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	start := time.Now()
+	if _, err := client.Status(); err == nil {
+		t.Fatal("Status succeeded without server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("gave up after %s, want about the reconnect wait", elapsed)
+	}
+}
+
+func TestStopPlaybackRemovesDeviceSongs(t *testing.T) {
+	for network, server := range servers(t) {
+		t.Run(network, func(t *testing.T) {
+			client := newClient(t, server, time.Second)
+			server.SetQueue("radio.m3u", "USB_DISK/1.mp3", "USB_DISK/2.mp3", "Other/1.mp3")
+			server.Play(1)
+			if err := client.StopPlayback("USB_DISK"); err != nil {
+				t.Fatal(err)
+			}
+			assertQueue(t, server, "radio.m3u", "Other/1.mp3")
+			if state, _ := server.State(); state != "stop" {
+				t.Fatalf("state = %s, want stop as a removed song was playing", state)
+			}
+		})
+	}
+}
+
+func TestMounts(t *testing.T) {
+	for network, server := range servers(t) {
+		t.Run(network, func(t *testing.T) {
+			client := newClient(t, server, time.Second)
+			server.AddNeighbor("udisks://by-uuid-1234-ABCD", "USB DISK")
+			storage, err := client.Mount([]string{"1234-ABCD"}, "USB_DISK")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if storage != "udisks://by-uuid-1234-ABCD" {
+				t.Fatalf("mounted storage = %s", storage)
+			}
+			mounts, err := client.Mounts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mounts["USB_DISK"] != "udisks://by-uuid-1234-ABCD" {
+				t.Fatalf("mounts = %v, want USB_DISK mounted", mounts)
+			}
+			if _, err := client.Mount([]string{"5678"}, "Other"); err == nil {
+				t.Fatal("Mount succeeded without neighbor")
+			}
+			if err := client.Unmount("USB_DISK"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := server.Mounts()["USB_DISK"]; ok {
+				t.Fatal("USB_DISK still mounted")
+			}
+			if err := client.Unmount("USB_DISK"); err == nil {
+				t.Fatal("Unmount of a missing mount succeeded")
+			}
+		})
+	}
+}
+
+func TestSnapshotAndRestoreQueue(t *testing.T) {
+	for network, server := range servers(t) {
+		t.Run(network, func(t *testing.T) {
+			client := newClient(t, server, time.Second)
+			server.AddToDatabase("radio/a.mp3", "radio/b.mp3", "radio/c.mp3")
+			server.SetQueue("radio/a.mp3", "radio/b.mp3", "radio/c.mp3")
+			server.Play(1)
+			server.SetElapsed(42)
+
+			snapshot, err := client.SnapshotQueue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snapshot == nil || snapshot.Song != 1 || snapshot.Elapsed != 42 || snapshot.State != "play" {
+				t.Fatalf("snapshot = %+v", snapshot)
+			}
+
+			// a non empty queue is left alone
+			if restored, err := client.RestoreQueue(snapshot); err != nil || restored {
+				t.Fatalf("RestoreQueue on a full queue = %v, %v", restored, err)
+			}
+
+			server.SetQueue()
+			server.DropOn("status", 1)
+			restored, err := client.RestoreQueue(snapshot)
+			if err != nil || !restored {
+				t.Fatalf("RestoreQueue = %v, %v", restored, err)
+			}
+			assertQueue(t, server, "radio/a.mp3", "radio/b.mp3", "radio/c.mp3")
+			assertState(t, server, "play", 1)
+			if elapsed := server.Elapsed(); elapsed != 42 {
+				t.Fatalf("elapsed = %v, want 42", elapsed)
+			}
+		})
+	}
+}
+
+func TestSongTags(t *testing.T) {
+	for network, server := range servers(t) {
+		t.Run(network, func(t *testing.T) {
+			client := newClient(t, server, time.Second)
+			server.AddToDatabase("USB/a/1.flac", "USB/a/2.flac", "Other/1.flac")
+			server.SetTags("USB/a/1.flac", map[string]string{"Title": "One", "Track": "1"})
+			tags, err := client.SongTags("USB")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tags) != 2 || tags["a/1.flac"]["Title"] != "One" {
+				t.Fatalf("tags = %v", tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,510 @@
+package mpdtest
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ACK error codes, as defined in MPD's Ack.hxx.
+const (
+	ackArg        = 2
+	ackPermission = 4
+	ackUnknown    = 5
+	ackNoExist    = 50
+	ackSystem     = 52
+	ackExist      = 56
+)
+
+type ackError struct {
+	code int
+	msg  string
+}
+
+func ack(code int, format string, args ...interface{}) *ackError {
+	return &ackError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// execute runs a command against the server state and writes its response,
+// without the trailing OK.
+func (s *Server) execute(w *bufio.Writer, name string, args []string, local bool) *ackError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch name {
+	case "ping":
+		return nil
+	case "clear":
+		s.queue = nil
+		s.current = -1
+		s.state = "stop"
+		s.version++
+		return nil
+	case "add", "addid":
+		return s.add(w, name, args)
+	case "load":
+		return s.load(args, local)
+	case "play":
+		return s.play(args)
+	case "seek":
+		return s.seek(args)
+	case "seekcur":
+		if len(args) != 1 || s.state == "stop" {
+			return ack(ackArg, "Not playing")
+		}
+		elapsed, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return ack(ackArg, "Float expected: %s", args[0])
+		}
+		s.elapsed = elapsed
+		return nil
+	case "random", "repeat":
+		if len(args) != 1 || (args[0] != "0" && args[0] != "1") {
+			return ack(ackArg, "Boolean (0/1) expected")
+		}
+		if name == "random" {
+			s.random = args[0] == "1"
+		} else {
+			s.repeat = args[0] == "1"
+		}
+		return nil
+	case "setvol":
+		volume, err := strconv.Atoi(strings.Join(args, ""))
+		if err != nil || volume < 0 || volume > 100 {
+			return ack(ackArg, "Invalid volume value")
+		}
+		s.volume = volume
+		return nil
+	case "shuffle":
+		return s.shuffle(args)
+	case "stop":
+		s.state = "stop"
+		s.elapsed = 0
+		return nil
+	case "pause":
+		if s.state != "stop" {
+			if len(args) > 0 && args[0] == "0" {
+				s.state = "play"
+			} else {
+				s.state = "pause"
+			}
+		}
+		return nil
+	case "status":
+		s.writeStatus(w)
+		return nil
+	case "currentsong":
+		if s.current >= 0 && s.current < len(s.queue) {
+			writeSong(w, s.current, s.queue[s.current])
+		}
+		return nil
+	case "listallinfo":
+		return s.listAllInfo(w, args)
+	case "playlistinfo":
+		return s.playlistInfo(w, args)
+	case "delete":
+		return s.delete(args)
+	case "move":
+		return s.move(args)
+	case "save":
+		return s.save(args)
+	case "rm":
+		if len(args) != 1 {
+			return ack(ackArg, "wrong number of arguments")
+		}
+		if _, ok := s.playlists[args[0]]; !ok {
+			return ack(ackNoExist, "No such playlist")
+		}
+		delete(s.playlists, args[0])
+		return nil
+	case "update", "rescan":
+		s.updateJob++
+		s.updateUntil = time.Now().Add(s.updateDelay)
+		fmt.Fprintf(w, "updating_db: %d\n", s.updateJob)
+		return nil
+	case "listneighbors":
+		uris := make([]string, 0, len(s.neighbors))
+		for uri := range s.neighbors {
+			uris = append(uris, uri)
+		}
+		sort.Strings(uris)
+		for _, uri := range uris {
+			fmt.Fprintf(w, "neighbor: %s\nname: %s\n", uri, s.neighbors[uri])
+		}
+		return nil
+	case "mount":
+		return s.mount(args)
+	case "unmount":
+		if len(args) != 1 {
+			return ack(ackArg, "wrong number of arguments")
+		}
+		if _, ok := s.mounts[args[0]]; !ok {
+			return ack(ackNoExist, "Not a mount point")
+		}
+		delete(s.mounts, args[0])
+		return nil
+	case "listmounts":
+		fmt.Fprintf(w, "mount: \nstorage: %s\n", s.musicDir)
+		names := make([]string, 0, len(s.mounts))
+		for name := range s.mounts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "mount: %s\nstorage: %s\n", name, s.mounts[name])
+		}
+		return nil
+	case "config":
+		if !local {
+			return ack(ackPermission, "Command only permitted to local clients")
+		}
+		fmt.Fprintf(w, "music_directory: %s\n", s.musicDir)
+		return nil
+	default:
+		return ack(ackUnknown, "unknown command \"%s\"", name)
+	}
+}
+
+func (s *Server) add(w *bufio.Writer, name string, args []string) *ackError {
+	if len(args) < 1 || len(args) > 2 {
+		return ack(ackArg, "wrong number of arguments")
+	}
+	files := s.lookup(args[0])
+	if len(files) == 0 {
+		return ack(ackNoExist, "No such directory")
+	}
+	if name == "addid" && len(files) != 1 {
+		return ack(ackNoExist, "No such song")
+	}
+
+	start := len(s.queue)
+	pos := start
+	if len(args) == 2 {
+		var err error
+		if pos, err = strconv.Atoi(args[1]); err != nil || pos < 0 || pos > start {
+			return ack(ackArg, "Bad song index")
+		}
+	}
+	for _, file := range files {
+		s.appendSong(file)
+	}
+	if pos != start {
+		s.moveRange(start, len(s.queue), pos)
+	}
+	if name == "addid" {
+		fmt.Fprintf(w, "Id: %d\n", s.nextID-1)
+	}
+	return nil
+}
+
+func (s *Server) load(args []string, local bool) *ackError {
+	if len(args) < 1 {
+		return ack(ackArg, "wrong number of arguments")
+	}
+	files, ok := s.playlists[args[0]]
+	if !ok {
+		if !filepath.IsAbs(args[0]) {
+			return ack(ackNoExist, "No such playlist")
+		}
+		if !local {
+			return ack(ackPermission, "Access denied")
+		}
+		var err error
+		if files, err = readPlaylistFile(args[0]); err != nil {
+			return ack(ackNoExist, "No such playlist")
+		}
+	}
+	if len(args) == 2 {
+		start, end, err := parseRange(args[1], len(files))
+		if err != nil {
+			return err
+		}
+		files = files[start:end]
+	}
+	for _, file := range files {
+		s.appendSong(file)
+	}
+	return nil
+}
+
+func (s *Server) play(args []string) *ackError {
+	pos := s.current
+	if len(args) > 0 {
+		var err error
+		if pos, err = strconv.Atoi(args[0]); err != nil {
+			return ack(ackArg, "Integer expected: %s", args[0])
+		}
+		if pos >= len(s.queue) {
+			return ack(ackArg, "Bad song index")
+		}
+	}
+	if len(s.queue) == 0 {
+		return nil
+	}
+	if pos < 0 {
+		pos = 0
+	}
+	s.current = pos
+	s.state = "play"
+	s.elapsed = 0
+	return nil
+}
+
+func (s *Server) seek(args []string) *ackError {
+	if len(args) != 2 {
+		return ack(ackArg, "wrong number of arguments")
+	}
+	pos, err := strconv.Atoi(args[0])
+	if err != nil {
+		return ack(ackArg, "Integer expected: %s", args[0])
+	}
+	if pos < 0 || pos >= len(s.queue) {
+		return ack(ackArg, "Bad song index")
+	}
+	elapsed, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return ack(ackArg, "Float expected: %s", args[1])
+	}
+	s.current = pos
+	s.elapsed = elapsed
+	if s.state == "stop" {
+		s.state = "play"
+	}
+	return nil
+}
+
+func (s *Server) writeStatus(w *bufio.Writer) {
+	fmt.Fprintf(w, "volume: %d\nrepeat: %d\nrandom: %d\nsingle: 0\nconsume: 0\n", s.volume, boolInt(s.repeat), boolInt(s.random))
+	fmt.Fprintf(w, "playlist: %d\nplaylistlength: %d\n", s.version, len(s.queue))
+	fmt.Fprintf(w, "state: %s\n", s.state)
+	if s.current >= 0 && s.current < len(s.queue) {
+		fmt.Fprintf(w, "song: %d\nsongid: %d\n", s.current, s.queue[s.current].ID)
+		if s.state != "stop" {
+			fmt.Fprintf(w, "elapsed: %.3f\n", s.elapsed)
+		}
+	}
+	if time.Now().Before(s.updateUntil) {
+		fmt.Fprintf(w, "updating_db: %d\n", s.updateJob)
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func writeSong(w *bufio.Writer, pos int, song Song) {
+	fmt.Fprintf(w, "file: %s\nPos: %d\nId: %d\n", song.File, pos, song.ID)
+}
+
+func (s *Server) playlistInfo(w *bufio.Writer, args []string) *ackError {
+	start, end := 0, len(s.queue)
+	if len(args) > 0 {
+		var err *ackError
+		if start, end, err = parseRange(args[0], len(s.queue)); err != nil {
+			return err
+		}
+	}
+	for i := start; i < end; i++ {
+		writeSong(w, i, s.queue[i])
+	}
+	return nil
+}
+
+func (s *Server) delete(args []string) *ackError {
+	if len(args) != 1 {
+		return ack(ackArg, "wrong number of arguments")
+	}
+	start, end, err := parseRange(args[0], len(s.queue))
+	if err != nil {
+		return err
+	}
+	s.queue = append(s.queue[:start], s.queue[end:]...)
+	switch {
+	case s.current >= end:
+		s.current -= end - start
+	case s.current >= start:
+		// The current song was deleted, playback goes on with the next one
+		s.current = start
+		if s.current >= len(s.queue) {
+			s.current = -1
+			s.state = "stop"
+		}
+	}
+	s.version++
+	return nil
+}
+
+func (s *Server) move(args []string) *ackError {
+	if len(args) != 2 {
+		return ack(ackArg, "wrong number of arguments")
+	}
+	start, end, err := parseRange(args[0], len(s.queue))
+	if err != nil {
+		return err
+	}
+	to, convErr := strconv.Atoi(args[1])
+	if convErr != nil || to < 0 || to+end-start > len(s.queue) {
+		return ack(ackArg, "Bad song index")
+	}
+	s.moveRange(start, end, to)
+	return nil
+}
+
+// moveRange moves the songs in [start, end) so that the first one ends at to.
+func (s *Server) moveRange(start, end, to int) {
+	var current Song
+	if s.current >= 0 {
+		current = s.queue[s.current]
+	}
+	moved := append([]Song(nil), s.queue[start:end]...)
+	rest := append(append([]Song(nil), s.queue[:start]...), s.queue[end:]...)
+	s.queue = append(append(append([]Song(nil), rest[:to]...), moved...), rest[to:]...)
+	if s.current >= 0 {
+		for i, song := range s.queue {
+			if song.ID == current.ID {
+				s.current = i
+			}
+		}
+	}
+	s.version++
+}
+
+func (s *Server) save(args []string) *ackError {
+	if len(args) != 1 {
+		return ack(ackArg, "wrong number of arguments")
+	}
+	if _, ok := s.playlists[args[0]]; ok {
+		return ack(ackExist, "Playlist already exists")
+	}
+	files := make([]string, len(s.queue))
+	for i, song := range s.queue {
+		files[i] = song.File
+	}
+	s.playlists[args[0]] = files
+	return nil
+}
+
+func (s *Server) mount(args []string) *ackError {
+	if len(args) != 2 {
+		return ack(ackArg, "wrong number of arguments")
+	}
+	name, uri := args[0], args[1]
+	if strings.Contains(name, "/") {
+		return ack(ackArg, "Bad mount point")
+	}
+	if _, ok := s.mounts[name]; ok {
+		return ack(ackArg, "Mount point busy")
+	}
+	if _, ok := s.neighbors[uri]; !ok && !strings.Contains(uri, "://") {
+		return ack(ackArg, "Unrecognized storage URI")
+	}
+	s.mounts[name] = uri
+	return nil
+}
+
+// listAllInfo lists the database files under a uri, with their tags.
+func (s *Server) listAllInfo(w *bufio.Writer, args []string) *ackError {
+	uri := ""
+	if len(args) > 0 {
+		uri = args[0]
+	}
+	files := s.lookup(uri)
+	if len(files) == 0 && uri != "" {
+		return ack(ackNoExist, "No such directory")
+	}
+	for _, file := range files {
+		fmt.Fprintf(w, "file: %s\n", file)
+		tags := s.tags[file]
+		names := make([]string, 0, len(tags))
+		for name := range tags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s: %s\n", name, tags[name])
+		}
+	}
+	return nil
+}
+
+// shuffle reverses the songs of the range, a predictable shuffle. The
+// current song is followed.
+func (s *Server) shuffle(args []string) *ackError {
+	start, end := 0, len(s.queue)
+	if len(args) == 1 {
+		var ackErr *ackError
+		if start, end, ackErr = parseRange(args[0], len(s.queue)); ackErr != nil {
+			return ackErr
+		}
+	}
+	slices.Reverse(s.queue[start:end])
+	if s.current >= start && s.current < end {
+		s.current = start + end - 1 - s.current
+	}
+	s.version++
+	return nil
+}
+
+// parseRange parses a song position or a START:END range, END being
+// optional, and checks it against length.
+func parseRange(arg string, length int) (int, int, *ackError) {
+	startArg, endArg, isRange := strings.Cut(arg, ":")
+	start, err := strconv.Atoi(startArg)
+	if err != nil || start < 0 {
+		return 0, 0, ack(ackArg, "Integer expected: %s", arg)
+	}
+	end := start + 1
+	if isRange {
+		end = length
+		if endArg != "" {
+			if end, err = strconv.Atoi(endArg); err != nil {
+				return 0, 0, ack(ackArg, "Integer expected: %s", arg)
+			}
+		}
+	}
+	if start > end || end > length || (start == length && !isRange) {
+		return 0, 0, ack(ackArg, "Bad song index")
+	}
+	return start, end, nil
+}
+
+// tokenize splits a command line into its arguments, handling MPD quoting.
+func tokenize(line string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				b.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return nil, fmt.Errorf("missing closing '\"'")
+			}
+			i++
+			args = append(args, b.String())
+		default:
+			j := i
+			for j < len(line) && line[j] != ' ' && line[j] != '\t' {
+				j++
+			}
+			args = append(args, line[i:j])
+			i = j
+		}
+	}
+	return args, nil
+}
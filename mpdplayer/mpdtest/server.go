@@ -0,0 +1,417 @@
+// Package mpdtest provides an in-process MPD server speaking enough of the
+// protocol to drive ReconnectingMPDClient in tests, over TCP or unix sockets.
+//
+// The server keeps a queue, a database, stored playlists, neighbors and
+// mounts in memory, and can be scripted to drop connections or fail commands
+// to exercise the client reconnection logic.
+package mpdtest
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const protocolVersion = "0.23.5"
+
+// Song is an entry of the queue.
+type Song struct {
+	ID   int
+	File string
+}
+
+// Server is a fake MPD server. Create one with NewServer or NewUnixServer.
+type Server struct {
+	// Network and Addr are the values to pass to mpd.Dial.
+	Network string
+	Addr    string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu           sync.Mutex
+	conns        map[net.Conn]struct{}
+	queue        []Song
+	current      int
+	state        string
+	elapsed      float64
+	volume       int
+	random       bool
+	repeat       bool
+	nextID       int
+	version      int
+	updateJob    int
+	updateUntil  time.Time
+	updateDelay  time.Duration
+	database     []string
+	tags         map[string]map[string]string
+	playlists    map[string][]string
+	neighbors    map[string]string
+	mounts       map[string]string
+	musicDir     string
+	commands     []string
+	drops        map[string]int
+	failures     map[string]int
+	failureError string
+}
+
+// NewServer starts a server listening on a random local TCP port.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	return newServer(listener), nil
+}
+
+// NewUnixServer starts a server listening on the given unix socket path.
+func NewUnixServer(path string) (*Server, error) {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	return newServer(listener), nil
+}
+
+func newServer(listener net.Listener) *Server {
+	s := &Server{
+		Network:   listener.Addr().Network(),
+		Addr:      listener.Addr().String(),
+		listener:  listener,
+		conns:     make(map[net.Conn]struct{}),
+		current:   -1,
+		state:     "stop",
+		volume:    100,
+		nextID:    1,
+		tags:      make(map[string]map[string]string),
+		playlists: make(map[string][]string),
+		neighbors: make(map[string]string),
+		mounts:    make(map[string]string),
+		musicDir:  "/var/lib/mpd/music",
+		drops:     make(map[string]int),
+		failures:  make(map[string]int),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close stops the listener and closes every open connection.
+func (s *Server) Close() {
+	if err := s.listener.Close(); err != nil {
+		log.Printf("mpdtest: failed to close listener: %v", err)
+	}
+	s.DropConnections()
+	s.wg.Wait()
+	if s.Network == "unix" {
+		_ = os.Remove(s.Addr)
+	}
+}
+
+// DropConnections closes every open client connection.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// DropOn closes the client connection the next n times command is received,
+// before it is executed.
+func (s *Server) DropOn(command string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops[command] += n
+}
+
+// FailOn answers the next n occurrences of command with an ACK error.
+func (s *Server) FailOn(command string, n int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[command] += n
+	s.failureError = message
+}
+
+// AddToDatabase registers files in the database, so that they and their
+// parent directories can be added to the queue.
+func (s *Server) AddToDatabase(files ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.database = append(s.database, files...)
+	sort.Strings(s.database)
+}
+
+// SetTags sets the tags listed with a database file, e.g. Album or
+// Last-Modified.
+func (s *Server) SetTags(file string, tags map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags[file] = tags
+}
+
+// SetPlaylist creates or replaces a stored playlist.
+func (s *Server) SetPlaylist(name string, files ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.playlists[name] = files
+}
+
+// Playlist returns the content of a stored playlist.
+func (s *Server) Playlist(name string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, ok := s.playlists[name]
+	return append([]string(nil), files...), ok
+}
+
+// AddNeighbor registers a storage uri such as udisks://by-uuid-1234 as
+// reported by the neighbor plugin.
+func (s *Server) AddNeighbor(uri, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.neighbors[uri] = name
+}
+
+// RemoveNeighbor forgets a neighbor, as when the device is unplugged.
+func (s *Server) RemoveNeighbor(uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.neighbors, uri)
+}
+
+// SetMusicDirectory sets the music_directory reported by the config command.
+func (s *Server) SetMusicDirectory(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.musicDir = dir
+}
+
+// SetUpdateDelay sets how long database updates report as running.
+func (s *Server) SetUpdateDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateDelay = d
+}
+
+// SetQueue replaces the queue and stops playback.
+func (s *Server) SetQueue(files ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = nil
+	for _, file := range files {
+		s.appendSong(file)
+	}
+	s.current = -1
+	s.state = "stop"
+}
+
+// Queue returns the files of the queue in order.
+func (s *Server) Queue() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := make([]string, len(s.queue))
+	for i, song := range s.queue {
+		files[i] = song.File
+	}
+	return files
+}
+
+// Play starts playing the song at pos, as if another client did.
+func (s *Server) Play(pos int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pos >= 0 && pos < len(s.queue) {
+		s.current = pos
+		s.state = "play"
+		s.elapsed = 0
+	}
+}
+
+// State returns the player state (play, stop or pause) and the position of
+// the current song, -1 if there is none.
+func (s *Server) State() (string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state, s.current
+}
+
+// SetElapsed sets the elapsed time of the current song, as if it had been
+// playing for that long.
+func (s *Server) SetElapsed(elapsed float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.elapsed = elapsed
+}
+
+// Elapsed returns the elapsed time of the current song.
+func (s *Server) Elapsed() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.elapsed
+}
+
+// Options returns the random and repeat flags.
+func (s *Server) Options() (random, repeat bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.random, s.repeat
+}
+
+// Volume returns the volume, from 0 to 100.
+func (s *Server) Volume() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.volume
+}
+
+// Mounts returns the mounted storages by mount name.
+func (s *Server) Mounts() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mounts := make(map[string]string, len(s.mounts))
+	for k, v := range s.mounts {
+		mounts[k] = v
+	}
+	return mounts
+}
+
+// Commands returns every command received so far, as sent on the wire.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "OK MPD %s\n", protocolVersion)
+	if err := w.Flush(); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		args, err := tokenize(line)
+		if err != nil || len(args) == 0 {
+			fmt.Fprintf(w, "ACK [%d@0] {} %v\n", ackArg, err)
+			_ = w.Flush()
+			continue
+		}
+		name := args[0]
+		if name == "close" {
+			return
+		}
+
+		drop, failure := s.record(line, name)
+		if drop {
+			return
+		}
+		if failure != "" {
+			fmt.Fprintf(w, "ACK [%d@0] {%s} %s\n", ackSystem, name, failure)
+		} else if err := s.execute(w, name, args[1:], conn.LocalAddr().Network() == "unix"); err != nil {
+			fmt.Fprintf(w, "ACK [%d@0] {%s} %s\n", err.code, name, err.msg)
+		} else {
+			fmt.Fprintln(w, "OK")
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// record logs a command and consumes the scripted failures matching it.
+func (s *Server) record(line, name string) (bool, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, line)
+	if s.drops[name] > 0 {
+		s.drops[name]--
+		return true, ""
+	}
+	if s.failures[name] > 0 {
+		s.failures[name]--
+		return false, s.failureError
+	}
+	return false, ""
+}
+
+func (s *Server) appendSong(file string) {
+	s.queue = append(s.queue, Song{ID: s.nextID, File: file})
+	s.nextID++
+	s.version++
+}
+
+// lookup resolves uri to database files: the file itself or the content of
+// a directory. Uris with a scheme are added as is, like streams or cdda.
+func (s *Server) lookup(uri string) []string {
+	if strings.Contains(uri, "://") {
+		return []string{uri}
+	}
+	uri = strings.Trim(uri, "/")
+	var files []string
+	for _, file := range s.database {
+		if uri == "" || file == uri || strings.HasPrefix(file, uri+"/") {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// readPlaylistFile loads the entries of a local m3u or cue playlist file.
+func readPlaylistFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	isCue := strings.EqualFold(filepath.Ext(path), ".cue")
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case isCue && strings.HasPrefix(line, "FILE "):
+			if fields, err := tokenize(strings.TrimPrefix(line, "FILE ")); err == nil && len(fields) > 0 {
+				files = append(files, fields[0])
+			}
+		case isCue, strings.HasPrefix(line, "#"):
+		default:
+			files = append(files, line)
+		}
+	}
+	return files, scanner.Err()
+}
@@ -0,0 +1,37 @@
+package mpdtest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+func TestAddWithBadPositionLeavesQueue(t *testing.T) {
+	server, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.AddToDatabase("album/1.flac", "album/2.flac")
+	server.SetQueue("radio.m3u")
+
+	client, err := mpd.Dial(server.Network, server.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Command("add %s %s", "album", mpd.Quoted("5")).OK(); err == nil {
+		t.Fatal("add at a position past the queue succeeded")
+	}
+	if got := server.Queue(); !slices.Equal(got, []string{"radio.m3u"}) {
+		t.Fatalf("queue = %v after a failed add", got)
+	}
+	if err := client.Command("add %s %s", "album", mpd.Quoted("0")).OK(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := server.Queue(), []string{"album/1.flac", "album/2.flac", "radio.m3u"}; !slices.Equal(got, want) {
+		t.Fatalf("queue = %v, want %v", got, want)
+	}
+}
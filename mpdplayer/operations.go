@@ -3,6 +3,9 @@ package mpdplayer
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,51 +19,139 @@ const CDDAPathPrefix = "cdda://"
 
 type PlaybackAction func(client *mpd.Client, device string) error
 
-func (rc *ReconnectingMPDClient) StartDiscPlayback(device string) error {
-	return rc.startPlayback(rc.attemptToLoadCD, device)
+// pathMatcher tells whether a queued file belongs to a device.
+type pathMatcher func(path string) bool
+
+// DiscURI returns the cdda URI of device, tracks being read from
+// DiscURI(device)/N. An empty device is MPD's default drive.
+func DiscURI(device string) string {
+	return CDDAPathPrefix + device
 }
 
-func (rc *ReconnectingMPDClient) StartUSBPlayback(device string) error {
-	return rc.startPlayback(addUSBToQueue, device)
+// StartDiscPlayback loads the disc in device, with tracks tied to that drive.
+func (rc *ReconnectingMPDClient) StartDiscPlayback(device string, mode QueueMode) error {
+	return rc.startPlayback(rc.attemptToLoadCD, device, mode)
 }
 
-func (rc *ReconnectingMPDClient) StartPlayback(uri string) error {
-	return rc.startPlayback(addUri, uri)
+// StartUSBPlayback loads the USB drive mounted at label, as told by options
+// when given.
+func (rc *ReconnectingMPDClient) StartUSBPlayback(label string, mode QueueMode, options *USBOptions) error {
+	if options == nil {
+		return rc.startPlayback(addUSBToQueue, label, mode)
+	}
+	if err := rc.startPlayback(options.addToQueue, label, mode); err != nil {
+		return err
+	}
+	return rc.execute(func(client *mpd.Client) error {
+		return options.apply(client, label)
+	})
+}
+
+func (rc *ReconnectingMPDClient) StartPlayback(uri string, mode QueueMode) error {
+	return rc.startPlayback(addUri, uri, mode)
 }
 
 // StartDiscPlayback now accepts a custom playback function
-func (rc *ReconnectingMPDClient) startPlayback(playbackFunc PlaybackAction, device string) error {
+func (rc *ReconnectingMPDClient) startPlayback(playbackFunc PlaybackAction, device string, mode QueueMode) error {
 	return rc.execute(func(client *mpd.Client) error {
-		if err := clearQueue(client); err != nil {
-			return fmt.Errorf("failed to clear MPD queue: %w", err)
+		slot, err := prepareQueue(client, mode)
+		if err != nil {
+			return fmt.Errorf("failed to prepare MPD queue: %w", err)
 		}
 		// Use the provided playback function
 		if err := playbackFunc(client, device); err != nil {
 			return fmt.Errorf("failed to load playlist: %w", err)
 		}
-		return client.Play(-1)
+		if err := slot.place(client); err != nil {
+			return err
+		}
+		return slot.play(client)
 	})
 }
 
-func (rc *ReconnectingMPDClient) StopDiscPlayback() error {
-	return rc.StopPlayback(CDDAPathPrefix)
+// StopDiscPlayback removes the tracks of the disc in device from the queue,
+// stopping the playback if one of them is playing. Tracks added without a
+// device, e.g. by a cdda:// schedule, are removed along with any disc, and
+// an empty device removes every disc track.
+func (rc *ReconnectingMPDClient) StopDiscPlayback(device string) error {
+	return rc.stopPlayback(discMatcher(device))
 }
 
 func (rc *ReconnectingMPDClient) StopPlayback(label string) error {
+	return rc.stopPlayback(prefixMatcher(label))
+}
+
+func (rc *ReconnectingMPDClient) stopPlayback(match pathMatcher) error {
 	return rc.execute(func(client *mpd.Client) error {
-		if checkPathPlaying(client, label) {
+		if checkPathPlaying(client, match) {
 			if err := client.Stop(); err != nil {
 				return fmt.Errorf("error: Failed to stop MPD playback: %w", err)
 			}
 		}
-		return deleteFromPlaylist(client, label)
+		return deleteFromPlaylist(client, match)
 	})
 }
 
-func (rc *ReconnectingMPDClient) Mount(identifiers []string, label string) error {
-	return rc.execute(func(client *mpd.Client) error {
-		neighborURI, err := findNeighbor(client, identifiers)
+// DiscProgress tells how far the playback of a disc went.
+type DiscProgress int
+
+const (
+	// DiscNotQueued is when no track of the disc is queued
+	DiscNotQueued DiscProgress = iota
+	// DiscNotCurrent is when tracks of the disc are queued, none of them
+	// being the current song
+	DiscNotCurrent
+	// DiscCurrent is when a track of the disc is the current song
+	DiscCurrent
+	// DiscLastTrack is when the last queued track of the disc is the
+	// current song
+	DiscLastTrack
+)
+
+// DiscProgress returns how far the playback of the disc in device went,
+// whatever the play state. MPD has no current song once it played the end
+// of the queue.
+func (rc *ReconnectingMPDClient) DiscProgress(device string) (DiscProgress, error) {
+	progress := DiscNotQueued
+	err := rc.execute(func(client *mpd.Client) error {
+		status, err := client.Status()
+		if err != nil {
+			return fmt.Errorf("failed to get MPD status: %w", err)
+		}
+		playlist, err := client.PlaylistInfo(-1, -1)
 		if err != nil {
+			return fmt.Errorf("failed to fetch MPD playlist: %w", err)
+		}
+		match := discMatcher(device)
+		last := -1
+		for i, song := range playlist {
+			if checkSongPath(song, match) {
+				last = i
+			}
+		}
+		if last < 0 {
+			return nil
+		}
+		progress = DiscNotCurrent
+		song := atoiOr(status["song"], -1)
+		switch {
+		case song == last:
+			progress = DiscLastTrack
+		case song >= 0 && song < last && checkSongPath(playlist[song], match):
+			progress = DiscCurrent
+		}
+		return nil
+	})
+	return progress, err
+}
+
+// Mount mounts the neighbor matching one of identifiers at label in the MPD
+// library, and returns its storage URI.
+func (rc *ReconnectingMPDClient) Mount(identifiers []string, label string) (string, error) {
+	var neighborURI string
+	err := rc.execute(func(client *mpd.Client) error {
+		var err error
+		if neighborURI, err = findNeighbor(client, identifiers); err != nil {
 			return fmt.Errorf("failed to find neighbor for %v: %w", identifiers, err)
 		}
 		if err := mount(client, neighborURI, label); err != nil {
@@ -68,6 +159,7 @@ func (rc *ReconnectingMPDClient) Mount(identifiers []string, label string) error
 		}
 		return nil
 	})
+	return neighborURI, err
 }
 
 func (rc *ReconnectingMPDClient) Unmount(label string) error {
@@ -79,18 +171,20 @@ func (rc *ReconnectingMPDClient) Unmount(label string) error {
 	})
 }
 
-func (rc *ReconnectingMPDClient) ClearMounts() error {
-	return rc.execute(func(client *mpd.Client) error {
-		mounts, err := listMounts(client)
+// Mounts returns the storages mounted in the MPD library, by mount path.
+func (rc *ReconnectingMPDClient) Mounts() (map[string]string, error) {
+	mounts := make(map[string]string)
+	err := rc.execute(func(client *mpd.Client) error {
+		list, err := listMounts(client)
 		if err != nil {
-			return fmt.Errorf("failed to list mounts: %w", err)
+			return err
 		}
-
-		if err = checkMountsOrUnmount(client, mounts); err != nil {
-			return fmt.Errorf("failed to check or unmount: %w", err)
+		for _, m := range list {
+			mounts[m["mount"]] = m["storage"]
 		}
 		return nil
 	})
+	return mounts, err
 }
 
 func (rc *ReconnectingMPDClient) GetConfig() (string, error) {
@@ -109,6 +203,84 @@ func (rc *ReconnectingMPDClient) GetConfig() (string, error) {
 	return musicDirectory, nil
 }
 
+// PlayerStatus is a snapshot of the MPD player state and current song.
+type PlayerStatus struct {
+	State       string
+	Song        int
+	Elapsed     float64
+	QueueLength int
+	File        string
+	Artist      string
+	Album       string
+	Title       string
+}
+
+func (rc *ReconnectingMPDClient) Status() (*PlayerStatus, error) {
+	var status, song mpd.Attrs
+	if err := rc.execute(func(client *mpd.Client) error {
+		var err error
+		if status, err = client.Status(); err != nil {
+			return fmt.Errorf("failed to get MPD status: %w", err)
+		}
+		if song, err = client.CurrentSong(); err != nil {
+			return fmt.Errorf("failed to get current song: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return newPlayerStatus(status, song), nil
+}
+
+// DiscCue returns the cue sheet of the disc in device, generated from its
+// metadata unless already cached.
+func (rc *ReconnectingMPDClient) DiscCue(device string) (string, error) {
+	if rc.mpcConfig.CuerConfig == nil {
+		return "", fmt.Errorf("no Cuer config to generate from")
+	}
+	cueFilePath, err := cue.New(rc.mpcConfig.CuerConfig).Generate(cue.Options{Device: device})
+	if err != nil || cueFilePath == "" {
+		return "", fmt.Errorf("failed to generate CUE file: %w", err)
+	}
+	return cueFilePath, nil
+}
+
+// UpdateDB starts the update of uri in the MPD database, without waiting
+// for it to end.
+func (rc *ReconnectingMPDClient) UpdateDB(uri string) error {
+	return rc.execute(func(client *mpd.Client) error {
+		if _, err := client.Update(uri); err != nil {
+			return fmt.Errorf("failed to update %s: %w", uri, err)
+		}
+		return nil
+	})
+}
+
+// SongTags returns the tags of the songs under uri in the MPD database, by
+// file relative to uri.
+func (rc *ReconnectingMPDClient) SongTags(uri string) (map[string]mpd.Attrs, error) {
+	tags := make(map[string]mpd.Attrs)
+	err := rc.execute(func(client *mpd.Client) error {
+		songs, err := client.ListAllInfo(uri)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", uri, err)
+		}
+		for _, song := range songs {
+			if file, ok := labelFile(uri, song["file"]); ok {
+				tags[file] = song
+			}
+		}
+		return nil
+	})
+	return tags, err
+}
+
+func (rc *ReconnectingMPDClient) Stop() error {
+	return rc.execute(func(client *mpd.Client) error {
+		return client.Stop()
+	})
+}
+
 // attemptToLoadCD tries to load the CD by first attempting to load a CUE file.
 // If loading the CUE file fails, it falls back to loading individual CDDA tracks,
 func (rc *ReconnectingMPDClient) attemptToLoadCD(client *mpd.Client, device string) error {
@@ -141,7 +313,7 @@ func loadCDDATracks(client *mpd.Client, device string) error {
 		return fmt.Errorf("failed to get track count: %w", err)
 	}
 
-	return addTracks(client, trackCount)
+	return addTracks(client, device, trackCount)
 }
 
 func loadCue(client *mpd.Client, cuerConfig *config.Config, device string) error {
@@ -152,6 +324,9 @@ func loadCue(client *mpd.Client, cuerConfig *config.Config, device string) error
 	if err != nil || cueFilePath == "" {
 		return fmt.Errorf("failed to generate CUE file: %w", err)
 	}
+	if cueFilePath, err = deviceCue(cueFilePath, device); err != nil {
+		return err
+	}
 	log.Printf("info: Loading playlist from %s\n", cueFilePath)
 	if err := client.PlaylistLoad(cueFilePath, -1, -1); err != nil {
 		return fmt.Errorf("failed to load CUE playlist: %w", err)
@@ -159,10 +334,32 @@ func loadCue(client *mpd.Client, cuerConfig *config.Config, device string) error
 	return nil
 }
 
+// deviceCue writes a copy of the cached cue sheet at path with its tracks
+// read from device, as cached sheets are shared by all drives.
+func deviceCue(path, device string) (string, error) {
+	if device == "" {
+		return path, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read CUE file: %w", err)
+	}
+	content := strings.ReplaceAll(
+		string(data),
+		fmt.Sprintf(`FILE "%s/`, CDDAPathPrefix),
+		fmt.Sprintf(`FILE "%s/`, DiscURI(device)),
+	)
+	devicePath := fmt.Sprintf("%s.%s.cue", strings.TrimSuffix(path, filepath.Ext(path)), filepath.Base(device))
+	if err := os.WriteFile(devicePath, []byte(content), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write CUE file for %s: %w", device, err)
+	}
+	return devicePath, nil
+}
+
 // addTracks adds individual CDDA tracks to the MPD playlist based on the specified track count.
-func addTracks(client *mpd.Client, trackCount int) error {
+func addTracks(client *mpd.Client, device string, trackCount int) error {
 	for track := 1; track <= trackCount; track++ {
-		if err := addUri(client, fmt.Sprintf("%s/%d", CDDAPathPrefix, track)); err != nil {
+		if err := addUri(client, fmt.Sprintf("%s/%d", DiscURI(device), track)); err != nil {
 			return fmt.Errorf("failed to add track %d: %w", track, err)
 		}
 	}
@@ -170,7 +367,7 @@ func addTracks(client *mpd.Client, trackCount int) error {
 	return nil
 }
 
-func checkPathPlaying(client *mpd.Client, checkPath string) bool {
+func checkPathPlaying(client *mpd.Client, match pathMatcher) bool {
 	song, err := client.CurrentSong()
 	if err != nil {
 		return true
@@ -181,16 +378,44 @@ func checkPathPlaying(client *mpd.Client, checkPath string) bool {
 		return true
 	}
 
-	return checkSongPath(song, checkPath)
+	return checkSongPath(song, match)
 }
 
-func checkSongPath(song mpd.Attrs, checkPath string) bool {
+func checkSongPath(song mpd.Attrs, match pathMatcher) bool {
 	if path, ok := song["file"]; ok {
-		return strings.HasPrefix(path, checkPath)
+		return match(path)
 	}
 	return false
 }
 
+func prefixMatcher(prefix string) pathMatcher {
+	return func(path string) bool {
+		return strings.HasPrefix(path, prefix)
+	}
+}
+
+// discMatcher matches the tracks of the disc in device, and the tracks
+// added without a device.
+func discMatcher(device string) pathMatcher {
+	if device == "" {
+		return prefixMatcher(CDDAPathPrefix)
+	}
+	devicePrefix := DiscURI(device) + "/"
+	return func(path string) bool {
+		return strings.HasPrefix(path, devicePrefix) || isDevicelessTrack(path)
+	}
+}
+
+// isDevicelessTrack reports whether path is a cdda:///N track.
+func isDevicelessTrack(path string) bool {
+	track, ok := strings.CutPrefix(path, CDDAPathPrefix+"/")
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(track)
+	return err == nil
+}
+
 // addUSBToQueue adds the specified label to the playlist.
 func addUSBToQueue(client *mpd.Client, label string) error {
 	if err := UpdateDBAndWait(client, label); err != nil {
@@ -207,7 +432,7 @@ func addUri(client *mpd.Client, uri string) error {
 	return nil
 }
 
-func deleteFromPlaylist(client *mpd.Client, checkPath string) error {
+func deleteFromPlaylist(client *mpd.Client, match pathMatcher) error {
 	playlist, err := client.PlaylistInfo(-1, -1)
 	if err != nil {
 		return fmt.Errorf("failed to fetch MPD playlist: %w", err)
@@ -216,7 +441,7 @@ func deleteFromPlaylist(client *mpd.Client, checkPath string) error {
 	start := -1 // Initialize start index to -1 to indicate no active range
 
 	for i := len(playlist) - 1; i >= -1; i-- {
-		if i >= 0 && checkSongPath(playlist[i], checkPath) {
+		if i >= 0 && checkSongPath(playlist[i], match) {
 			// Start a new range if not already started
 			if start == -1 {
 				start = i
@@ -304,37 +529,3 @@ func listMounts(client *mpd.Client) ([]mpd.Attrs, error) {
 	}
 	return res, nil
 }
-
-func checkMountsOrUnmount(client *mpd.Client, mounts []mpd.Attrs) error {
-	var errors []error
-	for _, v := range mounts {
-		if err := checkMountOrUnmount(client, v["storage"], v["mount"]); err != nil {
-			errors = append(errors, fmt.Errorf("failed to unmount %s: %w", v["mount"], err))
-		}
-	}
-
-	if len(errors) > 0 {
-		return fmt.Errorf("errors encountered while clearing mounts: %v", errors)
-	}
-	return nil
-}
-
-func checkMount(client *mpd.Client, storage, mount string) error {
-	if mount == "" {
-		return nil
-	}
-	if _, err := findNeighbor(client, []string{storage}); err != nil {
-		return fmt.Errorf("%s mount does not exists: %w", mount, err)
-	}
-	return nil
-}
-
-func checkMountOrUnmount(client *mpd.Client, storage, mount string) error {
-	if err := checkMount(client, storage, mount); err == nil {
-		return nil
-	}
-	if err := unmount(client, mount); err != nil {
-		return fmt.Errorf("failed to unmount %s: %w", mount, err)
-	}
-	return nil
-}
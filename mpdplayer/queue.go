@@ -0,0 +1,102 @@
+package mpdplayer
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// QueueMode tells how new tracks are added to the MPD queue.
+type QueueMode string
+
+const (
+	// QueueReplace clears the queue and plays the new tracks
+	QueueReplace QueueMode = "replace"
+	// QueueAppend adds the new tracks at the end of the queue, and plays
+	// them only if nothing is playing
+	QueueAppend QueueMode = "append"
+	// QueueNext inserts the new tracks after the current song, and plays
+	// them only if nothing is playing
+	QueueNext QueueMode = "next"
+	// QueueLoad adds the new tracks at the end of the queue without
+	// touching the playback
+	QueueLoad QueueMode = "load"
+)
+
+var QueueModes = []QueueMode{QueueReplace, QueueAppend, QueueNext, QueueLoad}
+
+func ParseQueueMode(mode string) (QueueMode, error) {
+	for _, m := range QueueModes {
+		if QueueMode(mode) == m {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("invalid queue mode %q, expected one of %v", mode, QueueModes)
+}
+
+// queueSlot is where new tracks go in the queue.
+type queueSlot struct {
+	mode QueueMode
+	// length of the queue before adding the tracks
+	length int
+	// position of the first new track once inserted
+	position int
+	state    string
+}
+
+// prepareQueue makes room for new tracks and returns where they go.
+func prepareQueue(client *mpd.Client, mode QueueMode) (*queueSlot, error) {
+	if mode == QueueReplace {
+		return &queueSlot{mode: mode}, clearQueue(client)
+	}
+	status, err := client.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MPD status: %w", err)
+	}
+	slot := &queueSlot{
+		mode:   mode,
+		length: atoiOr(status["playlistlength"], 0),
+		state:  status["state"],
+	}
+	slot.position = slot.length
+	if song, ok := status["song"]; ok && mode == QueueNext {
+		slot.position = atoiOr(song, slot.length-1) + 1
+	}
+	return slot, nil
+}
+
+// place moves the tracks added at the end of the queue to their position.
+func (slot *queueSlot) place(client *mpd.Client) error {
+	if slot.position == slot.length {
+		return nil
+	}
+	status, err := client.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get MPD status: %w", err)
+	}
+	length := atoiOr(status["playlistlength"], 0)
+	if length <= slot.length {
+		return nil
+	}
+	if err := client.Move(slot.length, length, slot.position); err != nil {
+		return fmt.Errorf("failed to move new tracks to position %d: %w", slot.position, err)
+	}
+	return nil
+}
+
+// play starts the playback of the new tracks, as required by the mode.
+func (slot *queueSlot) play(client *mpd.Client) error {
+	switch slot.mode {
+	case QueueReplace:
+		return client.Play(-1)
+	case QueueLoad:
+		log.Printf("info: Tracks loaded at position %d", slot.position)
+		return nil
+	}
+	if slot.state == "play" {
+		log.Printf("info: Tracks queued at position %d, keeping current playback", slot.position)
+		return nil
+	}
+	return client.Play(slot.position)
+}
@@ -0,0 +1,155 @@
+package mpdplayer
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// DiscPosition is where the playback of a disc stood.
+type DiscPosition struct {
+	Track   int       `json:"track"`
+	Elapsed float64   `json:"elapsed"`
+	Time    time.Time `json:"time"`
+}
+
+// DiscPosition returns the playback position of the disc in device, nil
+// when none of its tracks is the current song.
+func (rc *ReconnectingMPDClient) DiscPosition(device string) (*DiscPosition, error) {
+	var position *DiscPosition
+	err := rc.execute(func(client *mpd.Client) error {
+		status, err := client.Status()
+		if err != nil {
+			return fmt.Errorf("failed to get MPD status: %w", err)
+		}
+		song, err := client.CurrentSong()
+		if err != nil {
+			return fmt.Errorf("failed to get current song: %w", err)
+		}
+		track, ok := discTrack(device, song["file"])
+		if !ok {
+			return nil
+		}
+		position = &DiscPosition{
+			Track:   track,
+			Elapsed: atofOr(status["elapsed"], 0),
+			Time:    time.Now(),
+		}
+		return nil
+	})
+	return position, err
+}
+
+// ResumeDisc seeks back to position when the disc in device is playing,
+// which is reported by returning true. A disc only queued is left alone.
+func (rc *ReconnectingMPDClient) ResumeDisc(device string, position *DiscPosition) (bool, error) {
+	resumed := false
+	err := rc.execute(func(client *mpd.Client) error {
+		status, err := client.Status()
+		if err != nil {
+			return fmt.Errorf("failed to get MPD status: %w", err)
+		}
+		if status["state"] == "stop" {
+			return nil
+		}
+		song, err := client.CurrentSong()
+		if err != nil {
+			return fmt.Errorf("failed to get current song: %w", err)
+		}
+		if _, ok := discTrack(device, song["file"]); !ok {
+			return nil
+		}
+		playlist, err := client.PlaylistInfo(-1, -1)
+		if err != nil {
+			return fmt.Errorf("failed to fetch MPD playlist: %w", err)
+		}
+		file := fmt.Sprintf("%s/%d", DiscURI(device), position.Track)
+		for i, queued := range playlist {
+			if queued["file"] != file {
+				continue
+			}
+			if err := resume(client, i, position.Elapsed, status["state"]); err != nil {
+				return err
+			}
+			resumed = true
+			log.Printf("info: Resumed %s at track %d, %.0fs", device, position.Track, position.Elapsed)
+			return nil
+		}
+		return fmt.Errorf("track %d of %s is not queued", position.Track, device)
+	})
+	return resumed, err
+}
+
+// discTrack returns the track number of file when it is a track of the disc
+// in device.
+func discTrack(device, file string) (int, bool) {
+	track, ok := strings.CutPrefix(file, DiscURI(device)+"/")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(track)
+	return n, err == nil
+}
+
+// USBPosition is the queue order and playback position of the files of a
+// USB drive, relative to where it is mounted as its mount point may change.
+type USBPosition struct {
+	Files []string `json:"files"`
+	// File is the current song, empty when it is not on the drive
+	File    string    `json:"file,omitempty"`
+	Elapsed float64   `json:"elapsed"`
+	Random  bool      `json:"random"`
+	Repeat  bool      `json:"repeat"`
+	Time    time.Time `json:"time"`
+}
+
+// USBPosition returns the queue order and playback position of the USB drive
+// mounted at label, nil when none of its files is queued.
+func (rc *ReconnectingMPDClient) USBPosition(label string) (*USBPosition, error) {
+	var position *USBPosition
+	err := rc.execute(func(client *mpd.Client) error {
+		status, err := client.Status()
+		if err != nil {
+			return fmt.Errorf("failed to get MPD status: %w", err)
+		}
+		playlist, err := client.PlaylistInfo(-1, -1)
+		if err != nil {
+			return fmt.Errorf("failed to fetch MPD playlist: %w", err)
+		}
+		var files []string
+		for _, song := range playlist {
+			if file, ok := labelFile(label, song["file"]); ok {
+				files = append(files, file)
+			}
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		position = &USBPosition{
+			Files:  files,
+			Random: status["random"] == "1",
+			Repeat: status["repeat"] == "1",
+			Time:   time.Now(),
+		}
+		song := atoiOr(status["song"], -1)
+		if song < 0 || song >= len(playlist) || status["state"] == "stop" {
+			return nil
+		}
+		if file, ok := labelFile(label, playlist[song]["file"]); ok {
+			position.File = file
+			position.Elapsed = atofOr(status["elapsed"], 0)
+		}
+		return nil
+	})
+	return position, err
+}
+
+// labelFile returns file relative to label when it is on the USB drive
+// mounted there.
+func labelFile(label, file string) (string, bool) {
+	return strings.CutPrefix(file, label+"/")
+}
@@ -0,0 +1,112 @@
+package mpdplayer
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// QueueSnapshot is the MPD queue and playback position at a point in time.
+type QueueSnapshot struct {
+	Files   []string  `json:"files"`
+	Song    int       `json:"song"`
+	Elapsed float64   `json:"elapsed"`
+	State   string    `json:"state"`
+	Random  bool      `json:"random"`
+	Repeat  bool      `json:"repeat"`
+	Time    time.Time `json:"time"`
+}
+
+// SnapshotQueue returns the current queue and playback position, nil when
+// the queue is empty.
+func (rc *ReconnectingMPDClient) SnapshotQueue() (*QueueSnapshot, error) {
+	var snapshot *QueueSnapshot
+	err := rc.execute(func(client *mpd.Client) error {
+		status, err := client.Status()
+		if err != nil {
+			return fmt.Errorf("failed to get MPD status: %w", err)
+		}
+		playlist, err := client.PlaylistInfo(-1, -1)
+		if err != nil {
+			return fmt.Errorf("failed to fetch MPD playlist: %w", err)
+		}
+		if len(playlist) == 0 {
+			return nil
+		}
+		snapshot = &QueueSnapshot{
+			Files:   make([]string, 0, len(playlist)),
+			Song:    atoiOr(status["song"], -1),
+			Elapsed: atofOr(status["elapsed"], 0),
+			State:   status["state"],
+			Random:  status["random"] == "1",
+			Repeat:  status["repeat"] == "1",
+			Time:    time.Now(),
+		}
+		for _, song := range playlist {
+			snapshot.Files = append(snapshot.Files, song["file"])
+		}
+		return nil
+	})
+	return snapshot, err
+}
+
+// RestoreQueue loads a snapshot into the queue and resumes the playback
+// where it was. Nothing is done if the queue is not empty, which is
+// reported by returning false.
+func (rc *ReconnectingMPDClient) RestoreQueue(snapshot *QueueSnapshot) (bool, error) {
+	restored := false
+	err := rc.execute(func(client *mpd.Client) error {
+		status, err := client.Status()
+		if err != nil {
+			return fmt.Errorf("failed to get MPD status: %w", err)
+		}
+		if atoiOr(status["playlistlength"], 0) > 0 {
+			return nil
+		}
+		song := snapshot.Song
+		added := 0
+		for i, file := range snapshot.Files {
+			if err := addUri(client, file); err != nil {
+				// Media from an unplugged device can't be restored
+				log.Printf("warning: %v", err)
+				if i < snapshot.Song {
+					song--
+				}
+				continue
+			}
+			added++
+		}
+		if added == 0 {
+			return fmt.Errorf("none of the %d queued files could be restored", len(snapshot.Files))
+		}
+		restored = true
+		log.Printf("info: Restored %d/%d queued files", added, len(snapshot.Files))
+		if err := client.Random(snapshot.Random); err != nil {
+			return fmt.Errorf("failed to restore random: %w", err)
+		}
+		if err := client.Repeat(snapshot.Repeat); err != nil {
+			return fmt.Errorf("failed to restore repeat: %w", err)
+		}
+		if song >= added {
+			song = added - 1
+		}
+		return resume(client, song, snapshot.Elapsed, snapshot.State)
+	})
+	return restored, err
+}
+
+// resume puts the playback back at song and elapsed seconds, in state.
+func resume(client *mpd.Client, song int, elapsed float64, state string) error {
+	if song < 0 || state == "stop" || state == "" {
+		return nil
+	}
+	if err := client.SeekPos(song, time.Duration(elapsed*float64(time.Second))); err != nil {
+		return fmt.Errorf("failed to seek to %.0fs of song %d: %w", elapsed, song, err)
+	}
+	if state == "pause" {
+		return client.Pause(true)
+	}
+	return nil
+}
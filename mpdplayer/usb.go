@@ -0,0 +1,219 @@
+package mpdplayer
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/fhs/gompd/v2/mpd"
+)
+
+// USBOptions tell what to play from a USB drive and how, when its files
+// are not simply played in order.
+type USBOptions struct {
+	// Content selects and sorts the files of the drive, all of them being
+	// added in database order when nil
+	Content *USBContent
+	// Folder is played instead of the whole drive, relative to its mount
+	// point
+	Folder string
+	// URIs are played in order instead of the whole drive, relative to its
+	// mount point unless remote
+	URIs []string
+	// Shuffle shuffles the new tracks once queued
+	Shuffle bool
+	Random  *bool
+	Repeat  *bool
+	Volume  *int
+	// Start is the new track the playback starts at, from 1
+	Start int
+	// Position resumes the drive where it was removed, overriding Shuffle,
+	// Random, Repeat and Start
+	Position *USBPosition
+}
+
+// addToQueue adds the files of the USB drive mounted at label, in their
+// saved order if any, files added to the drive since then coming last.
+func (options *USBOptions) addToQueue(client *mpd.Client, label string) error {
+	status, err := client.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get MPD status: %w", err)
+	}
+	start := atoiOr(status["playlistlength"], 0)
+	if err := options.addURIs(client, label); err != nil {
+		return err
+	}
+	if options.Position != nil {
+		return options.Position.order(client, label, start)
+	}
+	if options.Shuffle {
+		return shuffleFrom(client, start)
+	}
+	return nil
+}
+
+// shuffleFrom shuffles the queue from start to its end.
+func shuffleFrom(client *mpd.Client, start int) error {
+	status, err := client.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get MPD status: %w", err)
+	}
+	end := atoiOr(status["playlistlength"], 0)
+	if end-start < 2 {
+		return nil
+	}
+	if err := client.Shuffle(start, end); err != nil {
+		return fmt.Errorf("failed to shuffle the new tracks: %w", err)
+	}
+	return nil
+}
+
+func (options *USBOptions) addURIs(client *mpd.Client, label string) error {
+	if err := UpdateDBAndWait(client, label); err != nil {
+		return fmt.Errorf("database update failed: %w", err)
+	}
+	if options.Folder != "" {
+		return options.addFiles(client, label, path.Join(label, options.Folder))
+	}
+	if len(options.URIs) == 0 {
+		return options.addFiles(client, label, label)
+	}
+	added := 0
+	for _, uri := range options.URIs {
+		if !isRemote(uri) {
+			uri = path.Join(label, uri)
+		}
+		if err := addUri(client, uri); err != nil {
+			log.Printf("warning: %v", err)
+			continue
+		}
+		added++
+	}
+	if added == 0 {
+		return fmt.Errorf("none of the %d files of %s could be added", len(options.URIs), label)
+	}
+	log.Printf("Added %d/%d files of %s to queue", added, len(options.URIs), label)
+	return nil
+}
+
+// addFiles adds the files found under uri, in the USB drive mounted at label.
+func (options *USBOptions) addFiles(client *mpd.Client, label, uri string) error {
+	if options.Content == nil {
+		log.Printf("Adding %s files to queue...", uri)
+		return addUri(client, uri)
+	}
+	return options.Content.addToQueue(client, label, uri)
+}
+
+// apply sets the playback options once the USB drive mounted at label is
+// playing. A drive only queued is left alone.
+func (options *USBOptions) apply(client *mpd.Client, label string) error {
+	status, err := client.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get MPD status: %w", err)
+	}
+	playlist, err := client.PlaylistInfo(-1, -1)
+	if err != nil {
+		return fmt.Errorf("failed to fetch MPD playlist: %w", err)
+	}
+	song := atoiOr(status["song"], -1)
+	if song < 0 || song >= len(playlist) || status["state"] == "stop" {
+		return nil
+	}
+	if !options.owns(label, playlist[song]["file"]) {
+		return nil
+	}
+	if options.Volume != nil {
+		if err := client.SetVolume(*options.Volume); err != nil {
+			return fmt.Errorf("failed to set volume: %w", err)
+		}
+	}
+	if options.Position != nil {
+		return options.Position.resume(client, label, playlist, status["state"])
+	}
+	if options.Random != nil {
+		if err := client.Random(*options.Random); err != nil {
+			return fmt.Errorf("failed to set random: %w", err)
+		}
+	}
+	if options.Repeat != nil {
+		if err := client.Repeat(*options.Repeat); err != nil {
+			return fmt.Errorf("failed to set repeat: %w", err)
+		}
+	}
+	if options.Start > 1 && song+options.Start-1 < len(playlist) {
+		return client.Play(song + options.Start - 1)
+	}
+	return nil
+}
+
+// owns reports whether file was added from the USB drive mounted at label.
+func (options *USBOptions) owns(label, file string) bool {
+	if _, ok := labelFile(label, file); ok {
+		return true
+	}
+	return slices.Contains(options.URIs, file)
+}
+
+// order moves the files of the USB drive mounted at label, queued from
+// start, to their saved order.
+func (position *USBPosition) order(client *mpd.Client, label string, start int) error {
+	playlist, err := client.PlaylistInfo(-1, -1)
+	if err != nil {
+		return fmt.Errorf("failed to fetch MPD playlist: %w", err)
+	}
+	if start > len(playlist) {
+		return nil
+	}
+	queued := make([]string, 0, len(playlist)-start)
+	for _, song := range playlist[start:] {
+		queued = append(queued, song["file"])
+	}
+	target := 0
+	for _, file := range position.Files {
+		i := slices.Index(queued[target:], path.Join(label, file))
+		if i < 0 {
+			// Removed from the drive since
+			continue
+		}
+		i += target
+		if i != target {
+			if err := client.Move(start+i, start+i+1, start+target); err != nil {
+				return fmt.Errorf("failed to restore the queue order: %w", err)
+			}
+			moved := queued[i]
+			queued = slices.Insert(slices.Delete(queued, i, i+1), target, moved)
+		}
+		target++
+	}
+	return nil
+}
+
+// resume restores the random and repeat flags and seeks back to the saved
+// song of the USB drive mounted at label.
+func (position *USBPosition) resume(client *mpd.Client, label string, playlist []mpd.Attrs, state string) error {
+	if err := client.Random(position.Random); err != nil {
+		return fmt.Errorf("failed to restore random: %w", err)
+	}
+	if err := client.Repeat(position.Repeat); err != nil {
+		return fmt.Errorf("failed to restore repeat: %w", err)
+	}
+	if position.File == "" {
+		return nil
+	}
+	file := path.Join(label, position.File)
+	for i, queued := range playlist {
+		if queued["file"] == file {
+			log.Printf("info: Resumed %s at %s, %.0fs", label, position.File, position.Elapsed)
+			return resume(client, i, position.Elapsed, state)
+		}
+	}
+	log.Printf("info: %s is not on %s anymore, not resuming", position.File, label)
+	return nil
+}
+
+func isRemote(uri string) bool {
+	return strings.Contains(uri, "://")
+}
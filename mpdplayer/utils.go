@@ -1,9 +1,53 @@
 package mpdplayer
 
 import (
+	"fmt"
+	"strconv"
+
+	"github.com/fhs/gompd/v2/mpd"
+	"go.uploadedlobster.com/discid"
+
 	"github.com/b0bbywan/go-disc-cuer/utils"
 )
 
 func getTrackCount(device string) (int, error) {
 	return utils.GetTrackCount(device)
 }
+
+// DiscID returns the FreeDB ID of the disc in device, the one its cue sheet
+// is cached under.
+func DiscID(device string) (string, error) {
+	disc, err := discid.Read(device)
+	if err != nil {
+		return "", fmt.Errorf("failed to read disc in %s: %w", device, err)
+	}
+	defer disc.Close()
+	return disc.FreedbID(), nil
+}
+
+func newPlayerStatus(status, song mpd.Attrs) *PlayerStatus {
+	return &PlayerStatus{
+		State:       status["state"],
+		Song:        atoiOr(status["song"], -1),
+		Elapsed:     atofOr(status["elapsed"], 0),
+		QueueLength: atoiOr(status["playlistlength"], 0),
+		File:        song["file"],
+		Artist:      song["Artist"],
+		Album:       song["Album"],
+		Title:       song["Title"],
+	}
+}
+
+func atoiOr(s string, fallback int) int {
+	if v, err := strconv.Atoi(s); err == nil {
+		return v
+	}
+	return fallback
+}
+
+func atofOr(s string, fallback float64) float64 {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v
+	}
+	return fallback
+}
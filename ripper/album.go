@@ -0,0 +1,128 @@
+package ripper
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Album is the metadata ripped tracks are tagged and named with.
+type Album struct {
+	Artist string
+	Title  string
+	Date   string
+	Genre  string
+	// Cover is the path of the front cover image, if any
+	Cover string
+	// Tracks are the track titles, in order
+	Tracks []string
+}
+
+// ReadCue reads the album metadata of a cue sheet generated by go-disc-cuer.
+func ReadCue(cuePath string) (*Album, error) {
+	file, err := os.Open(cuePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cue sheet: %w", err)
+	}
+	defer file.Close()
+
+	album := &Album{}
+	inTrack := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		keyword, value, _ := strings.Cut(line, " ")
+		if keyword == "REM" {
+			keyword, value, _ = strings.Cut(value, " ")
+			keyword = "REM " + keyword
+		}
+		value = cueValue(value)
+		switch {
+		case keyword == "TRACK":
+			inTrack = true
+			album.Tracks = append(album.Tracks, "")
+		case keyword == "TITLE" && inTrack:
+			album.Tracks[len(album.Tracks)-1] = value
+		case keyword == "TITLE":
+			album.Title = value
+		case keyword == "PERFORMER" && !inTrack:
+			album.Artist = value
+		case keyword == "REM DATE":
+			album.Date = value
+		case keyword == "REM GENRE":
+			album.Genre = value
+		case keyword == "REM COVER":
+			album.Cover = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cue sheet: %w", err)
+	}
+	return album, nil
+}
+
+// cueValue removes the quotes around a cue sheet value, the values written
+// by go-disc-cuer not being escaped.
+func cueValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// folder returns the folder of the album, relative to the rip folder.
+func (album *Album) folder() string {
+	return path.Join(safeName(album.Artist, "Unknown Artist"), safeName(album.Title, "Unknown Album"))
+}
+
+// title returns the title of track, from 1.
+func (album *Album) title(track int) string {
+	if track <= len(album.Tracks) && album.Tracks[track-1] != "" {
+		return album.Tracks[track-1]
+	}
+	return fmt.Sprintf("Track %02d", track)
+}
+
+// tags returns the Vorbis comments of track, as NAME=value.
+func (album *Album) tags(track, tracks int) []string {
+	tags := []string{
+		"TITLE=" + album.title(track),
+		"TRACKNUMBER=" + strconv.Itoa(track),
+		"TRACKTOTAL=" + strconv.Itoa(tracks),
+	}
+	for _, tag := range [][2]string{
+		{"ARTIST", album.Artist},
+		{"ALBUM", album.Title},
+		{"DATE", album.Date},
+		{"GENRE", album.Genre},
+	} {
+		if tag[1] != "" {
+			tags = append(tags, tag[0]+"="+tag[1])
+		}
+	}
+	return tags
+}
+
+// fileName returns the file name of track, without its extension.
+func (album *Album) fileName(track int) string {
+	return fmt.Sprintf("%02d - %s", track, safeName(album.title(track), "Track"))
+}
+
+// safeName makes name usable as a file or folder name, fallback if empty.
+func safeName(name, fallback string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r < ' ' {
+			return '-'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if name == "" {
+		return fallback
+	}
+	return name
+}
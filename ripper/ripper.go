@@ -0,0 +1,210 @@
+// Package ripper rips audio discs track by track into the MPD library,
+// cdparanoia reading the tracks and flac or opusenc encoding them.
+package ripper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/b0bbywan/go-disc-cuer/utils"
+
+	"github.com/b0bbywan/go-mpd-discplayer/jobs"
+)
+
+// Format is the audio format of ripped tracks.
+type Format string
+
+const (
+	// FormatFLAC encodes lossless FLAC files
+	FormatFLAC Format = "flac"
+	// FormatOpus encodes lossy Opus files
+	FormatOpus Format = "opus"
+)
+
+var Formats = []Format{FormatFLAC, FormatOpus}
+
+func ParseFormat(format string) (Format, error) {
+	for _, f := range Formats {
+		if Format(format) == f {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid rip format %q, expected one of %v", format, Formats)
+}
+
+// ErrRunning is returned when a rip is started on a drive already ripping.
+var ErrRunning = errors.New("rip already running")
+
+// Config tells where and how discs are ripped.
+type Config struct {
+	// Folder is where albums are ripped, in an artist and album folder
+	Folder string
+	Format Format
+	// OpusBitrate is the bitrate of Opus files, in kbit/s
+	OpusBitrate int
+}
+
+func NewConfig(folder string, format Format, opusBitrate int) *Config {
+	return &Config{
+		Folder:      folder,
+		Format:      format,
+		OpusBitrate: opusBitrate,
+	}
+}
+
+// Progress is reported before each track is ripped.
+type Progress struct {
+	Device string
+	// Track is the track being ripped, from 1
+	Track  int
+	Tracks int
+}
+
+// Resolver returns the metadata of the disc in a drive.
+type Resolver func(device string) (*Album, error)
+
+// Ripper rips discs in the background, one job per drive.
+type Ripper struct {
+	config  *Config
+	resolve Resolver
+	jobs    *jobs.Group
+}
+
+func NewRipper(config *Config, resolve Resolver) *Ripper {
+	return &Ripper{
+		config:  config,
+		resolve: resolve,
+		jobs:    jobs.NewGroup(),
+	}
+}
+
+// Start rips the disc in device in the background until done or ctx is
+// cancelled, offset being the read offset of the drive in samples. progress
+// is called before each track, and done once the job ends with the album
+// folder, relative to the rip folder.
+func (r *Ripper) Start(ctx context.Context, device string, offset int, progress func(Progress), done func(device, folder string, err error)) error {
+	var folder string
+	var err error
+	started := r.jobs.Start(ctx, device, func(ctx context.Context) {
+		folder, err = r.rip(ctx, device, offset, progress)
+	}, func() {
+		done(device, folder, err)
+	})
+	if !started {
+		return fmt.Errorf("%w on %s", ErrRunning, device)
+	}
+	return nil
+}
+
+// Cancel stops the rip of device, reporting whether one was running. The
+// track being ripped is discarded, those ripped are kept.
+func (r *Ripper) Cancel(device string) bool {
+	return r.jobs.Cancel(device)
+}
+
+// Running reports whether the disc in device is being ripped.
+func (r *Ripper) Running(device string) bool {
+	return r.jobs.Running(device)
+}
+
+// Wait blocks until the rips end, e.g. once the context they were started
+// with is cancelled.
+func (r *Ripper) Wait() {
+	r.jobs.Wait()
+}
+
+func (r *Ripper) rip(ctx context.Context, device string, offset int, progress func(Progress)) (string, error) {
+	album, err := r.resolve(device)
+	if err != nil {
+		return "", fmt.Errorf("failed to identify disc in %s: %w", device, err)
+	}
+	tracks := len(album.Tracks)
+	if tracks == 0 {
+		if tracks, err = utils.GetTrackCount(device); err != nil {
+			return "", fmt.Errorf("failed to get track count: %w", err)
+		}
+	}
+	folder := album.folder()
+	dir := filepath.Join(r.config.Folder, filepath.FromSlash(folder))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if album.Cover != "" {
+		if err := copyCover(album.Cover, dir); err != nil {
+			log.Printf("[rip] Failed to copy cover of %s: %v", folder, err)
+		}
+	}
+	log.Printf("[rip] Ripping %d tracks of %s into %s", tracks, device, dir)
+	for track := 1; track <= tracks; track++ {
+		if err := ctx.Err(); err != nil {
+			return folder, err
+		}
+		progress(Progress{Device: device, Track: track, Tracks: tracks})
+		path := filepath.Join(dir, album.fileName(track)+"."+string(r.config.Format))
+		if _, err := os.Stat(path); err == nil {
+			log.Printf("[rip] Track %d of %s already ripped", track, device)
+			continue
+		}
+		if err := r.ripTrack(ctx, device, offset, album, track, tracks, path); err != nil {
+			return folder, fmt.Errorf("failed to rip track %d: %w", track, err)
+		}
+	}
+	log.Printf("[rip] Ripped %s into %s", device, dir)
+	return folder, nil
+}
+
+// coverExtensions are the extensions of the cover images, by content type.
+var coverExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// copyCover copies the cover image src into dir as cover, its extension
+// told by its content, cover art being served with any file name.
+func copyCover(src, dir string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	f.Close()
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := coverExtensions[contentType]
+	if !ok {
+		return fmt.Errorf("%s is not an image but %s", src, contentType)
+	}
+	return copyFile(src, filepath.Join(dir, "cover"+ext))
+}
+
+func copyFile(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,37 @@
+package ripper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyCover(t *testing.T) {
+	images := map[string][]byte{
+		"cover.jpg":  {0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00},
+		"cover.png":  []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+		"cover.gif":  []byte("GIF89a\x01\x00\x01\x00"),
+		"cover.webp": []byte("RIFF\x24\x00\x00\x00WEBPVP8 "),
+	}
+	for want, content := range images {
+		src := filepath.Join(t.TempDir(), "art") // cached without extension
+		if err := os.WriteFile(src, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		dir := t.TempDir()
+		if err := copyCover(src, dir); err != nil {
+			t.Fatalf("%s: %v", want, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, want)); err != nil {
+			t.Errorf("%s not copied: %v", want, err)
+		}
+	}
+
+	src := filepath.Join(t.TempDir(), "cover.jpg")
+	if err := os.WriteFile(src, []byte("<html>not found</html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyCover(src, t.TempDir()); err == nil {
+		t.Fatal("page copied as cover")
+	}
+}
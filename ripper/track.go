@@ -0,0 +1,144 @@
+package ripper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CD audio is 16 bits stereo at 44.1 kHz, read raw in little endian.
+const (
+	sampleRate = "44100"
+	channels   = "2"
+	bits       = "16"
+)
+
+// readCommand reads track of the disc in device to its standard output,
+// shifted by the read offset of the drive, in samples.
+func readCommand(ctx context.Context, device string, track, offset int) *exec.Cmd {
+	args := []string{"--quiet", "--output-raw-little-endian", "--force-cdrom-device", device}
+	if offset != 0 {
+		args = append(args, "--sample-offset", strconv.Itoa(offset))
+	}
+	return exec.CommandContext(ctx, "cdparanoia", append(args, strconv.Itoa(track), "-")...)
+}
+
+// trackReader is the audio of a track read by cdparanoia.
+type trackReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+// OpenTrack reads track of the disc in device as 16 bits stereo little
+// endian samples, shifted by the read offset of the drive.
+func OpenTrack(ctx context.Context, device string, track, offset int) (io.ReadCloser, error) {
+	cmd := readCommand(ctx, device, track, offset)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+	return &trackReader{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+// Close stops reading, returning the error of cdparanoia if any.
+func (r *trackReader) Close() error {
+	r.ReadCloser.Close()
+	return commandError(r.cmd, r.cmd.Wait(), r.stderr)
+}
+
+// encodeCommand encodes the audio of its standard input to path, tagged.
+func (r *Ripper) encodeCommand(ctx context.Context, album *Album, track, tracks int, path string) *exec.Cmd {
+	if r.config.Format == FormatOpus {
+		args := []string{"--quiet", "--raw",
+			"--raw-rate", sampleRate, "--raw-chan", channels, "--raw-bits", bits,
+			"--bitrate", strconv.Itoa(r.config.OpusBitrate)}
+		for _, tag := range album.tags(track, tracks) {
+			args = append(args, "--comment", tag)
+		}
+		if album.Cover != "" {
+			args = append(args, "--picture", album.Cover)
+		}
+		return exec.CommandContext(ctx, "opusenc", append(args, "-", path)...)
+	}
+	args := []string{"--silent", "--force", "--force-raw-format", "--endian=little", "--sign=signed",
+		"--sample-rate=" + sampleRate, "--channels=" + channels, "--bps=" + bits}
+	for _, tag := range album.tags(track, tracks) {
+		args = append(args, "--tag="+tag)
+	}
+	if album.Cover != "" {
+		args = append(args, "--picture="+album.Cover)
+	}
+	return exec.CommandContext(ctx, "flac", append(args, "--output-name="+path, "-")...)
+}
+
+// ripTrack reads track and encodes it to a hidden file next to path, MPD
+// ignoring it until it is complete and renamed to path.
+func (r *Ripper) ripTrack(ctx context.Context, device string, offset int, album *Album, track, tracks int, path string) error {
+	part := filepath.Join(filepath.Dir(path), "."+filepath.Base(path))
+	defer os.Remove(part)
+
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+	var readErr, encodeErr bytes.Buffer
+	reader := readCommand(ctx, device, track, offset)
+	reader.Stdout = pipeWriter
+	reader.Stderr = &readErr
+	encoder := r.encodeCommand(ctx, album, track, tracks, part)
+	encoder.Stdin = pipeReader
+	encoder.Stderr = &encodeErr
+
+	if err := encoder.Start(); err != nil {
+		pipeReader.Close()
+		pipeWriter.Close()
+		return fmt.Errorf("failed to start %s: %w", encoder.Path, err)
+	}
+	pipeReader.Close()
+	if err := reader.Start(); err != nil {
+		pipeWriter.Close()
+		encoder.Wait()
+		return fmt.Errorf("failed to start %s: %w", reader.Path, err)
+	}
+	pipeWriter.Close()
+
+	err = reader.Wait()
+	if waitErr := encoder.Wait(); err == nil {
+		err = commandError(encoder, waitErr, &encodeErr)
+	} else {
+		err = commandError(reader, err, &readErr)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(part, path); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", part, err)
+	}
+	return nil
+}
+
+// commandError describes the failure of cmd with its error output.
+func commandError(cmd *exec.Cmd, err error, stderr *bytes.Buffer) error {
+	if err == nil {
+		return nil
+	}
+	if output := strings.TrimSpace(stderr.String()); output != "" {
+		return fmt.Errorf("%s: %w: %s", filepath.Base(cmd.Path), err, output)
+	}
+	return fmt.Errorf("%s: %w", filepath.Base(cmd.Path), err)
+}